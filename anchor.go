@@ -0,0 +1,79 @@
+package traefik_plugin_block_useragents
+
+// Auto-anchoring modes, see Config.AutoAnchor.
+const (
+	AutoAnchorNone   = "none"
+	AutoAnchorPrefix = "prefix"
+	AutoAnchorFull   = "full"
+)
+
+// validateAutoAnchor checks the auto-anchoring mode.
+func validateAutoAnchor(config *Config) error {
+	switch config.AutoAnchor {
+	case "", AutoAnchorNone, AutoAnchorPrefix, AutoAnchorFull:
+		return nil
+	default:
+		return configErrorf(ErrInvalidValue, "invalid autoAnchor %q, expected %q, %q or %q", config.AutoAnchor, AutoAnchorNone, AutoAnchorPrefix, AutoAnchorFull)
+	}
+}
+
+// anchorPattern anchors a regex at the start, and with the "full" mode also
+// at the end, of the User-Agent. The group keeps alternations whole: "a|b"
+// becomes "^(?:a|b)", not "^a|b".
+func anchorPattern(pattern, mode string) string {
+	switch mode {
+	case AutoAnchorPrefix:
+		return `^(?:` + pattern + `)`
+	case AutoAnchorFull:
+		return `^(?:` + pattern + `)$`
+	default:
+		return pattern
+	}
+}
+
+// anchorBrowsers returns a copy of a browser list with its regexes anchored.
+// Entries without a regex, generated later from names, are left alone.
+func anchorBrowsers(list []BrowserConfig, mode string) []BrowserConfig {
+	if list == nil {
+		return nil
+	}
+	anchored := make([]BrowserConfig, len(list))
+	for i, bc := range list {
+		if bc.Regex != "" {
+			bc.Regex = anchorPattern(bc.Regex, mode)
+		}
+		anchored[i] = bc
+	}
+	return anchored
+}
+
+// withAutoAnchor returns a copy of config with the regexes written in the
+// browser lists, including those of the shadow ruleset and the policies,
+// anchored per AutoAnchor. The patterns generated from browserSpecs and
+// names match version tokens inside the User-Agent, so they are added
+// afterwards and never anchored. AutoAnchor is cleared from the copy, so
+// the derived configurations are not anchored twice.
+func withAutoAnchor(config *Config) *Config {
+	switch config.AutoAnchor {
+	case AutoAnchorPrefix, AutoAnchorFull:
+	default:
+		return config
+	}
+	mode := config.AutoAnchor
+	anchored := *config
+	anchored.AllowedBrowsers = anchorBrowsers(config.AllowedBrowsers, mode)
+	anchored.BlockedBrowsers = anchorBrowsers(config.BlockedBrowsers, mode)
+	anchored.DenyBrowsers = anchorBrowsers(config.DenyBrowsers, mode)
+	anchored.SoftAllowedBrowsers = anchorBrowsers(config.SoftAllowedBrowsers, mode)
+	anchored.ShadowBrowsers = anchorBrowsers(config.ShadowBrowsers, mode)
+	if config.Policies != nil {
+		anchored.Policies = make(map[string]PolicyConfig, len(config.Policies))
+		for name, policy := range config.Policies {
+			policy.AllowedBrowsers = anchorBrowsers(policy.AllowedBrowsers, mode)
+			policy.BlockedBrowsers = anchorBrowsers(policy.BlockedBrowsers, mode)
+			anchored.Policies[name] = policy
+		}
+	}
+	anchored.AutoAnchor = ""
+	return &anchored
+}
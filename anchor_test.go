@@ -0,0 +1,65 @@
+package traefik_plugin_block_useragents
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestAnchorPattern(t *testing.T) {
+	tests := []struct {
+		mode string
+		want string
+	}{
+		{"", "curl|wget"},
+		{AutoAnchorNone, "curl|wget"},
+		{AutoAnchorPrefix, "^(?:curl|wget)"},
+		{AutoAnchorFull, "^(?:curl|wget)$"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			if got := anchorPattern("curl|wget", tt.mode); got != tt.want {
+				t.Errorf("anchorPattern() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAutoAnchor(t *testing.T) {
+	tests := []struct {
+		name      string
+		mode      string
+		userAgent string
+		want      int
+	}{
+		{"unanchored blocks embedded token", AutoAnchorNone, chromeUA + " curl/8.0", http.StatusForbidden},
+		{"prefix ignores embedded token", AutoAnchorPrefix, chromeUA + " curl/8.0", http.StatusOK},
+		{"prefix blocks leading token", AutoAnchorPrefix, "curl/8.0 " + chromeUA, http.StatusForbidden},
+		{"full ignores leading token", AutoAnchorFull, "curl/8.0 " + chromeUA, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := CreateConfig()
+			config.AllowedBrowsers = []BrowserConfig{{Name: "Mozilla", Regex: `Mozilla/5\.0 .*`}}
+			config.BrowserSpecs = []string{"Chrome >= 100"} // Never anchored
+			config.BlockedBrowsers = []BrowserConfig{{Name: "curl", Regex: `curl/[\d.]+`}}
+			config.AutoAnchor = tt.mode
+			h := newTestHandler(t, config, nil)
+			if rec := serve(h, tt.userAgent); rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestAutoAnchorPolicies(t *testing.T) {
+	config := CreateConfig()
+	config.AutoAnchor = AutoAnchorFull
+	config.Policies = map[string]PolicyConfig{"api": {AllowedBrowsers: []BrowserConfig{{Name: "client", Regex: "client/1"}}}}
+	anchored := withAutoAnchor(config)
+	if got := anchored.Policies["api"].AllowedBrowsers[0].Regex; got != "^(?:client/1)$" {
+		t.Errorf("policy regex = %q, want anchored", got)
+	}
+	if config.Policies["api"].AllowedBrowsers[0].Regex != "client/1" || anchored.AutoAnchor != "" {
+		t.Error("withAutoAnchor() changed the original config or kept autoAnchor")
+	}
+}
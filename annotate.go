@@ -0,0 +1,27 @@
+package traefik_plugin_block_useragents
+
+import "net/http"
+
+// Headers carrying the classification of allowed requests to the backend.
+const (
+	annotationBrowserHeader = "X-UA-Browser"
+	annotationOSHeader      = "X-UA-OS"
+)
+
+// annotateRequest sets the names of the first allowed browser and OS rules
+// matching the request. A header is left unset when no rule of its kind matches.
+func (b *BlockUserAgents) annotateRequest(req *http.Request) {
+	userAgents := b.userAgentValues(req)
+	for _, rule := range b.allowedRules {
+		if rule.appliesTo(req.Method) && matchesAny(rule.re, userAgents) {
+			req.Header.Set(annotationBrowserHeader, rule.name)
+			break
+		}
+	}
+	for i, re := range b.osRegexpsAllow {
+		if matchesAny(re, userAgents) {
+			req.Header.Set(annotationOSHeader, b.osNames[i])
+			break
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package traefik_plugin_block_useragents
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestAnnotate(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotate    bool
+		osTypes     []string
+		userAgent   string
+		wantBrowser string
+		wantOS      string
+	}{
+		{"chrome on windows", true, []string{"Windows NT", "X11"}, chromeUA, "Chrome", "Windows NT"},
+		{"firefox on linux", true, []string{"Windows NT", "X11"}, firefoxUA, "Firefox", "X11"},
+		{"no OS rule", true, nil, chromeUA, "Chrome", ""},
+		{"disabled", false, nil, chromeUA, "Spoofed", "Spoofed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.AllowedBrowsers = append(config.AllowedBrowsers, BrowserConfig{Name: "Firefox", Regex: `Firefox/\d+`})
+			config.AllowedOSTypes = tt.osTypes
+			config.Annotate = tt.annotate
+			var browser, os string
+			h := newTestHandler(t, config, http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+				browser, os = req.Header.Get("X-UA-Browser"), req.Header.Get("X-UA-OS")
+			}))
+
+			serve(h, tt.userAgent, withHeader("X-UA-Browser", "Spoofed"), withHeader("X-UA-OS", "Spoofed"))
+			if browser != tt.wantBrowser || os != tt.wantOS {
+				t.Errorf("annotations = %q, %q, want %q, %q", browser, os, tt.wantBrowser, tt.wantOS)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package traefik_plugin_block_useragents
+
+// embeddedBaseline is the ruleset layered beneath the configuration when
+// UseEmbeddedBaseline is set, in the rulesFile YAML format. It allows the
+// current major browsers, leaving the OS unrestricted. It is kept in source
+// rather than read with go:embed, which Yaegi does not support.
+const embeddedBaseline = `
+allowedBrowsers:
+  - name: "Chrome"
+    names: ["Chrome", "CriOS"]
+    version: ">= 120"
+  - name: "Edge"
+    names: ["Edge"]
+    version: ">= 120"
+  - name: "Firefox"
+    names: ["Firefox", "FxiOS"]
+    version: ">= 115" # Oldest supported ESR
+  - name: "Safari"
+    names: ["Safari"]
+    version: ">= 16"
+  - name: "Opera"
+    names: ["Opera"]
+    version: ">= 105"
+  - name: "Samsung Internet"
+    names: ["SamsungBrowser"]
+    version: ">= 23"
+`
+
+// withEmbeddedBaseline returns a copy of config with the embedded baseline
+// beneath its rules when UseEmbeddedBaseline is set. Allowed browsers of the
+// config replace the baseline entries of the same name, so an entry with
+// enabled: false removes one; the others are added after the baseline.
+func withEmbeddedBaseline(config *Config) (*Config, error) {
+	if !config.UseEmbeddedBaseline {
+		return config, nil
+	}
+	baseline, err := parseRules([]byte(embeddedBaseline), true, "embedded baseline")
+	if err != nil {
+		return nil, err
+	}
+	overridden := make(map[string]bool, len(config.AllowedBrowsers))
+	for _, bc := range config.AllowedBrowsers {
+		overridden[bc.Name] = true
+	}
+	merged := *config
+	merged.AllowedBrowsers = make([]BrowserConfig, 0, len(baseline.AllowedBrowsers)+len(config.AllowedBrowsers))
+	for _, bc := range baseline.AllowedBrowsers {
+		if !overridden[bc.Name] {
+			merged.AllowedBrowsers = append(merged.AllowedBrowsers, bc)
+		}
+	}
+	merged.AllowedBrowsers = append(merged.AllowedBrowsers, config.AllowedBrowsers...)
+	merged.AllowedOSTypes = append(baseline.AllowedOSTypes, config.AllowedOSTypes...)
+	merged.UseEmbeddedBaseline = false
+	return &merged, nil
+}
@@ -0,0 +1,53 @@
+package traefik_plugin_block_useragents
+
+import (
+	"net/http"
+	"testing"
+)
+
+const (
+	chrome119UA = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/119.0.0.0 Safari/537.36"
+	chrome129UA = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/129.0.0.0 Safari/537.36"
+)
+
+func TestEmbeddedBaseline(t *testing.T) {
+	disabled := false
+	tests := []struct {
+		name      string
+		browsers  []BrowserConfig
+		userAgent string
+		want      int
+	}{
+		{"baseline allows current Chrome", nil, chromeUA, http.StatusOK},
+		{"baseline allows Chrome 129", nil, chrome129UA, http.StatusOK},
+		{"baseline blocks old Chrome", nil, chrome119UA, http.StatusForbidden},
+		{"baseline allows Firefox ESR", nil, firefoxUA, http.StatusOK},
+		{"baseline allows Safari", nil, safariUA, http.StatusOK},
+		{"baseline blocks tools", nil, curlUA, http.StatusForbidden},
+		{"override replaces entry of same name", []BrowserConfig{{Name: "Chrome", Names: []string{"Chrome"}, Version: ">= 130"}}, chrome129UA, http.StatusForbidden},
+		{"override keeps other entries", []BrowserConfig{{Name: "Chrome", Names: []string{"Chrome"}, Version: ">= 130"}}, firefoxUA, http.StatusOK},
+		{"disabled entry removes baseline entry", []BrowserConfig{{Name: "Safari", Regex: "Safari", Enabled: &disabled}}, safariUA, http.StatusForbidden},
+		{"new entry added", []BrowserConfig{{Name: "curl", Regex: "^curl/"}}, curlUA, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := CreateConfig()
+			config.UseEmbeddedBaseline = true
+			config.AllowedBrowsers = tt.browsers
+			h := newTestHandler(t, config, nil)
+			if rec := serve(h, tt.userAgent); rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestEmbeddedBaselineParses(t *testing.T) {
+	rules, err := parseRules([]byte(embeddedBaseline), true, "embedded baseline")
+	if err != nil {
+		t.Fatalf("parseRules() error: %v", err)
+	}
+	if len(rules.AllowedBrowsers) == 0 {
+		t.Error("embedded baseline allows no browser")
+	}
+}
@@ -0,0 +1,224 @@
+package traefik_plugin_block_useragents
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+)
+
+// defaultBlockLogMaxBytes is the size at which the block log file is rotated
+// when BlockLogMaxBytes is not set.
+const defaultBlockLogMaxBytes = 10 << 20
+
+// blockLogQueueSize bounds the records waiting to be written; records
+// arriving while the queue is full are dropped.
+const blockLogQueueSize = 1024
+
+// blockLogSink appends blocked request records as JSON lines to a file from
+// a writer goroutine, so requests never wait on the disk. When the file
+// would exceed maxBytes, it is renamed with a ".1" suffix, replacing the
+// previous one, and a new file is started.
+type blockLogSink struct {
+	path     string
+	maxBytes int64
+
+	file *os.File // Owned by the writer goroutine once started
+	size int64
+
+	mu      sync.RWMutex
+	closed  bool
+	records chan []byte
+	done    chan struct{}
+
+	dropped atomic.Uint64
+
+	refs int // Guarded by blockLogRegistry
+}
+
+// validateBlockLog checks the block log file settings.
+func validateBlockLog(config *Config) error {
+	var errs ConfigErrors
+	if config.BlockLogMaxBytes < 0 {
+		errs.add(configErrorf(ErrInvalidValue, "blockLogMaxBytes must not be negative"))
+	}
+	if config.BlockLogMaxBytes != 0 && config.BlockLogFile == "" {
+		errs.add(configErrorf(ErrMissingSetting, "blockLogMaxBytes requires blockLogFile"))
+	}
+	return errs.err()
+}
+
+// blockLogRegistry shares one sink per block log file, so the instances
+// writing to a file (one per router, per configuration reload and per SIGHUP
+// reload) go through a single writer and rotate it once. A sink is opened by
+// the first reference to its file and closed with the last.
+var blockLogRegistry = struct {
+	sync.Mutex
+	sinks map[string]*blockLogSink
+}{sinks: make(map[string]*blockLogSink)}
+
+// blockLogRef is the reference of an instance to the sink of its block log
+// file.
+type blockLogRef struct {
+	sink   *blockLogSink
+	closed atomic.Bool
+	once   sync.Once
+	stop   chan struct{}
+}
+
+// newBlockLogRef references the sink of the block log file of a validated
+// config, opening the file and starting its writer when no other instance
+// writes to it, or returns nil when no file is configured. The size limit of
+// the instance that opened the file applies. The reference is released by
+// close or when ctx, the construction context, is cancelled.
+func newBlockLogRef(ctx context.Context, config *Config) (*blockLogRef, error) {
+	if config.BlockLogFile == "" {
+		return nil, nil
+	}
+	path := filepath.Clean(config.BlockLogFile)
+
+	blockLogRegistry.Lock()
+	defer blockLogRegistry.Unlock()
+	s, ok := blockLogRegistry.sinks[path]
+	if !ok {
+		s = &blockLogSink{
+			path:     path,
+			maxBytes: int64(config.BlockLogMaxBytes),
+			records:  make(chan []byte, blockLogQueueSize),
+			done:     make(chan struct{}),
+		}
+		if s.maxBytes == 0 {
+			s.maxBytes = defaultBlockLogMaxBytes
+		}
+		if err := s.open(); err != nil {
+			return nil, err
+		}
+		go s.run()
+		blockLogRegistry.sinks[path] = s
+	}
+	s.refs++
+	r := &blockLogRef{sink: s, stop: make(chan struct{})}
+	go func() {
+		select {
+		case <-ctx.Done():
+			r.close()
+		case <-r.stop:
+		}
+	}()
+	return r, nil
+}
+
+// write queues a record unless the reference is closed.
+func (r *blockLogRef) write(message *BlockUserAgentsMessage) {
+	if !r.closed.Load() {
+		r.sink.write(message)
+	}
+}
+
+// close releases the reference; the last one writes the queued records and
+// closes the file. It is idempotent.
+func (r *blockLogRef) close() {
+	r.once.Do(func() {
+		close(r.stop)
+		r.closed.Store(true)
+		blockLogRegistry.Lock()
+		r.sink.refs--
+		last := r.sink.refs == 0
+		if last {
+			delete(blockLogRegistry.sinks, r.sink.path)
+		}
+		blockLogRegistry.Unlock()
+		if last {
+			r.sink.close()
+		}
+	})
+}
+
+// open opens the log file for appending and records its current size.
+func (s *blockLogSink) open() error {
+	file, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("error opening block log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("error opening block log file: %w", err)
+	}
+	s.file, s.size = file, info.Size()
+	return nil
+}
+
+// run writes the queued records until the queue is closed.
+func (s *blockLogSink) run() {
+	defer close(s.done)
+	for record := range s.records {
+		if s.file == nil {
+			continue // Reopening after a rotation failed
+		}
+		if s.size > 0 && s.size+int64(len(record)) > s.maxBytes {
+			s.rotate()
+			if s.file == nil {
+				continue
+			}
+		}
+		n, err := s.file.Write(record)
+		s.size += int64(n)
+		if err != nil {
+			log.Printf("error writing block log file %q: %v", s.path, err)
+		}
+	}
+	if s.file != nil {
+		_ = s.file.Close()
+	}
+}
+
+// rotate moves the current file aside and starts a new one.
+func (s *blockLogSink) rotate() {
+	_ = s.file.Close()
+	s.file = nil
+	if err := os.Rename(s.path, s.path+".1"); err != nil {
+		log.Printf("error rotating block log file %q: %v", s.path, err)
+	}
+	if err := s.open(); err != nil {
+		log.Printf("%v, block records are dropped", err)
+	}
+}
+
+// write queues a record as a JSON line. It never blocks: the record is
+// dropped when the queue is full or the sink is closed.
+func (s *blockLogSink) write(message *BlockUserAgentsMessage) {
+	record, err := json.Marshal(message)
+	if err != nil {
+		return
+	}
+	record = append(record, '\n')
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return
+	}
+	select {
+	case s.records <- record:
+	default:
+		if s.dropped.Add(1) == 1 {
+			log.Printf("block log file %q cannot keep up, dropping records", s.path)
+		}
+	}
+}
+
+// close writes the queued records and closes the file. It is idempotent.
+func (s *blockLogSink) close() {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.records)
+	}
+	s.mu.Unlock()
+	<-s.done
+}
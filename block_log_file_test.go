@@ -0,0 +1,103 @@
+package traefik_plugin_block_useragents
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// readBlockLog returns the records of a block log file, failing on any line
+// that is not a whole JSON record.
+func readBlockLog(t *testing.T, path string) []BlockUserAgentsMessage {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var records []BlockUserAgentsMessage
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record BlockUserAgentsMessage
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("%s: corrupted record %q: %v", path, scanner.Text(), err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestBlockLogSharedRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocked.log")
+	const maxBytes = 2048
+	handlers := make([]*BlockUserAgents, 3)
+	for i := range handlers {
+		config := testConfig()
+		config.BlockLogFile = path
+		config.BlockLogMaxBytes = maxBytes
+		handlers[i] = newTestHandler(t, config, nil)
+	}
+	if handlers[0].blockLog.sink != handlers[2].blockLog.sink {
+		t.Fatal("instances writing the same file do not share its sink")
+	}
+
+	const requests = 60
+	for i := 0; i < requests; i++ {
+		serve(handlers[i%len(handlers)], fmt.Sprintf("bot/%d", i))
+	}
+	for _, h := range handlers {
+		_ = h.Close()
+	}
+	if _, ok := blockLogRegistry.sinks[path]; ok {
+		t.Error("sink still registered after the last instance closed")
+	}
+
+	current := readBlockLog(t, path)
+	rotated := readBlockLog(t, path+".1")
+	for _, name := range []string{path, path + ".1"} {
+		info, err := os.Stat(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() > maxBytes {
+			t.Errorf("%s is %d bytes, over the %d bytes limit", name, info.Size(), maxBytes)
+		}
+	}
+	// The two files hold the last records, in order and without gaps.
+	records := append(rotated, current...)
+	if len(records) == 0 || len(current) == 0 {
+		t.Fatal("no records written")
+	}
+	first := requests - len(records)
+	for i, record := range records {
+		if want := fmt.Sprintf("bot/%d", first+i); record.UserAgent != want {
+			t.Fatalf("record %d is for %q, want %q", i, record.UserAgent, want)
+		}
+	}
+}
+
+func TestBlockLogClosedWithContext(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocked.log")
+	config := testConfig()
+	config.BlockLogFile = path
+	ctx, cancel := context.WithCancel(context.Background())
+	ref, err := newBlockLogRef(ctx, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref.write(&BlockUserAgentsMessage{UserAgent: curlUA})
+	cancel()
+	select {
+	case <-ref.sink.done:
+	case <-time.After(time.Second):
+		t.Fatal("block log still open after the context was cancelled")
+	}
+	if records := readBlockLog(t, path); len(records) != 1 {
+		t.Errorf("%d records flushed, want 1", len(records))
+	}
+}
@@ -0,0 +1,91 @@
+package traefik_plugin_block_useragents
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// blockPage is a static block response body loaded at startup.
+type blockPage struct {
+	content     []byte
+	contentType string
+}
+
+// validateBlockPages checks the block page settings. The files themselves are
+// read by New.
+func validateBlockPages(config *Config) error {
+	var errs ConfigErrors
+	for reason, file := range config.BlockPagesByReason {
+		if _, ok := blockReasons[reason]; !ok {
+			errs.add(configErrorf(ErrUnknownReason, "unknown block reason %q in blockPagesByReason", reason))
+		}
+		if file == "" {
+			errs.add(configErrorf(ErrMissingSetting, "blockPagesByReason %q must name a file", reason))
+		}
+	}
+	return errs.err()
+}
+
+// loadBlockPage reads a block page, failing when it exceeds maxBytes. The
+// content type is taken from the file extension, or sniffed from the content.
+func loadBlockPage(name string, maxBytes int) (*blockPage, error) {
+	info, err := os.Stat(filepath.Clean(name))
+	if err != nil {
+		return nil, fmt.Errorf("error reading block page %q: %w", name, err)
+	}
+	if info.Size() > int64(maxBytes) {
+		return nil, fmt.Errorf("block page %q exceeds %d bytes", name, maxBytes)
+	}
+	content, err := os.ReadFile(filepath.Clean(name))
+	if err != nil {
+		return nil, fmt.Errorf("error reading block page %q: %w", name, err)
+	}
+	if len(content) > maxBytes {
+		return nil, fmt.Errorf("block page %q exceeds %d bytes", name, maxBytes)
+	}
+	contentType := mime.TypeByExtension(strings.ToLower(filepath.Ext(name)))
+	if contentType == "" {
+		contentType = http.DetectContentType(content)
+	}
+	return &blockPage{content: content, contentType: contentType}, nil
+}
+
+// loadBlockPages reads the block pages of a validated config.
+func loadBlockPages(config *Config, maxBytes int) (*blockPage, map[string]*blockPage, error) {
+	var defaultPage *blockPage
+	if config.BlockPageFile != "" {
+		page, err := loadBlockPage(config.BlockPageFile, maxBytes)
+		if err != nil {
+			return nil, nil, err
+		}
+		defaultPage = page
+	}
+	pages := make(map[string]*blockPage, len(config.BlockPagesByReason))
+	for reason, file := range config.BlockPagesByReason {
+		page, err := loadBlockPage(file, maxBytes)
+		if err != nil {
+			return nil, nil, err
+		}
+		pages[reason] = page
+	}
+	return defaultPage, pages, nil
+}
+
+// blockPageFor returns the block page for a reason: the page of the reason,
+// or of the part before its colon, then the default page. It returns nil
+// when no page applies.
+func (b *BlockUserAgents) blockPageFor(reason string) *blockPage {
+	if page, ok := b.blockPagesByReason[reason]; ok {
+		return page
+	}
+	if prefix, _, found := strings.Cut(reason, ":"); found {
+		if page, ok := b.blockPagesByReason[prefix]; ok {
+			return page
+		}
+	}
+	return b.blockPage
+}
@@ -0,0 +1,85 @@
+package traefik_plugin_block_useragents
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writePage writes a block page into a temporary directory and returns its path.
+func writePage(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestBlockPages(t *testing.T) {
+	config := testConfig()
+	config.Rules = []Rule{{Pattern: `Firefox/`, Action: RuleDeny}}
+	config.BlockPageFile = writePage(t, "blocked.html", "<h1>Blocked</h1>")
+	config.BlockPagesByReason = map[string]string{
+		"Denied Rule": writePage(t, "denied.txt", "denied by rule"),
+	}
+	h := newTestHandler(t, config, nil)
+
+	tests := []struct {
+		name            string
+		userAgent       string
+		wantBody        string
+		wantContentType string
+	}{
+		{"page of the reason prefix", firefoxUA, "denied by rule", "text/plain; charset=utf-8"},
+		{"default page", curlUA, "<h1>Blocked</h1>", "text/html; charset=utf-8"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(h, tt.userAgent)
+			if rec.Code != http.StatusForbidden {
+				t.Errorf("status = %d, want %d", rec.Code, http.StatusForbidden)
+			}
+			if got := rec.Body.String(); got != tt.wantBody {
+				t.Errorf("body = %q, want %q", got, tt.wantBody)
+			}
+			if got := rec.Header().Get("Content-Type"); got != tt.wantContentType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.wantContentType)
+			}
+		})
+	}
+}
+
+func TestBlockPageSizeLimit(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(*Config, string)
+	}{
+		{"blockPageFile", func(config *Config, page string) { config.BlockPageFile = page }},
+		{"blockPagesByReason", func(config *Config, page string) {
+			config.BlockPagesByReason = map[string]string{"Unsupported Browser": page}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.MaxBlockBodyBytes = 16
+			tt.edit(config, writePage(t, "large.html", strings.Repeat("x", 17)))
+			_, err := New(context.Background(), okHandler, config, "test")
+			if err == nil || !strings.Contains(err.Error(), "exceeds 16 bytes") {
+				t.Errorf("New error = %v, want the page to exceed 16 bytes", err)
+			}
+		})
+	}
+
+	config := testConfig()
+	config.MaxBlockBodyBytes = 16
+	config.BlockPageFile = writePage(t, "fits.html", strings.Repeat("x", 16))
+	h := newTestHandler(t, config, nil)
+	if got := serve(h, curlUA).Body.Len(); got != 16 {
+		t.Errorf("body length = %d, want 16", got)
+	}
+}
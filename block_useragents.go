@@ -2,160 +2,2233 @@
 package traefik_plugin_block_useragents
 
 import (
+	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
+	"html"
 	"log"
+	"math"
+	"net"
 	"net/http"
+	"net/url"
 	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
 )
 
 // BrowserConfig defines configuration for a single browser.
 type BrowserConfig struct {
-	Name    string `json:"name"`              // Browser name (e.g., "Chrome")
-	Regex   string `json:"regex,omitempty"`   // Required: Exact regex pattern to match the browser
-	Version string `json:"version,omitempty"` // Unused: Kept for compatibility but ignored
+	Name    string   `json:"name" yaml:"name"`                           // Browser name (e.g., "Chrome")
+	Regex   string   `json:"regex,omitempty" yaml:"regex,omitempty"`     // Required unless names is set: Exact regex pattern to match the browser, may reference ${ENV_VAR}
+	Names   []string `json:"names,omitempty" yaml:"names,omitempty"`     // Optional: Browser names matched on their version tokens instead of regex, e.g. ["Chrome", "Chromium", "Edge"]
+	Version string   `json:"version,omitempty" yaml:"version,omitempty"` // Optional with names: Shared version constraint, e.g. ">= 100" or "90..120"; ignored otherwise
+
+	Action      string `json:"action,omitempty" yaml:"action,omitempty"`           // Optional (blockedBrowsers only): "block" (default), "redirect" or "log-only"
+	RedirectURL string `json:"redirectUrl,omitempty" yaml:"redirectUrl,omitempty"` // Required when Action is "redirect"
+
+	Except  []string `json:"except,omitempty" yaml:"except,omitempty"`   // Optional (allowedBrowsers only): Regex patterns that block despite a match
+	Methods []string `json:"methods,omitempty" yaml:"methods,omitempty"` // Optional: HTTP methods the rule applies to (default: all)
+	Flags   string   `json:"flags,omitempty" yaml:"flags,omitempty"`     // Optional: Regex flags applied to Regex: "i", "s" and/or "m"
+
+	Enabled *bool  `json:"enabled,omitempty" yaml:"enabled,omitempty"` // Optional: Set to false to disable the rule without removing it (default true)
+	Comment string `json:"comment,omitempty" yaml:"comment,omitempty"` // Optional: Free-form note, ignored by matching
+	Label   string `json:"label,omitempty" yaml:"label,omitempty"`     // Optional: Metrics label counting the decisions made by this rule
+}
+
+// enabled reports whether the rule is enabled.
+func (bc BrowserConfig) enabled() bool {
+	return bc.Enabled == nil || *bc.Enabled
+}
+
+// enabledBrowsers returns the enabled entries of a browser list.
+func enabledBrowsers(list []BrowserConfig) []BrowserConfig {
+	enabled := make([]BrowserConfig, 0, len(list))
+	for _, bc := range list {
+		if bc.enabled() {
+			enabled = append(enabled, bc)
+		}
+	}
+	return enabled
+}
+
+// withEnabledRules returns a copy of the config without its disabled browser rules.
+func withEnabledRules(config *Config) *Config {
+	enabled := *config
+	enabled.AllowedBrowsers = enabledBrowsers(config.AllowedBrowsers)
+	enabled.BlockedBrowsers = enabledBrowsers(config.BlockedBrowsers)
+	enabled.DenyBrowsers = enabledBrowsers(config.DenyBrowsers)
+	enabled.SoftAllowedBrowsers = enabledBrowsers(config.SoftAllowedBrowsers)
+	return &enabled
+}
+
+// supportedRegexFlags lists the flags accepted in BrowserConfig.Flags.
+const supportedRegexFlags = "ism"
+
+// validateFlags checks that flags only contains supported regex flags.
+func (bc BrowserConfig) validateFlags() error {
+	var errs ConfigErrors
+	for _, flag := range bc.Flags {
+		if !strings.ContainsRune(supportedRegexFlags, flag) {
+			errs.add(configErrorf(ErrInvalidValue, "unsupported regex flag %q for browser: %s", flag, bc.Name))
+		}
+	}
+	return errs.err()
+}
+
+// pattern returns Regex with Flags applied as an inline (?flags) prefix.
+func (bc BrowserConfig) pattern() string {
+	if bc.Flags == "" {
+		return bc.Regex
+	}
+	return "(?" + bc.Flags + ")" + bc.Regex
+}
+
+// Actions applied to a request matching an entry of BlockedBrowsers.
+const (
+	ActionBlock    = "block"
+	ActionRedirect = "redirect"
+	ActionLogOnly  = "log-only"
+)
+
+// Decisions for User-Agents matching no allowed browser, see Config.DefaultDecision.
+const (
+	DefaultDecisionBlock = "block"
+	DefaultDecisionAllow = "allow"
+)
+
+// actionPrecedence ranks actions so the strictest matching rule wins.
+var actionPrecedence = map[string]int{
+	ActionLogOnly:  1,
+	ActionRedirect: 2,
+	ActionBlock:    3,
+}
+
+// Config holds the plugin configuration.
+type Config struct {
+	AllowedBrowsers     []BrowserConfig `json:"allowedBrowsers,omitempty"`     // List of browser configs
+	AllowedOSTypes      []string        `json:"allowedOSTypes,omitempty"`      // Optional: List of allowed OS regex patterns, may reference ${ENV_VAR}
+	AllowedOSNames      []string        `json:"allowedOSNames,omitempty"`      // Optional: Allowed OS families by name (Windows, macOS, iOS, Android, Linux, ChromeOS)
+	OSVersionRules      []string        `json:"osVersionRules,omitempty"`      // Optional: Minimum/maximum OS versions such as "win>=10" or "ios>=14"
+	BrandVersionRules   []string        `json:"brandVersionRules,omitempty"`   // Optional: Full versions required of Sec-CH-UA-Full-Version-List brands, e.g. "Google Chrome>=120.0.6099"
+	BlockedBrowsers     []BrowserConfig `json:"blockedBrowsers,omitempty"`     // Optional: Browsers handled by their own action before the allowlist
+	RateLimits          []RateLimitRule `json:"rateLimits,omitempty"`          // Optional: Request budgets for matching User-Agents
+	RulesFile           string          `json:"rulesFile,omitempty"`           // Optional: JSON or YAML file with extra allowedBrowsers/allowedOSTypes
+	RulesURL            string          `json:"rulesUrl,omitempty"`            // Optional: http(s) URL serving extra rules in the rulesFile format
+	MaxRulesBytes       int             `json:"maxRulesBytes,omitempty"`       // Optional: Size cap for rules fetched from rulesUrl (default 1 MiB)
+	GlobBrowsers        []string        `json:"globBrowsers,omitempty"`        // Optional: Allowed browsers as shell-glob patterns matching the whole User-Agent
+	BrowserSpecs        []string        `json:"browserSpecs,omitempty"`        // Optional: Allowed browsers as "<name> [<op> <version>]" specs, e.g. "Chrome >= 100"
+	UseEmbeddedBaseline bool            `json:"useEmbeddedBaseline,omitempty"` // Optional: Layer the built-in allowlist of current major browsers beneath allowedBrowsers
+
+	MatchAllHeaderValues  bool     `json:"matchAllHeaderValues,omitempty"`  // Optional: Match rules against every User-Agent header value, not just the first
+	MatchHeaders          []string `json:"matchHeaders,omitempty"`          // Optional: Headers to match, in order; the first non-empty one is used (default: User-Agent)
+	DecodeUserAgent       bool     `json:"decodeUserAgent,omitempty"`       // Optional: URL-decode and HTML-unescape the User-Agent before matching
+	MatchCombinedIdentity bool     `json:"matchCombinedIdentity,omitempty"` // Optional: Match allowedBrowsers against the User-Agent followed by the Sec-CH-UA* client hints
+	SkipInvalidPatterns   bool     `json:"skipInvalidPatterns,omitempty"`   // Optional: Log and skip uncompilable browser/OS patterns instead of failing
+
+	FingerprintHeader   string   `json:"fingerprintHeader,omitempty"`   // Optional: Header carrying the client TLS fingerprint (e.g., "X-JA3")
+	AllowedFingerprints []string `json:"allowedFingerprints,omitempty"` // Optional: Allowed fingerprint values, compared case-insensitively
+
+	SelfTestUserAgents []string `json:"selfTestUserAgents,omitempty"` // Optional: User-Agents that must be allowed, checked at startup
+
+	BlockResponseHeaders map[string]string `json:"blockResponseHeaders,omitempty"` // Optional: Headers added to every block response
+	ExposeReasonHeader   bool              `json:"exposeReasonHeader,omitempty"`   // Optional: Add the block reason as X-Block-Reason
+
+	Rules         []Rule `json:"rules,omitempty"`         // Optional: Ordered allow/deny rules, first match wins
+	DefaultAction string `json:"defaultAction,omitempty"` // Optional: "allow" or "deny" when no rule matches (default: use the allowlist)
+
+	DefaultDecision string `json:"defaultDecision,omitempty"` // Optional: "block" (default) or "allow" User-Agents matching no allowed browser
+
+	EmptyConfigBehavior string `json:"emptyConfigBehavior,omitempty"` // Optional: "error" (default), "allow-all" or "block-all" when no browser rule is configured
+
+	StrictValidation bool `json:"strictValidation,omitempty"` // Optional: Fail on duplicate or conflicting browser patterns instead of logging them
+
+	BlockStatusCode int            `json:"blockStatusCode,omitempty"` // Optional: Status of block responses (default 403)
+	StatusByReason  map[string]int `json:"statusByReason,omitempty"`  // Optional: Status per block reason, e.g. {"No User-Agent": 400}
+
+	ScoreRules     []ScoreRule `json:"scoreRules,omitempty"`     // Optional: Weighted patterns summed into a score per request
+	ScoreThreshold int         `json:"scoreThreshold,omitempty"` // Optional: Minimum score for requests to pass when scoreRules is set
+
+	CheckPlausibility bool `json:"checkPlausibility,omitempty"` // Optional: Block User-Agents that do not look like those of real clients
+	MinPlausibility   int  `json:"minPlausibility,omitempty"`   // Optional: Plausibility (0-100) below which User-Agents are blocked (default 50)
+	MinUASegments     int  `json:"minUASegments,omitempty"`     // Optional: Minimum number of slash, space or parenthesis delimited User-Agent segments
+
+	MinTLSVersion          string `json:"minTlsVersion,omitempty"`          // Optional: Minimum TLS version ("1.0" to "1.3") of the client connection
+	TLSVersionHeader       string `json:"tlsVersionHeader,omitempty"`       // Optional: Header forwarding the TLS version when terminated upstream (default "X-Forwarded-TLS-Version")
+	BlockUnknownTLSVersion bool   `json:"blockUnknownTlsVersion,omitempty"` // Optional: Block requests whose TLS version is absent or unparsable instead of allowing them
+
+	CheckSNIMatch   bool   `json:"checkSniMatch,omitempty"`   // Optional: Block requests whose Host differs from the TLS SNI
+	SNIHeader       string `json:"sniHeader,omitempty"`       // Optional: Header forwarding the SNI when TLS terminates upstream (default "X-Forwarded-SNI")
+	BlockMissingSNI bool   `json:"blockMissingSni,omitempty"` // Optional: Block requests without an SNI instead of allowing them
+
+	BlockHTTP10 bool `json:"blockHttp10,omitempty"` // Optional: Block requests made over HTTP/1.0 or HTTP/0.9
+
+	MatchTimeout string `json:"matchTimeout,omitempty"` // Optional: Go duration after which evaluation is abandoned and the request blocked (default: disabled)
+
+	Policies      map[string]PolicyConfig `json:"policies,omitempty"`      // Optional: Named browser/OS rulesets selected per host
+	HostPolicyMap map[string]string       `json:"hostPolicyMap,omitempty"` // Optional: Host regex to policy name, tried in sorted order
+	DefaultPolicy string                  `json:"defaultPolicy,omitempty"` // Optional: Policy for hosts matching no hostPolicyMap entry (default: top-level rules)
+
+	Annotate bool `json:"annotate,omitempty"` // Optional: Forward the matched browser and OS as X-UA-Browser and X-UA-OS
+
+	ReloadOnSignal bool `json:"reloadOnSignal,omitempty"` // Optional: Reload rulesFile/rulesUrl when the process receives SIGHUP
+
+	RequiredHeaders  []string `json:"requiredHeaders,omitempty"`  // Optional: Headers every request must carry (e.g., "Accept")
+	ForbiddenHeaders []string `json:"forbiddenHeaders,omitempty"` // Optional: Headers that block the request when present
+
+	ShadowBrowsers []BrowserConfig `json:"shadowBrowsers,omitempty"` // Optional: Candidate allowedBrowsers evaluated alongside the active ones, logging disagreements
+	ShadowOSTypes  []string        `json:"shadowOSTypes,omitempty"`  // Optional: Candidate allowedOSTypes evaluated alongside the active ones, logging disagreements
+
+	CorrelationHeader     string `json:"correlationHeader,omitempty"`     // Optional: Header carrying the request ID logged with events (default "X-Request-ID")
+	GenerateCorrelationID bool   `json:"generateCorrelationId,omitempty"` // Optional: Generate the request ID when absent and echo it on the response
+	Tracing               bool   `json:"tracing,omitempty"`               // Optional: Continue the W3C trace context (traceparent) with a span per request, see SpanHook
+
+	BlockLogFile     string `json:"blockLogFile,omitempty"`     // Optional: File receiving blocked requests as JSON lines
+	BlockLogMaxBytes int    `json:"blockLogMaxBytes,omitempty"` // Optional: Size at which blockLogFile is rotated to "<file>.1" (default 10 MiB)
+
+	WebhookURL        string `json:"webhookUrl,omitempty"`        // Optional: http(s) URL receiving blocked requests as JSON POSTs
+	WebhookAuthHeader string `json:"webhookAuthHeader,omitempty"` // Optional: Authorization header value sent to the webhook, e.g. "Bearer <token>"
+
+	ThreatFeedURL  string `json:"threatFeedUrl,omitempty"`  // Optional: http(s) URL serving User-Agent regexes to block, one per line
+	ReloadInterval string `json:"reloadInterval,omitempty"` // Optional: Go duration between threatFeedUrl and rulesUrl refreshes (default: fetched once)
+
+	DistinctBlockAlertThreshold int    `json:"distinctBlockAlertThreshold,omitempty"` // Optional: Warn when this many distinct User-Agents are blocked within the window
+	DistinctBlockAlertWindow    string `json:"distinctBlockAlertWindow,omitempty"`    // Optional: Rolling window of distinctBlockAlertThreshold as a Go duration (default "5m")
+
+	DenyBrowsers   []BrowserConfig `json:"denyBrowsers,omitempty"`   // Optional: Browsers to block, allowing everything else when allowedBrowsers is empty
+	AllowOverrides []string        `json:"allowOverrides,omitempty"` // Optional: Regex patterns exempting User-Agents from denyBrowsers
+
+	BlockResponseTemplate string            `json:"blockResponseTemplate,omitempty"` // Optional: Go text/template rendered as the block response body
+	MessagesByReason      map[string]string `json:"messagesByReason,omitempty"`      // Optional: Message per block reason, served as the body or exposed to the template as .Message
+
+	BlockPageFile      string            `json:"blockPageFile,omitempty"`      // Optional: Static page served as the block response body, read at startup
+	BlockPagesByReason map[string]string `json:"blockPagesByReason,omitempty"` // Optional: Static page per block reason, taking precedence over blockPageFile
+
+	CheckOrigin    bool     `json:"checkOrigin,omitempty"`    // Optional: Validate the Origin (or Referer) header against allowedOrigins
+	AllowedOrigins []string `json:"allowedOrigins,omitempty"` // Optional: Allowed Origin/Referer regex patterns
+
+	ChallengeBrowsers   []string `json:"challengeBrowsers,omitempty"`   // Optional: Regex patterns of User-Agents that must pass a JavaScript challenge
+	ChallengeCookieName string   `json:"challengeCookieName,omitempty"` // Optional: Name of the challenge cookie (default "ua_challenge")
+	ChallengeSecret     string   `json:"challengeSecret,omitempty"`     // Required with challengeBrowsers: Secret used to sign the cookie value
+	ChallengeTTL        string   `json:"challengeTtl,omitempty"`        // Optional: Go duration a passed challenge stays valid (default 24h)
+
+	LogSampleRate   float64 `json:"logSampleRate,omitempty"`   // Optional: Fraction (0.0-1.0) of block events to log (default 1.0)
+	LogMaxPerReason int     `json:"logMaxPerReason,omitempty"` // Optional: Block events logged per reason within logDedupeWindow (default 0, unlimited)
+	LogDedupeWindow string  `json:"logDedupeWindow,omitempty"` // Optional: Go duration of the logMaxPerReason window (default 1m)
+
+	CacheSize int    `json:"cacheSize,omitempty"` // Optional: Number of decisions to cache (default 0, disabled)
+	CacheTTL  string `json:"cacheTTL,omitempty"`  // Optional: Lifetime of cached decisions as a Go duration (default: no expiry)
+
+	NormalizeForward bool          `json:"normalizeForward,omitempty"` // Optional: Rewrite the User-Agent forwarded to the backend
+	NormalizeRules   []ReplaceRule `json:"normalizeRules,omitempty"`   // Optional: Rewrites applied in order when normalizeForward is set
+
+	EvaluationOrder []string `json:"evaluationOrder,omitempty"` // Optional: Order of the checks ("ua", "bot", "browser", "os", "fingerprint", "origin", "language")
+
+	RequireMatchCount int `json:"requireMatchCount,omitempty"` // Optional: Number of allowed browser patterns a User-Agent must match (default 1)
+	MinMatchLength    int `json:"minMatchLength,omitempty"`    // Optional: Minimum length of the text matched by an allowed browser pattern (default 0, any)
+
+	DeniedIPs            []string `json:"deniedIPs,omitempty"`            // Optional: Client IPs and CIDRs blocked regardless of User-Agent
+	TrustForwardedHeader bool     `json:"trustForwardedHeader,omitempty"` // Optional: Resolve the client IP from X-Forwarded-For
+	TrustedProxies       []string `json:"trustedProxies,omitempty"`       // Optional: Proxy IPs and CIDRs whose X-Forwarded-For is honored (default: any)
+	ClientIPHeaders      []string `json:"clientIpHeaders,omitempty"`      // Optional: Headers carrying the client IP, tried in order (default: X-Forwarded-For)
+
+	OSMatchMode string `json:"osMatchMode,omitempty"` // Optional: "allow" (default) requires an allowedOSTypes match, "block" bans matching OS types
+
+	AllowGRPC bool `json:"allowGrpc,omitempty"` // Optional: Skip the browser check for gRPC requests
+
+	AllowPreflight bool `json:"allowPreflight,omitempty"` // Optional: Forward CORS preflight requests without the User-Agent checks (default true)
+
+	RecoverDownstream bool `json:"recoverDownstream,omitempty"` // Optional: Log panics of the next handler and answer with recoverStatusCode
+	RecoverStatusCode int  `json:"recoverStatusCode,omitempty"` // Optional: Status answered after a recovered panic (default 500)
+	RepanicDownstream bool `json:"repanicDownstream,omitempty"` // Optional: Re-raise recovered panics after logging them, without answering
+
+	LearnMode     bool   `json:"learnMode,omitempty"`     // Optional: Forward every request unchecked and report rules allowing the browsers seen
+	LearnInterval string `json:"learnInterval,omitempty"` // Optional: Go duration between learn mode reports (default 1h)
+	LearnFile     string `json:"learnFile,omitempty"`     // Optional: Rules file (JSON, or YAML for .yaml/.yml) replaced with each learn mode report
+
+	EnableValidateEndpoint bool     `json:"enableValidateEndpoint,omitempty"` // Optional: Serve a config validation endpoint at validatePath
+	ValidatePath           string   `json:"validatePath,omitempty"`           // Required with enableValidateEndpoint: Path of the validation endpoint
+	ValidateAllowedIPs     []string `json:"validateAllowedIps,omitempty"`     // Optional: Client IPs and CIDRs allowed to call the validation endpoint (default: loopback)
+
+	MetricsPath       string   `json:"metricsPath,omitempty"`       // Optional: Path answered with the request and decision cache counters as JSON
+	MetricsAllowedIPs []string `json:"metricsAllowedIps,omitempty"` // Optional: Client IPs and CIDRs allowed to call the metrics endpoint (default: loopback)
+
+	SoftAllowedBrowsers []BrowserConfig `json:"softAllowedBrowsers,omitempty"` // Optional: Browsers whose misses are only logged (Soft-Miss) on allowed requests
+
+	MaxBlockBodyBytes int `json:"maxBlockBodyBytes,omitempty"` // Optional: Cap on the block response body size (default 64 KiB)
+
+	CompressBlockResponse bool `json:"compressBlockResponse,omitempty"` // Optional: Gzip block response bodies of 1 KiB or more for clients accepting it
+
+	CheckLanguage    bool     `json:"checkLanguage,omitempty"`    // Optional: Require an Accept-Language header matching allowedLanguages
+	AllowedLanguages []string `json:"allowedLanguages,omitempty"` // Optional: Allowed Accept-Language regex patterns
+
+	Expvar bool `json:"expvar,omitempty"` // Optional: Publish counters through expvar under "block_useragents.<name>"
+
+	RequireScheme string `json:"requireScheme,omitempty"` // Optional: "https" or "http", requests using the other scheme are rejected
+	SchemeAction  string `json:"schemeAction,omitempty"`  // Optional: "block" (default) or "redirect" to the required scheme
+
+	CombinePatterns bool `json:"combinePatterns,omitempty"` // Optional: Match allowed browsers with a single combined regex
+
+	AutoAnchor string `json:"autoAnchor,omitempty"` // Optional: "none" (default), "prefix" anchors browser regexes at the start of the User-Agent, "full" at both ends
+
+	EnforcementDelay string `json:"enforcementDelay,omitempty"` // Optional: Go duration after startup during which User-Agent decisions are only logged
+
+	LogFormat string `json:"logFormat,omitempty"` // Optional: "text" (default), "json" or "logfmt"
+	LogTiming bool   `json:"logTiming,omitempty"` // Optional: Add the event timestamp and evaluation time to logged requests
+
+	BypassHeaderName   string   `json:"bypassHeaderName,omitempty"`   // Optional: Header whose valid token skips all checks; it is never forwarded
+	BypassHeaderValues []string `json:"bypassHeaderValues,omitempty"` // Required with bypassHeaderName: Accepted bypass tokens
+
+	MaintenanceMode bool `json:"maintenanceMode,omitempty"` // Optional: Answer every request with 503 without evaluating the rules
+}
+
+// defaultMaxBlockBodyBytes caps block response bodies when MaxBlockBodyBytes is not set.
+const defaultMaxBlockBodyBytes = 64 << 10
+
+// OS match modes.
+const (
+	OSMatchAllow = "allow"
+	OSMatchBlock = "block"
+)
+
+// ReplaceRule rewrites the parts of a User-Agent matching Regex.
+type ReplaceRule struct {
+	Regex       string `json:"regex,omitempty"`       // Required: Regex pattern to replace
+	Replacement string `json:"replacement,omitempty"` // Replacement text, may reference groups like ${1}
+}
+
+// BlockTemplateData is the data available to BlockResponseTemplate.
+type BlockTemplateData struct {
+	Reason    string
+	Message   string // From MessagesByReason, empty when the reason has none
+	UserAgent string
+	Host      string
+}
+
+// CreateConfig creates and initializes the plugin configuration.
+func CreateConfig() *Config {
+	return &Config{
+		AllowedBrowsers:   []BrowserConfig{},
+		AllowedOSTypes:    []string{},
+		AllowedOSNames:    []string{},
+		OSVersionRules:    []string{},
+		BrandVersionRules: []string{},
+		BlockedBrowsers:   []BrowserConfig{},
+		RateLimits:        []RateLimitRule{},
+		GlobBrowsers:      []string{},
+		BrowserSpecs:      []string{},
+
+		AllowedFingerprints: []string{},
+		SelfTestUserAgents:  []string{},
+
+		BlockResponseHeaders: map[string]string{},
+
+		Rules: []Rule{},
+
+		DenyBrowsers:   []BrowserConfig{},
+		AllowOverrides: []string{},
+
+		AllowedOrigins: []string{},
+
+		ChallengeBrowsers: []string{},
+
+		LogSampleRate: 1.0,
+
+		NormalizeRules: []ReplaceRule{},
+
+		EvaluationOrder: []string{},
+
+		RequireMatchCount: 1,
+
+		AllowPreflight: true,
+
+		MatchHeaders: []string{},
+
+		BypassHeaderValues: []string{},
+
+		StatusByReason: map[string]int{},
+
+		MessagesByReason: map[string]string{},
+
+		BlockPagesByReason: map[string]string{},
+
+		ScoreRules: []ScoreRule{},
+
+		Policies:      map[string]PolicyConfig{},
+		HostPolicyMap: map[string]string{},
+
+		RequiredHeaders:  []string{},
+		ForbiddenHeaders: []string{},
+
+		DeniedIPs:       []string{},
+		TrustedProxies:  []string{},
+		ClientIPHeaders: []string{},
+
+		SoftAllowedBrowsers: []BrowserConfig{},
+
+		AllowedLanguages: []string{},
+	}
+}
+
+// BlockUserAgents struct.
+type BlockUserAgents struct {
+	name              string
+	next              http.Handler
+	allowedRules      []browserRule      // Browser rules
+	osRegexpsAllow    []*regexp.Regexp   // OS regex patterns (optional)
+	osNames           []string           // Names of the OS patterns, for annotations
+	osVersionRules    []osVersionRule    // OS version cutoffs (optional)
+	brandVersionRules []brandVersionRule // Client hint brand version cutoffs (optional)
+	blockedRules      []browserRule      // Blocked browser rules with their actions (optional)
+	rateLimiters      []*rateLimiter     // Rate limits for matching User-Agents (optional)
+
+	matchAllHeaderValues  bool
+	matchHeaders          []string
+	matchCombinedIdentity bool
+	decodeUserAgents      bool
+
+	fingerprintHeader   string
+	allowedFingerprints map[string]struct{}
+
+	blockResponseHeaders map[string]string
+	exposeReasonHeader   bool
+
+	orderedRules  []orderedRule
+	defaultAction string
+
+	denyRules      []browserRule
+	allowOverrides []*regexp.Regexp
+
+	blockTemplate *template.Template
+
+	checkOrigin    bool
+	allowedOrigins []*regexp.Regexp
+
+	challengeRegexps    []*regexp.Regexp
+	challengeCookieName string
+	challengeSecret     string
+	challengeTTL        time.Duration
+
+	logSampler *logSampler
+	logDeduper *logDeduper // Caps the block events logged per reason (optional)
+
+	cache        *decisionCache
+	hasPathRules bool
+
+	normalizeRules []replaceRule
+
+	evaluationOrder []string
+
+	guard *subsystemGuard
+
+	requireMatchCount int
+	minMatchLength    int
+
+	clock clock
+
+	enforceAfter time.Time   // End of the enforcement delay, zero without one
+	enforcing    atomic.Bool // Set once the enforcement delay is over
+
+	deniedIPs            []*net.IPNet
+	trustForwardedHeader bool
+	trustedProxies       []*net.IPNet
+	clientIPHeaders      []string
+
+	osMatchMode string
+
+	allowGRPC      bool
+	allowPreflight bool
+
+	recoverDownstream bool
+	recoverStatusCode int
+	repanicDownstream bool
+
+	validatePath       string
+	validateAllowedIPs []*net.IPNet
+
+	metricsPath       string
+	metricsAllowedIPs []*net.IPNet
+
+	softRules []browserRule
+
+	maxBlockBodyBytes int
+	blockBodyBytes    atomic.Uint64 // Total block response body bytes written
+
+	compressBlockResponse bool
+
+	checkLanguageEnabled bool
+	allowedLanguages     []*regexp.Regexp
+
+	expvar *expvarMetrics
+	stats  *requestStats // Counters behind Stats, shared with the policies and reloaded rulesets
+
+	requireScheme string
+	schemeAction  string
+
+	combinedRegexp *regexp.Regexp
+	singleRule     *regexp.Regexp
+
+	defaultAllow bool // Allow User-Agents matching no allowed browser
+
+	emptyConfig string // EmptyConfigBehavior when the ruleset is empty, "" otherwise
+
+	blockStatusCode  int
+	statusByReason   map[string]int
+	messagesByReason map[string]string
+
+	blockPage          *blockPage
+	blockPagesByReason map[string]*blockPage
+
+	scoreRules     []scoreRule
+	scoreThreshold int
+
+	checkPlausibilityEnabled bool
+	minPlausibility          int
+	minUASegments            int
+
+	minTLSVersion    uint16
+	tlsVersionHeader string
+	blockUnknownTLS  bool
+
+	checkSNIMatchEnabled bool
+	sniHeader            string
+	blockMissingSNI      bool
+
+	blockHTTP10 bool
+
+	matchTimeout time.Duration
+	evalTimeouts atomic.Uint64
+
+	policies      map[string]*BlockUserAgents
+	hostPolicies  []hostPolicy
+	defaultPolicy *BlockUserAgents
+
+	annotate bool
+
+	requiredHeaders  []string
+	forbiddenHeaders []string
+
+	distinct *distinctTracker // Distinct blocked User-Agent alert (optional)
+
+	threatFeed *threatFeed // Denylist fetched from ThreatFeedURL (optional)
+
+	learner *learner // Records the browsers seen in learn mode (optional)
+
+	correlationHeader     string
+	generateCorrelationID bool
+	tracing               bool
+
+	shadow *BlockUserAgents // Shadow ruleset compared with the active one (optional)
+
+	blockLog *blockLogRef // Block log file writer (optional)
+	webhook  *webhookSink // Webhook poster (optional)
+
+	ctx             context.Context                 // Construction context, also that of the rulesets reloaded from reloadConfig
+	reloadConfig    *Config                         // Configuration to rebuild from on SIGHUP or rulesUrl changes, nil when disabled
+	reloadStop      chan struct{}                   // Closed by Close to stop waiting for ctx before leaving the SIGHUP registry
+	reloaded        atomic.Pointer[BlockUserAgents] // Latest ruleset reloaded, serving requests once set
+	rulesValidators httpValidators                  // Cache validators of the rulesUrl response
+	rulesWatch      *rulesWatch                     // rulesUrl refresh (optional)
+
+	closeOnce sync.Once
+
+	logFormat string
+	logTiming bool
+
+	bypassHeader string
+	bypassTokens []string
+
+	maintenanceMode bool
+
+	// DecisionHook, when set, runs on every request after the built-in checks
+	// and may override their decision. It must be fast and safe for concurrent use.
+	DecisionHook func(req *http.Request, defaultAllow bool) (allow bool, reason string)
+
+	// SpanHook, when set with tracing enabled, receives the span of every
+	// request as it leaves the middleware, e.g. to export it to a tracer. It
+	// must be fast and safe for concurrent use.
+	SpanHook func(span DecisionSpan)
+}
+
+// replaceRule is a compiled ReplaceRule.
+type replaceRule struct {
+	re          *regexp.Regexp
+	replacement string
+}
+
+// browserRule carries a compiled browser regex along with its rule metadata.
+type browserRule struct {
+	name        string
+	label       string // Metrics label, empty when unlabeled
+	re          *regexp.Regexp
+	action      string
+	redirectURL string
+	except      []*regexp.Regexp
+	methods     map[string]struct{} // Methods the rule applies to, nil for all
+}
+
+// appliesTo reports whether the rule is in scope for the given HTTP method.
+func (r *browserRule) appliesTo(method string) bool {
+	if r.methods == nil {
+		return true
+	}
+	_, ok := r.methods[method]
+	return ok
+}
+
+// methodSet builds the method scope of a rule, returning nil when it applies to all methods.
+func methodSet(methods []string) map[string]struct{} {
+	if len(methods) == 0 {
+		return nil
+	}
+	set := make(map[string]struct{}, len(methods))
+	for _, method := range methods {
+		set[strings.ToUpper(strings.TrimSpace(method))] = struct{}{}
+	}
+	return set
+}
+
+// BlockUserAgentsMessage struct for logging blocked requests.
+type BlockUserAgentsMessage struct {
+	UserAgent  string `json:"user-agent"`
+	RemoteAddr string `json:"ip"`
+	Host       string `json:"host"`
+	RequestURI string `json:"uri"`
+
+	ParsedBrowser string `json:"browser,omitempty"` // Best-effort browser name and version
+	ParsedOS      string `json:"os,omitempty"`      // Best-effort OS name and version
+
+	MatchHeader string `json:"matchHeader,omitempty"` // Header the rules were matched against, when not User-Agent
+
+	RequestID string `json:"requestId,omitempty"` // Value of the correlationHeader, when present
+	TraceID   string `json:"traceId,omitempty"`   // With tracing: Trace of the request
+	SpanID    string `json:"spanId,omitempty"`    // With tracing: Span of the request in the middleware
+
+	Timestamp  string `json:"timestamp,omitempty"`  // With logTiming: RFC 3339 time of the event
+	EvalMicros *int64 `json:"evalMicros,omitempty"` // With logTiming: Time spent evaluating the request, in microseconds
+
+	Event  string `json:"event,omitempty"`  // Structured log formats only: "Blocked", "LogOnly" or "Soft-Miss"
+	Name   string `json:"name,omitempty"`   // Structured log formats only: Middleware name
+	Reason string `json:"reason,omitempty"` // Structured log formats only: Block reason
+}
+
+// Behaviors for a configuration without browser rules, see Config.EmptyConfigBehavior.
+const (
+	EmptyConfigError    = "error"
+	EmptyConfigAllowAll = "allow-all"
+	EmptyConfigBlockAll = "block-all"
+)
+
+// emptyRuleset reports whether the configuration has no rule deciding on
+// browsers, which emptyConfigBehavior handles.
+func emptyRuleset(config *Config) bool {
+	return config.DefaultAction == "" && len(config.AllowedBrowsers) == 0 && len(config.GlobBrowsers) == 0 && len(config.BrowserSpecs) == 0 &&
+		len(config.DenyBrowsers) == 0 && config.DefaultDecision != DefaultDecisionAllow && config.DefaultPolicy == ""
+}
+
+// emptyConfigBehavior returns the behavior applying to an empty ruleset, or ""
+// when the ruleset is not empty.
+func emptyConfigBehavior(config *Config) string {
+	if !emptyRuleset(config) {
+		return ""
+	}
+	return config.EmptyConfigBehavior
+}
+
+// ValidateConfig validates the plugin configuration. It reports every
+// problem it finds as a ConfigErrors, whose entries can be told apart with
+// errors.Is and the Err* kinds.
+func ValidateConfig(config *Config) error {
+	config, err := withEmbeddedBaseline(config)
+	if err != nil {
+		return ConfigErrors{configErrorf(ErrInvalidValue, "%w", err)}
+	}
+	if config, err = withBrowserNames(config); err != nil {
+		return ConfigErrors{configErrorf(ErrInvalidValue, "%w", err)}
+	}
+	var errs ConfigErrors
+	switch config.DefaultDecision {
+	case "", DefaultDecisionBlock, DefaultDecisionAllow:
+	default:
+		errs.add(configErrorf(ErrInvalidValue, "invalid defaultDecision %q", config.DefaultDecision))
+	}
+	switch config.EmptyConfigBehavior {
+	case "", EmptyConfigError:
+		if emptyRuleset(config) && !config.LearnMode {
+			errs.add(ErrNoBrowsers)
+		}
+	case EmptyConfigAllowAll, EmptyConfigBlockAll:
+	default:
+		errs.add(configErrorf(ErrInvalidValue, "invalid emptyConfigBehavior %q", config.EmptyConfigBehavior))
+	}
+	switch config.DefaultAction {
+	case "":
+	case RuleAllow, RuleDeny:
+		if config.DefaultDecision != "" {
+			errs.add(configErrorf(ErrConflictingSettings, "defaultDecision has no effect when defaultAction is set"))
+		}
+	default:
+		errs.add(configErrorf(ErrInvalidValue, "invalid defaultAction %q", config.DefaultAction))
+	}
+	for _, rule := range config.Rules {
+		errs.add(validateRule(rule))
+	}
+	for _, bc := range config.AllowedBrowsers {
+		if bc.Regex == "" {
+			errs.add(configErrorf(ErrMissingRegex, "regex or names must be provided for browser: %s", bc.Name))
+		}
+	}
+	for _, spec := range config.BrowserSpecs {
+		if _, err := ParseBrowserSpec(spec); err != nil {
+			errs.add(configErrorf(ErrInvalidValue, "%w", err))
+		}
+	}
+	for _, list := range [][]BrowserConfig{config.AllowedBrowsers, config.BlockedBrowsers, config.DenyBrowsers, config.SoftAllowedBrowsers} {
+		for _, bc := range list {
+			errs.add(bc.validateFlags())
+		}
+	}
+	errs.add(validateEnvPatterns(config))
+	if config.SkipInvalidPatterns {
+		errs.add(reportInvalidPatterns(withExpandedEnv(config)))
+	}
+	for _, bc := range config.BlockedBrowsers {
+		if bc.Regex == "" {
+			errs.add(configErrorf(ErrMissingRegex, "regex or names must be provided for blocked browser: %s", bc.Name))
+		}
+		switch bc.Action {
+		case "", ActionBlock, ActionLogOnly:
+		case ActionRedirect:
+			if bc.RedirectURL == "" {
+				errs.add(configErrorf(ErrMissingSetting, "redirectUrl must be provided for blocked browser: %s", bc.Name))
+			}
+		default:
+			errs.add(configErrorf(ErrInvalidValue, "invalid action %q for blocked browser: %s", bc.Action, bc.Name))
+		}
+	}
+	for _, bc := range config.DenyBrowsers {
+		if bc.Regex == "" {
+			errs.add(configErrorf(ErrMissingRegex, "regex or names must be provided for denied browser: %s", bc.Name))
+		}
+	}
+	for _, bc := range config.SoftAllowedBrowsers {
+		if bc.Regex == "" {
+			errs.add(configErrorf(ErrMissingRegex, "regex or names must be provided for soft allowed browser: %s", bc.Name))
+		}
+	}
+	for _, rule := range config.RateLimits {
+		errs.add(validateRateLimit(rule))
+	}
+	if len(config.AllowedFingerprints) > 0 && config.FingerprintHeader == "" {
+		errs.add(configErrorf(ErrMissingSetting, "fingerprintHeader must be provided when allowedFingerprints is set"))
+	}
+	if config.CheckLanguage && len(config.AllowedLanguages) == 0 {
+		errs.add(configErrorf(ErrMissingSetting, "allowedLanguages must be provided when checkLanguage is enabled"))
+	}
+	if config.CheckOrigin && len(config.AllowedOrigins) == 0 {
+		errs.add(configErrorf(ErrMissingSetting, "allowedOrigins must be provided when checkOrigin is enabled"))
+	}
+	errs.add(validateChallenge(config))
+	for _, rule := range config.NormalizeRules {
+		if rule.Regex == "" {
+			errs.add(configErrorf(ErrMissingRegex, "regex must be provided for every normalize rule"))
+		}
+	}
+	if config.RequireMatchCount < 0 {
+		errs.add(configErrorf(ErrInvalidValue, "requireMatchCount must not be negative"))
+	}
+	if config.MinMatchLength < 0 {
+		errs.add(configErrorf(ErrInvalidValue, "minMatchLength must not be negative"))
+	}
+	if rules := len(config.AllowedBrowsers) + len(config.GlobBrowsers) + len(config.BrowserSpecs); config.RequireMatchCount > 1 && config.RequireMatchCount > rules {
+		errs.add(configErrorf(ErrInvalidValue, "requireMatchCount %d exceeds the number of allowed browser rules (%d)", config.RequireMatchCount, rules))
+	}
+	if _, err := parseIPNets(config.DeniedIPs); err != nil {
+		errs.add(configErrorf(ErrInvalidValue, "invalid deniedIPs: %w", err))
+	}
+	if _, err := parseIPNets(config.TrustedProxies); err != nil {
+		errs.add(configErrorf(ErrInvalidValue, "invalid trustedProxies: %w", err))
+	}
+	if len(config.TrustedProxies) > 0 && !config.TrustForwardedHeader {
+		errs.add(configErrorf(ErrMissingSetting, "trustForwardedHeader must be enabled when trustedProxies is set"))
+	}
+	errs.add(validateClientIPHeaders(config.ClientIPHeaders))
+	if len(config.ClientIPHeaders) > 0 && !config.TrustForwardedHeader {
+		errs.add(configErrorf(ErrMissingSetting, "trustForwardedHeader must be enabled when clientIpHeaders is set"))
+	}
+	if config.MaxBlockBodyBytes < 0 {
+		errs.add(configErrorf(ErrInvalidValue, "maxBlockBodyBytes must not be negative"))
+	}
+	switch config.OSMatchMode {
+	case "", OSMatchAllow, OSMatchBlock:
+	default:
+		errs.add(configErrorf(ErrInvalidValue, "invalid osMatchMode %q", config.OSMatchMode))
+	}
+	errs.add(validateEvaluationOrder(config.EvaluationOrder))
+	errs.add(validateScheme(config))
+	errs.add(validateLogFormat(config.LogFormat))
+	errs.add(validateBypass(config))
+	for _, osName := range config.AllowedOSNames {
+		if _, err := osDetectorPattern(osName); err != nil {
+			errs.add(configErrorf(ErrInvalidValue, "%w", err))
+		}
+	}
+	errs.add(validateRuleConflicts(config))
+	errs.add(validateStatusCodes(config))
+	errs.add(validateScoreRules(config.ScoreRules))
+	errs.add(validateTLSVersion(config))
+	errs.add(validateMatchTimeout(config))
+	errs.add(validateReload(config))
+	errs.add(validateHeaderLists(config))
+	errs.add(validateDistinctBlockAlert(config))
+	errs.add(validateThreatFeed(config))
+	errs.add(validateCorrelation(config))
+	errs.add(validatePlausibility(config))
+	errs.add(validateBlockLog(config))
+	errs.add(validateWebhook(config))
+	errs.add(validateBlockPages(config))
+	errs.add(validateOSVersionRules(config))
+	errs.add(validateBrandVersionRules(config))
+	errs.add(validateValidateEndpoint(config))
+	errs.add(validateMetricsEndpoint(config))
+	errs.add(validateEnforcementDelay(config))
+	errs.add(validateAutoAnchor(config))
+	errs.add(validateCache(config))
+	if config.LogSampleRate < 0 || config.LogSampleRate > 1 {
+		errs.add(configErrorf(ErrInvalidValue, "logSampleRate must be between 0.0 and 1.0, got %v", config.LogSampleRate))
+	}
+	errs.add(validateLogDedupe(config))
+	errs.add(validateRecoverDownstream(config))
+	errs.add(validateLearnMode(config))
+	for _, header := range config.MatchHeaders {
+		if strings.TrimSpace(header) == "" {
+			errs.add(configErrorf(ErrInvalidValue, "matchHeaders must not contain an empty header name"))
+		}
+	}
+	for key := range config.BlockResponseHeaders {
+		if key == "" {
+			errs.add(configErrorf(ErrInvalidValue, "blockResponseHeaders must not contain an empty header name"))
+		}
+	}
+	if len(errs) == 0 {
+		// Policies and the shadow ruleset derive from this config, whose
+		// problems they would repeat
+		errs.add(validatePolicies(config))
+		errs.add(validateShadow(config))
+	}
+	return errs.err()
+}
+
+// reportInvalidPatterns logs the browser and OS patterns that will be skipped
+// because they do not compile, and fails if no valid browser pattern remains.
+func reportInvalidPatterns(config *Config) error {
+	valid := len(config.GlobBrowsers)
+	for _, bc := range config.AllowedBrowsers {
+		if _, err := compileRegexp(bc.pattern()); err != nil {
+			log.Printf("skipping invalid browser regex for %s: %v", bc.Name, err)
+			continue
+		}
+		valid++
+	}
+	for _, osPattern := range config.AllowedOSTypes {
+		if _, err := compileRegexp(osPattern); err != nil {
+			log.Printf("skipping invalid OS regex %q: %v", osPattern, err)
+		}
+	}
+	if valid == 0 && config.DefaultAction == "" && len(config.DenyBrowsers) == 0 && config.DefaultDecision != DefaultDecisionAllow {
+		return configErrorf(ErrNoBrowsers, "no valid allowed browser pattern remains after skipping invalid patterns")
+	}
+	return nil
+}
+
+// New creates and returns a plugin instance.
+func New(ctx context.Context, next http.Handler, config *Config, name string) (http.Handler, error) {
+	b, err := newBlockUserAgents(ctx, next, config, name, true, nil)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// newBlockUserAgents creates a plugin instance. Rebuilds of a reloading
+// instance are not reloadable themselves: the instance they serve for keeps
+// handling SIGHUP and watching rulesUrl. Rules already fetched from the
+// RulesURL, if any, are used instead of fetching them again.
+func newBlockUserAgents(ctx context.Context, next http.Handler, config *Config, name string, reloadable bool, fetched *fetchedRules) (*BlockUserAgents, error) {
+	if next == nil {
+		return nil, fmt.Errorf("%s: next handler must not be nil", name)
+	}
+	originalConfig := config
+	config, rulesValidators, err := withRulesFile(ctx, config, fetched)
+	if err != nil {
+		return nil, err
+	}
+	if config, err = withEmbeddedBaseline(config); err != nil {
+		return nil, err
+	}
+	config = withAutoAnchor(config)
+	if config, err = withBrowserSpecs(config); err != nil {
+		return nil, err
+	}
+	if config, err = withBrowserNames(config); err != nil {
+		return nil, err
+	}
+	baseConfig := config
+	config = withEnabledRules(config)
+	if err := ValidateConfig(config); err != nil {
+		return nil, err
+	}
+	config = withExpandedEnv(config)
+	allowedRules := make([]browserRule, 0)
+	osRegexpsAllow := make([]*regexp.Regexp, 0)
+	osNames := make([]string, 0)
+
+	// Compile regex patterns for allowed browsers
+	for _, bc := range config.AllowedBrowsers {
+		if bc.Regex == "" {
+			continue // Skip if no regex is provided
+		}
+		re, err := compileRegexp(bc.pattern())
+		if err != nil {
+			if config.SkipInvalidPatterns {
+				continue // Already reported by ValidateConfig
+			}
+			return nil, fmt.Errorf("error compiling browser regex for %s: %w", bc.Name, err)
+		}
+		except := make([]*regexp.Regexp, 0, len(bc.Except))
+		for _, pattern := range bc.Except {
+			exRe, err := compileRegexp(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("error compiling exception regex %q for %s: %w", pattern, bc.Name, err)
+			}
+			except = append(except, exRe)
+		}
+		allowedRules = append(allowedRules, browserRule{name: bc.Name, label: bc.Label, re: re, except: except, methods: methodSet(bc.Methods)})
+	}
+
+	// Translate and compile glob patterns for allowed browsers
+	for _, glob := range config.GlobBrowsers {
+		re, err := compileRegexp(globToRegex(glob))
+		if err != nil {
+			return nil, fmt.Errorf("error compiling browser glob %q: %w", glob, err)
+		}
+		allowedRules = append(allowedRules, browserRule{name: glob, re: re})
+	}
+
+	// Compile regex patterns for allowed OS types (if provided)
+	for _, osPattern := range config.AllowedOSTypes {
+		re, err := compileRegexp(osPattern)
+		if err != nil {
+			if config.SkipInvalidPatterns {
+				continue // Already reported by ValidateConfig
+			}
+			return nil, fmt.Errorf("error compiling OS regex %q: %w", osPattern, err)
+		}
+		osRegexpsAllow = append(osRegexpsAllow, re)
+		osNames = append(osNames, osPattern)
+	}
+
+	// Resolve named OS families to their canonical detectors (if provided)
+	for _, osName := range config.AllowedOSNames {
+		pattern, err := osDetectorPattern(osName)
+		if err != nil {
+			return nil, err
+		}
+		re, err := compileRegexp(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("error compiling OS detector for %s: %w", osName, err)
+		}
+		osRegexpsAllow = append(osRegexpsAllow, re)
+		osNames = append(osNames, osName)
+	}
+
+	// Compile blocked browser rules (if provided)
+	blockedRules := make([]browserRule, 0, len(config.BlockedBrowsers))
+	for _, bc := range config.BlockedBrowsers {
+		re, err := compileRegexp(bc.pattern())
+		if err != nil {
+			return nil, fmt.Errorf("error compiling blocked browser regex for %s: %w", bc.Name, err)
+		}
+		action := bc.Action
+		if action == "" {
+			action = ActionBlock
+		}
+		blockedRules = append(blockedRules, browserRule{
+			name:        bc.Name,
+			label:       bc.Label,
+			re:          re,
+			action:      action,
+			redirectURL: bc.RedirectURL,
+			methods:     methodSet(bc.Methods),
+		})
+	}
+
+	// Compile deny rules and their overrides (if provided)
+	denyRules := make([]browserRule, 0, len(config.DenyBrowsers))
+	for _, bc := range config.DenyBrowsers {
+		re, err := compileRegexp(bc.pattern())
+		if err != nil {
+			return nil, fmt.Errorf("error compiling denied browser regex for %s: %w", bc.Name, err)
+		}
+		denyRules = append(denyRules, browserRule{name: bc.Name, label: bc.Label, re: re, methods: methodSet(bc.Methods)})
+	}
+	softRules := make([]browserRule, 0, len(config.SoftAllowedBrowsers))
+	for _, bc := range config.SoftAllowedBrowsers {
+		re, err := compileRegexp(bc.pattern())
+		if err != nil {
+			return nil, fmt.Errorf("error compiling soft allowed browser regex for %s: %w", bc.Name, err)
+		}
+		softRules = append(softRules, browserRule{name: bc.Name, re: re, methods: methodSet(bc.Methods)})
+	}
+	allowOverrides := make([]*regexp.Regexp, 0, len(config.AllowOverrides))
+	for _, pattern := range config.AllowOverrides {
+		re, err := compileRegexp(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("error compiling allow override regex %q: %w", pattern, err)
+		}
+		allowOverrides = append(allowOverrides, re)
+	}
+
+	// Compile allowed origin patterns (if provided)
+	allowedOrigins := make([]*regexp.Regexp, 0, len(config.AllowedOrigins))
+	for _, pattern := range config.AllowedOrigins {
+		re, err := compileRegexp(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("error compiling origin regex %q: %w", pattern, err)
+		}
+		allowedOrigins = append(allowedOrigins, re)
+	}
+
+	// Compile allowed language patterns (if provided)
+	allowedLanguages := make([]*regexp.Regexp, 0, len(config.AllowedLanguages))
+	for _, pattern := range config.AllowedLanguages {
+		re, err := compileRegexp(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("error compiling language regex %q: %w", pattern, err)
+		}
+		allowedLanguages = append(allowedLanguages, re)
+	}
+
+	// Compile challenge patterns (if provided)
+	challengeRegexps := make([]*regexp.Regexp, 0, len(config.ChallengeBrowsers))
+	for _, pattern := range config.ChallengeBrowsers {
+		re, err := compileRegexp(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("error compiling challenge regex %q: %w", pattern, err)
+		}
+		challengeRegexps = append(challengeRegexps, re)
+	}
+	challengeCookieName := config.ChallengeCookieName
+	if challengeCookieName == "" {
+		challengeCookieName = defaultChallengeCookieName
+	}
+
+	// Compile User-Agent normalization rules (if enabled)
+	normalizeRules := make([]replaceRule, 0, len(config.NormalizeRules))
+	if config.NormalizeForward {
+		for _, rule := range config.NormalizeRules {
+			re, err := compileRegexp(rule.Regex)
+			if err != nil {
+				return nil, fmt.Errorf("error compiling normalize regex %q: %w", rule.Regex, err)
+			}
+			normalizeRules = append(normalizeRules, replaceRule{re: re, replacement: rule.Replacement})
+		}
+	}
+
+	// Compile rate limit rules (if provided)
+	rateLimiters := make([]*rateLimiter, 0, len(config.RateLimits))
+	for _, rule := range config.RateLimits {
+		rl, err := newRateLimiter(rule)
+		if err != nil {
+			return nil, err
+		}
+		rateLimiters = append(rateLimiters, rl)
+	}
+
+	orderedRules, err := compileRules(config.Rules)
+	if err != nil {
+		return nil, err
+	}
+
+	scoreRules, err := compileScoreRules(config.ScoreRules)
+	if err != nil {
+		return nil, err
+	}
+
+	var blockTemplate *template.Template
+	if config.BlockResponseTemplate != "" {
+		blockTemplate, err = template.New(name).Parse(config.BlockResponseTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing block response template: %w", err)
+		}
+	}
+
+	deniedIPs, err := parseIPNets(config.DeniedIPs)
+	if err != nil {
+		return nil, fmt.Errorf("invalid deniedIPs: %w", err)
+	}
+	trustedProxies, err := parseIPNets(config.TrustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trustedProxies: %w", err)
+	}
+
+	clientIPHeaders := []string{"X-Forwarded-For"}
+	if len(config.ClientIPHeaders) > 0 {
+		clientIPHeaders = make([]string, 0, len(config.ClientIPHeaders))
+		for _, header := range config.ClientIPHeaders {
+			clientIPHeaders = append(clientIPHeaders, http.CanonicalHeaderKey(header))
+		}
+	}
+
+	matchHeaders := []string{"User-Agent"}
+	if len(config.MatchHeaders) > 0 {
+		matchHeaders = make([]string, 0, len(config.MatchHeaders))
+		for _, header := range config.MatchHeaders {
+			matchHeaders = append(matchHeaders, http.CanonicalHeaderKey(strings.TrimSpace(header)))
+		}
+	}
+
+	allowedFingerprints := make(map[string]struct{}, len(config.AllowedFingerprints))
+	for _, fingerprint := range config.AllowedFingerprints {
+		allowedFingerprints[strings.ToLower(strings.TrimSpace(fingerprint))] = struct{}{}
+	}
+
+	b := &BlockUserAgents{
+		name:              name,
+		next:              next,
+		allowedRules:      allowedRules,
+		osRegexpsAllow:    osRegexpsAllow,
+		osNames:           osNames,
+		osVersionRules:    compileOSVersionRules(config.OSVersionRules),
+		brandVersionRules: compileBrandVersionRules(config.BrandVersionRules),
+		blockedRules:      blockedRules,
+		rateLimiters:      rateLimiters,
+
+		matchAllHeaderValues:  config.MatchAllHeaderValues,
+		matchHeaders:          matchHeaders,
+		decodeUserAgents:      config.DecodeUserAgent,
+		matchCombinedIdentity: config.MatchCombinedIdentity,
+
+		fingerprintHeader:   config.FingerprintHeader,
+		allowedFingerprints: allowedFingerprints,
+
+		blockResponseHeaders: config.BlockResponseHeaders,
+		exposeReasonHeader:   config.ExposeReasonHeader,
+
+		orderedRules:  orderedRules,
+		defaultAction: config.DefaultAction,
+
+		denyRules:      denyRules,
+		allowOverrides: allowOverrides,
+
+		blockTemplate: blockTemplate,
+
+		checkOrigin:    config.CheckOrigin,
+		allowedOrigins: allowedOrigins,
+
+		challengeRegexps:    challengeRegexps,
+		challengeCookieName: challengeCookieName,
+		challengeSecret:     config.ChallengeSecret,
+		challengeTTL:        newChallengeTTL(config),
+
+		logSampler: newLogSampler(config.LogSampleRate),
+		logDeduper: newLogDeduper(config),
+
+		cache: newDecisionCache(config),
+
+		normalizeRules: normalizeRules,
+
+		evaluationOrder: resolveEvaluationOrder(config.EvaluationOrder),
+
+		guard: &subsystemGuard{name: name},
+
+		requireMatchCount: max(config.RequireMatchCount, 1),
+		minMatchLength:    config.MinMatchLength,
+
+		clock: realClock{},
+		stats: newRequestStats(),
+
+		deniedIPs:            deniedIPs,
+		trustForwardedHeader: config.TrustForwardedHeader,
+		trustedProxies:       trustedProxies,
+		clientIPHeaders:      clientIPHeaders,
+
+		osMatchMode: config.OSMatchMode,
+
+		allowGRPC: config.AllowGRPC,
+
+		allowPreflight: config.AllowPreflight,
+
+		recoverDownstream: config.RecoverDownstream,
+		recoverStatusCode: newRecoverStatusCode(config),
+		repanicDownstream: config.RepanicDownstream,
+
+		validatePath:       config.ValidatePath, // Only set with enableValidateEndpoint
+		validateAllowedIPs: newValidateAllowedIPs(config),
+
+		metricsPath:       config.MetricsPath,
+		metricsAllowedIPs: newMetricsAllowedIPs(config),
+
+		softRules: softRules,
+
+		maxBlockBodyBytes: config.MaxBlockBodyBytes,
+
+		compressBlockResponse: config.CompressBlockResponse,
+
+		checkLanguageEnabled: config.CheckLanguage,
+		allowedLanguages:     allowedLanguages,
+
+		requireScheme: config.RequireScheme,
+		schemeAction:  config.SchemeAction,
+
+		logFormat: config.LogFormat,
+		logTiming: config.LogTiming,
+
+		bypassHeader: config.BypassHeaderName,
+		bypassTokens: config.BypassHeaderValues,
+
+		maintenanceMode: config.MaintenanceMode,
+
+		defaultAllow: config.DefaultDecision == DefaultDecisionAllow,
+
+		emptyConfig: emptyConfigBehavior(config),
+
+		annotate: config.Annotate,
+
+		requiredHeaders:  canonicalHeaders(config.RequiredHeaders),
+		forbiddenHeaders: canonicalHeaders(config.ForbiddenHeaders),
+
+		distinct: newDistinctTracker(config),
+
+		blockStatusCode:  config.BlockStatusCode,
+		statusByReason:   config.StatusByReason,
+		messagesByReason: config.MessagesByReason,
+
+		scoreRules:     scoreRules,
+		scoreThreshold: config.ScoreThreshold,
+
+		checkPlausibilityEnabled: config.CheckPlausibility,
+		minPlausibility:          config.MinPlausibility,
+		minUASegments:            config.MinUASegments,
+
+		tlsVersionHeader: config.TLSVersionHeader,
+		blockUnknownTLS:  config.BlockUnknownTLSVersion,
+
+		checkSNIMatchEnabled: config.CheckSNIMatch,
+		sniHeader:            config.SNIHeader,
+		blockMissingSNI:      config.BlockMissingSNI,
+
+		blockHTTP10: config.BlockHTTP10,
+
+		correlationHeader:     config.CorrelationHeader,
+		generateCorrelationID: config.GenerateCorrelationID,
+		tracing:               config.Tracing,
+	}
+	if config.MatchTimeout != "" {
+		b.matchTimeout, _ = time.ParseDuration(config.MatchTimeout)
+	}
+	if config.MinTLSVersion != "" {
+		b.minTLSVersion, _ = parseTLSVersion(config.MinTLSVersion)
+	}
+	if b.tlsVersionHeader == "" {
+		b.tlsVersionHeader = defaultTLSVersionHeader
+	}
+	if b.sniHeader == "" {
+		b.sniHeader = defaultSNIHeader
+	}
+	if b.minPlausibility == 0 {
+		b.minPlausibility = defaultMinPlausibility
+	}
+	if b.correlationHeader == "" {
+		b.correlationHeader = defaultCorrelationHeader
+	}
+	if config.Expvar {
+		b.expvar = newExpvarMetrics(name)
+		b.expvar.registerLabels(ruleLabels(allowedRules, blockedRules, denyRules))
+		if b.distinct != nil {
+			b.expvar.registerDistinct()
+		}
+	}
+	if config.CombinePatterns {
+		b.combinedRegexp, err = combineRules(allowedRules, b.requireMatchCount)
+		if err != nil {
+			return nil, err
+		}
+	}
+	b.singleRule = b.singleRuleFastPath()
+	if b.maxBlockBodyBytes == 0 {
+		b.maxBlockBodyBytes = defaultMaxBlockBodyBytes
+	}
+	if b.blockPage, b.blockPagesByReason, err = loadBlockPages(config, b.maxBlockBodyBytes); err != nil {
+		return nil, err
+	}
+	for _, rule := range orderedRules {
+		if rule.target == TargetPath {
+			b.hasPathRules = true
+		}
+	}
+	if b.emptyConfig != "" {
+		log.Printf("%s: no browser rule configured, applying emptyConfigBehavior %q to every request", name, b.emptyConfig)
+	}
+	if config.LogSampleRate < 1 {
+		log.Printf("%s: logging %.0f%% of blocked requests after the first of each reason", name, config.LogSampleRate*100)
+	}
+	if b.logDeduper != nil {
+		log.Printf("%s: logging at most %d blocked requests per reason every %s", name, b.logDeduper.max, b.logDeduper.window)
+	}
+
+	if err := b.newPolicies(ctx, next, baseConfig); err != nil {
+		return nil, err
+	}
+	if err := b.newShadow(ctx, next, baseConfig); err != nil {
+		return nil, err
+	}
+
+	if err := b.selfTest(ctx, config.SelfTestUserAgents); err != nil {
+		return nil, err
+	}
+	if b.blockLog, err = newBlockLogRef(ctx, config); err != nil {
+		return nil, err
+	}
+	b.webhook = newWebhookSink(ctx, config)
+	b.threatFeed = newThreatFeed(ctx, config, name)
+	b.learner = newLearner(ctx, config, name)
+	for _, policy := range b.policies {
+		policy.threatFeed = b.threatFeed // Fetched once for all policies
+		policy.blockLog = b.blockLog     // One writer per file
+		policy.webhook = b.webhook
+		policy.stats = b.stats // Counted with the top-level requests
+	}
+	b.ctx = ctx
+	b.rulesValidators = rulesValidators
+	if reloadable && config.ReloadOnSignal {
+		b.reloadConfig = originalConfig
+		registerReload(b)
+		b.unregisterReloadOnDone(ctx)
+	}
+	if reloadable && config.RulesURL != "" && config.ReloadInterval != "" {
+		b.reloadConfig = originalConfig
+		b.rulesWatch = b.watchRulesURL(ctx, config)
+	}
+	if reloadable {
+		b.startWarmup(config) // Rebuilds inherit the delay of the instance they serve for
+	}
+	return b, nil
+}
+
+// NewWithHook creates a plugin instance like New, with a decision hook that
+// can override the built-in decision of every request. Traefik's configuration
+// cannot carry functions, so this is meant for embedding the plugin in Go code.
+func NewWithHook(ctx context.Context, next http.Handler, config *Config, name string,
+	hook func(req *http.Request, defaultAllow bool) (allow bool, reason string),
+) (http.Handler, error) {
+	handler, err := New(ctx, next, config, name)
+	if err != nil {
+		return nil, err
+	}
+	handler.(*BlockUserAgents).DecisionHook = hook
+	return handler, nil
+}
+
+// selfTest evaluates User-Agents that are expected to be allowed and fails if
+// any of them would be blocked, turning a broken allowlist into a startup error.
+func (b *BlockUserAgents) selfTest(ctx context.Context, userAgents []string) error {
+	failures := make([]string, 0)
+	for _, userAgent := range userAgents {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("self-test aborted: %w", err)
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, "/", nil)
+		if err != nil {
+			return fmt.Errorf("error building self-test request: %w", err)
+		}
+		req.Header.Set("User-Agent", userAgent)
+		if d := b.evaluate(req); !d.allowed {
+			failures = append(failures, fmt.Sprintf("%q (%s)", userAgent, d.reason))
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("self-test failed, expected User-Agents would be blocked: %s", strings.Join(failures, ", "))
+	}
+	return nil
+}
+
+// globToRegex translates a shell-glob pattern into an anchored regex.
+// '*' matches any run of characters, '?' matches a single character and
+// everything else is matched literally.
+func globToRegex(glob string) string {
+	var sb strings.Builder
+	sb.WriteString("^")
+	for _, r := range glob {
+		switch r {
+		case '*':
+			sb.WriteString(".*")
+		case '?':
+			sb.WriteString(".")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	sb.WriteString("$")
+	return sb.String()
+}
+
+// decision is the outcome of evaluating a request against the rules.
+type decision struct {
+	allowed     bool
+	reason      string // Block reason, empty when allowed
+	status      int    // Response status when not allowed
+	redirectURL string // Redirect target when status is a redirect
+	logOnly     string // Reason of a matching log-only rule, logged even when allowed
+	challenge   bool   // Respond with the JavaScript challenge instead of blocking
+	softMiss    bool   // Allowed, but missed the soft allowlist
+	label       string // Label of the browser rule that decided, counted in the metrics
+
+	elapsed time.Duration // Time spent evaluating, measured when logTiming is enabled
+}
+
+// allow returns an allowing decision.
+func allow() decision {
+	return decision{allowed: true}
+}
+
+// block returns a blocking decision with the default status.
+func block(reason string) decision {
+	return decision{reason: reason, status: http.StatusForbidden}
+}
+
+// Dimensions of the evaluation pipeline, see Config.EvaluationOrder.
+const (
+	DimensionUserAgent    = "ua"           // Missing User-Agent
+	DimensionBot          = "bot"          // Blocked and denied browsers, challenges
+	DimensionBrowser      = "browser"      // Allowed browsers and their exceptions
+	DimensionOS           = "os"           // Allowed OS types and OS version rules
+	DimensionFingerprint  = "fingerprint"  // Allowed TLS fingerprints
+	DimensionOrigin       = "origin"       // Allowed origins
+	DimensionLanguage     = "language"     // Allowed Accept-Language values
+	DimensionScore        = "score"        // Score rules and threshold
+	DimensionHeaders      = "headers"      // Required and forbidden headers
+	DimensionPlausibility = "plausibility" // Structural plausibility and segment count of the User-Agent
+)
+
+// defaultEvaluationOrder is the order dimensions are evaluated in by default.
+var defaultEvaluationOrder = []string{
+	DimensionUserAgent,
+	DimensionBot,
+	DimensionBrowser,
+	DimensionOS,
+	DimensionFingerprint,
+	DimensionOrigin,
+	DimensionLanguage,
+	DimensionScore,
+	DimensionHeaders,
+	DimensionPlausibility,
+}
+
+// validateEvaluationOrder checks that the listed dimensions are known and unique.
+func validateEvaluationOrder(order []string) error {
+	var errs ConfigErrors
+	seen := make(map[string]bool, len(order))
+	for _, dimension := range order {
+		if !slices.Contains(defaultEvaluationOrder, dimension) {
+			errs.add(configErrorf(ErrInvalidValue, "unknown evaluation dimension %q", dimension))
+		} else if seen[dimension] {
+			errs.add(configErrorf(ErrInvalidValue, "duplicate evaluation dimension %q", dimension))
+		}
+		seen[dimension] = true
+	}
+	return errs.err()
 }
 
-// Config holds the plugin configuration.
-type Config struct {
-	AllowedBrowsers []BrowserConfig `json:"allowedBrowsers,omitempty"` // List of browser configs
-	AllowedOSTypes  []string        `json:"allowedOSTypes,omitempty"`  // Optional: List of allowed OS regex patterns
+// resolveEvaluationOrder returns the configured order followed by any
+// dimensions it leaves out, so omitting a dimension never disables its check.
+func resolveEvaluationOrder(order []string) []string {
+	resolved := append([]string{}, order...)
+	for _, dimension := range defaultEvaluationOrder {
+		if !slices.Contains(resolved, dimension) {
+			resolved = append(resolved, dimension)
+		}
+	}
+	return resolved
 }
 
-// CreateConfig creates and initializes the plugin configuration.
-func CreateConfig() *Config {
-	return &Config{
-		AllowedBrowsers: []BrowserConfig{},
-		AllowedOSTypes:  []string{},
+// evaluation carries per-request state through the evaluation pipeline.
+type evaluation struct {
+	req        *http.Request
+	userAgents []string
+	logOnly    string // Reason of a matching log-only rule
+	label      string // Label of the allowed browser rule that matched
+}
+
+// evaluate checks the request against the configured rules, walking the
+// dimensions in the configured order until one of them decides.
+// It has no side effects, so it can be used outside of ServeHTTP.
+func (b *BlockUserAgents) evaluate(req *http.Request) decision {
+	switch b.emptyConfig {
+	case EmptyConfigAllowAll:
+		return allow()
+	case EmptyConfigBlockAll:
+		return block("Empty Config")
+	}
+	if b.singleRule != nil {
+		return b.evaluateSingleRule(req)
+	}
+	e := &evaluation{req: req, userAgents: b.userAgentValues(req)}
+	d := b.evaluateRules(e)
+	if d.allowed && len(b.softRules) > 0 {
+		d.softMiss = !b.matchesSoftRules(e)
 	}
+	return d
 }
 
-// BlockUserAgents struct.
-type BlockUserAgents struct {
-	name           string
-	next           http.Handler
-	regexpsAllow   []*regexp.Regexp // Browser regex patterns
-	osRegexpsAllow []*regexp.Regexp // OS regex patterns (optional)
+// matchesSoftRules reports whether the request matches one of the soft allowlist rules.
+func (b *BlockUserAgents) matchesSoftRules(e *evaluation) bool {
+	for _, rule := range b.softRules {
+		if rule.appliesTo(e.req.Method) && matchesAny(rule.re, e.userAgents) {
+			return true
+		}
+	}
+	return false
 }
 
-// BlockUserAgentsMessage struct for logging blocked requests.
-type BlockUserAgentsMessage struct {
-	UserAgent  string `json:"user-agent"`
-	RemoteAddr string `json:"ip"`
-	Host       string `json:"host"`
-	RequestURI string `json:"uri"`
+// evaluateRules runs the hard checks: the ordered rules, then each dimension.
+func (b *BlockUserAgents) evaluateRules(e *evaluation) decision {
+	req := e.req
+
+	// Ordered rules take precedence over the allowlist when they decide
+	if d, ok := b.evaluateOrderedRules(req, e.userAgents); ok {
+		return d
+	}
+
+	for _, dimension := range b.evaluationOrder {
+		var d *decision
+		switch dimension {
+		case DimensionUserAgent:
+			d = b.checkUserAgent(e)
+		case DimensionBot:
+			d = b.checkBot(e)
+		case DimensionBrowser:
+			if d = b.checkBrowser(e); d == nil {
+				d = b.checkBrandVersion(e)
+			}
+		case DimensionOS:
+			if d = b.checkOS(e); d == nil {
+				d = b.checkOSVersion(e)
+			}
+		case DimensionFingerprint:
+			d = b.checkFingerprint(e)
+		case DimensionOrigin:
+			d = b.checkAllowedOrigin(e)
+		case DimensionLanguage:
+			d = b.checkLanguage(e)
+		case DimensionScore:
+			d = b.checkScore(e)
+		case DimensionHeaders:
+			d = b.checkHeaders(e)
+		case DimensionPlausibility:
+			if d = b.checkSegments(e); d == nil {
+				d = b.checkPlausibility(e)
+			}
+		}
+		if d != nil {
+			d.logOnly = e.logOnly
+			return *d
+		}
+	}
+	return decision{allowed: true, logOnly: e.logOnly, label: e.label}
 }
 
-// ValidateConfig validates the plugin configuration.
-func ValidateConfig(config *Config) error {
-	if len(config.AllowedBrowsers) == 0 {
-		return fmt.Errorf("at least one allowed browser must be specified")
+// blockDecision returns a pointer to a blocking decision for use by the checks.
+func blockDecision(reason string) *decision {
+	d := block(reason)
+	return &d
+}
+
+// checkUserAgent blocks requests without a User-Agent.
+func (b *BlockUserAgents) checkUserAgent(e *evaluation) *decision {
+	if len(e.userAgents) == 0 {
+		return blockDecision("No User-Agent")
 	}
-	for _, bc := range config.AllowedBrowsers {
-		if bc.Regex == "" {
-			return fmt.Errorf("regex must be provided for browser: %s", bc.Name)
+	return nil
+}
+
+// checkBot applies the blocked and denied browser rules and the challenge.
+func (b *BlockUserAgents) checkBot(e *evaluation) *decision {
+	// Check blocked browser rules, applying the strictest matching action
+	if rule := b.matchBlockedRule(e.req.Method, e.userAgents); rule != nil {
+		switch rule.action {
+		case ActionBlock:
+			d := blockDecision("Blocked Browser: " + rule.name)
+			d.label = rule.label
+			return d
+		case ActionRedirect:
+			return &decision{reason: "Redirected Browser: " + rule.name, status: http.StatusFound, redirectURL: rule.redirectURL, label: rule.label}
+		case ActionLogOnly:
+			e.logOnly = "Log-Only Browser: " + rule.name
+		}
+	}
+
+	// Check deny rules unless an override exempts the User-Agent
+	if rule := b.matchDenyRule(e.req.Method, e.userAgents); rule != nil {
+		d := blockDecision("Denied Browser: " + rule.name)
+		d.label = rule.label
+		return d
+	}
+
+	// Challenge borderline User-Agents, allowing them once the challenge is passed
+	for _, re := range b.challengeRegexps {
+		if !matchesAny(re, e.userAgents) {
+			continue
 		}
+		if b.challengePassed(e.req) {
+			return &decision{allowed: true}
+		}
+		return &decision{reason: "Challenged", status: http.StatusOK, challenge: true}
 	}
 	return nil
 }
 
-// New creates and returns a plugin instance.
-func New(_ context.Context, next http.Handler, config *Config, name string) (http.Handler, error) {
-	if err := ValidateConfig(config); err != nil {
-		return nil, err
+// matchDenyRule returns the first deny rule matching the User-Agent, or nil
+// when none matches or an allow override exempts it. Overrides beat deny rules.
+func (b *BlockUserAgents) matchDenyRule(method string, userAgents []string) *browserRule {
+	if len(b.denyRules) == 0 {
+		return nil
 	}
-	regexpsAllow := make([]*regexp.Regexp, 0)
-	osRegexpsAllow := make([]*regexp.Regexp, 0)
+	for _, re := range b.allowOverrides {
+		if matchesAny(re, userAgents) {
+			return nil
+		}
+	}
+	for i := range b.denyRules {
+		rule := &b.denyRules[i]
+		if rule.appliesTo(method) && matchesAny(rule.re, userAgents) {
+			return rule
+		}
+	}
+	return nil
+}
 
-	// Compile regex patterns for allowed browsers
-	for _, bc := range config.AllowedBrowsers {
-		if bc.Regex == "" {
-			continue // Skip if no regex is provided
+// checkBrowser requires matches against requireMatchCount allowed browser rules.
+// Without allowed browsers (a deny-list policy) or for allowed gRPC calls the check is skipped.
+func (b *BlockUserAgents) checkBrowser(e *evaluation) *decision {
+	if len(b.allowedRules) == 0 || (b.allowGRPC && isGRPC(e.req)) {
+		return nil
+	}
+	identities := b.browserIdentities(e)
+	if b.combinedRegexp != nil {
+		if b.matchesBrowser(b.combinedRegexp, identities) || b.defaultAllow {
+			return nil
 		}
-		re, err := regexp.Compile(bc.Regex)
-		if err != nil {
-			return nil, fmt.Errorf("error compiling browser regex for %s: %w", bc.Name, err)
+		return blockDecision("Unsupported Browser")
+	}
+	matches := 0
+	for _, rule := range b.allowedRules {
+		if !rule.appliesTo(e.req.Method) || !b.matchesBrowser(rule.re, identities) {
+			continue
+		}
+		// Exceptions are only evaluated once the positive match succeeded
+		for _, exRe := range rule.except {
+			if matchesAny(exRe, identities) {
+				d := blockDecision("Blocked Exception")
+				d.label = rule.label
+				return d
+			}
+		}
+		if matches == 0 {
+			e.label = rule.label // The first matching rule is credited with the allow
 		}
-		regexpsAllow = append(regexpsAllow, re)
+		matches++
+		if matches >= b.requireMatchCount {
+			return nil
+		}
+	}
+	if b.defaultAllow {
+		return nil
 	}
+	return blockDecision("Unsupported Browser")
+}
 
-	// Compile regex patterns for allowed OS types (if provided)
-	for _, osPattern := range config.AllowedOSTypes {
-		re, err := regexp.Compile(osPattern)
-		if err != nil {
-			return nil, fmt.Errorf("error compiling OS regex %q: %w", osPattern, err)
+// combineRules compiles the allowed browser rules into a single alternation,
+// which is faster to match than each rule in turn on large allowlists. It
+// returns nil when the rules need per-rule evaluation: with exceptions,
+// method scopes, labels or a match count above one.
+func combineRules(rules []browserRule, requireMatchCount int) (*regexp.Regexp, error) {
+	if len(rules) == 0 || requireMatchCount > 1 {
+		return nil, nil
+	}
+	patterns := make([]string, 0, len(rules))
+	for _, rule := range rules {
+		if len(rule.except) > 0 || len(rule.methods) > 0 || rule.label != "" {
+			return nil, nil
+		}
+		patterns = append(patterns, "(?:"+rule.re.String()+")")
+	}
+	re, err := compileRegexp(strings.Join(patterns, "|"))
+	if err != nil {
+		return nil, fmt.Errorf("error compiling combined browser regex: %w", err)
+	}
+	return re, nil
+}
+
+// checkOS requires a match against the OS patterns, if any. In block mode
+// the patterns are a denylist and a match blocks the request instead.
+func (b *BlockUserAgents) checkOS(e *evaluation) *decision {
+	if len(b.osRegexpsAllow) == 0 {
+		return nil
+	}
+	for _, re := range b.osRegexpsAllow {
+		if matchesAny(re, e.userAgents) {
+			if b.osMatchMode == OSMatchBlock {
+				return blockDecision("Banned OS")
+			}
+			return nil
+		}
+	}
+	if b.osMatchMode == OSMatchBlock {
+		return nil
+	}
+	return blockDecision("Unsupported OS")
+}
+
+// checkFingerprint checks the forwarded TLS fingerprint when one is present.
+func (b *BlockUserAgents) checkFingerprint(e *evaluation) *decision {
+	if len(b.allowedFingerprints) == 0 {
+		return nil
+	}
+	fingerprint := e.req.Header.Get(b.fingerprintHeader)
+	if fingerprint == "" {
+		return nil
+	}
+	if _, ok := b.allowedFingerprints[strings.ToLower(strings.TrimSpace(fingerprint))]; !ok {
+		return blockDecision("Unsupported Fingerprint")
+	}
+	return nil
+}
+
+// checkAllowedOrigin checks the Origin, or the Referer when no Origin is sent.
+func (b *BlockUserAgents) checkAllowedOrigin(e *evaluation) *decision {
+	if !b.checkOrigin {
+		return nil
+	}
+	origin := e.req.Header.Get("Origin")
+	if origin == "" {
+		origin = e.req.Referer()
+	}
+	if origin != "" && !b.originAllowed(origin) {
+		return blockDecision("Disallowed Origin")
+	}
+	return nil
+}
+
+// checkLanguage requires the Accept-Language header to match an allowed pattern.
+func (b *BlockUserAgents) checkLanguage(e *evaluation) *decision {
+	if !b.checkLanguageEnabled {
+		return nil
+	}
+	language := e.req.Header.Get("Accept-Language")
+	if language == "" {
+		return blockDecision("No Accept-Language")
+	}
+	for _, re := range b.allowedLanguages {
+		if re.MatchString(language) {
+			return nil
 		}
-		osRegexpsAllow = append(osRegexpsAllow, re)
 	}
+	return blockDecision("Unsupported Language")
+}
 
-	return &BlockUserAgents{
-		name:           name,
-		next:           next,
-		regexpsAllow:   regexpsAllow,
-		osRegexpsAllow: osRegexpsAllow,
-	}, nil
+// originAllowed reports whether origin matches one of the allowed origin patterns.
+func (b *BlockUserAgents) originAllowed(origin string) bool {
+	for _, re := range b.allowedOrigins {
+		if re.MatchString(origin) {
+			return true
+		}
+	}
+	return false
 }
 
 // ServeHTTP handles the HTTP request.
 func (b *BlockUserAgents) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	if res == nil {
+		log.Printf("%s: nil response writer, dropping request", b.name)
+		return
+	}
 	if req == nil {
 		res.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
-	userAgent := req.UserAgent()
-	if userAgent == "" {
-		b.logBlockedRequest(req, "No User-Agent")
-		res.WriteHeader(http.StatusForbidden)
+	// Continue the trace of the request, including through the instance the
+	// request is handed to
+	if span, traced := b.startSpan(req); span != nil {
+		req = traced
+		defer b.endSpan(span)
+	}
+
+	// Answer the validation endpoint before any rule applies
+	if b.isValidateRequest(req) {
+		b.serveValidate(res, req)
+		return
+	}
+
+	// Answer the metrics endpoint, counting requests of every ruleset
+	if b.isMetricsRequest(req) {
+		b.serveMetrics(res, req)
+		return
+	}
+
+	// Hand the request to the ruleset reloaded on SIGHUP, if any
+	if reloaded := b.reloaded.Load(); reloaded != nil {
+		reloaded.ServeHTTP(res, req)
+		return
+	}
+
+	// Hand the request to the policy selected by its host, if any; learn
+	// mode covers every host
+	if policy := b.policyFor(req); policy != nil && b.learner == nil {
+		policy.ServeHTTP(res, req)
+		return
+	}
+
+	// Never forward annotations supplied by the client
+	if b.annotate {
+		req.Header.Del(annotationBrowserHeader)
+		req.Header.Del(annotationOSHeader)
+	}
+
+	// Tag the request with a correlation ID before anything is logged
+	b.correlate(res, req)
+
+	// Forward requests presenting a valid bypass token without any check
+	if b.bypassed(req) {
+		b.forward(res, req)
+		return
+	}
+
+	// Turn everyone else away while in maintenance
+	if b.maintenanceMode {
+		b.respondBlocked(res, req, decision{reason: "Maintenance", status: http.StatusServiceUnavailable})
+		return
+	}
+
+	// Learn from every other request without checking it
+	if b.learner != nil {
+		for _, userAgent := range b.userAgentValues(req) {
+			b.guard.run("learn mode", func() { b.learner.observe(userAgent) })
+		}
+		b.forward(res, req)
+		return
+	}
+
+	// Block denied client IPs regardless of their User-Agent
+	if len(b.deniedIPs) > 0 && containsIP(b.deniedIPs, b.clientIP(req)) {
+		b.respondBlocked(res, req, block("Denied IP"))
+		return
+	}
+
+	// Enforce the required scheme regardless of the User-Agent
+	if d := b.checkScheme(req); d != nil {
+		b.respondBlocked(res, req, *d)
+		return
+	}
+
+	// Enforce the minimum TLS version regardless of the User-Agent
+	if d := b.checkTLSVersion(req); d != nil {
+		b.respondBlocked(res, req, *d)
+		return
+	}
+
+	// Block requests for another host than the TLS connection was opened for
+	if d := b.checkSNIMatch(req); d != nil {
+		b.respondBlocked(res, req, *d)
+		return
+	}
+
+	// Block legacy HTTP versions regardless of the User-Agent
+	if d := b.checkHTTPVersion(req); d != nil {
+		b.respondBlocked(res, req, *d)
+		return
+	}
+
+	// Let CORS preflights through so the actual request gets checked instead
+	if b.allowPreflight && isPreflight(req) {
+		b.forward(res, req)
 		return
 	}
 
-	// Check browser patterns
-	browserMatch := false
-	for _, re := range b.regexpsAllow {
-		if re.MatchString(userAgent) {
-			browserMatch = true
-			break
+	// Block User-Agents listed by the threat feed regardless of the allowlist
+	if d := b.checkThreatFeed(req); d != nil {
+		if !b.warmingUp() {
+			b.respondBlocked(res, req, *d)
+			return
 		}
+		b.logWouldBlock(req, *d)
+	}
+
+	var start time.Time
+	if b.logTiming {
+		start = b.clock.Now()
+	}
+	d := b.cachedEvaluate(req)
+	b.guard.run("shadow ruleset", func() { b.compareShadow(req, d) })
+	if b.DecisionHook != nil {
+		d = b.applyDecisionHook(req, d)
 	}
-	if !browserMatch {
-		b.logBlockedRequest(req, "Unsupported Browser")
-		res.WriteHeader(http.StatusForbidden)
+	if b.logTiming {
+		d.elapsed = b.clock.Now().Sub(start)
+	}
+	if d.logOnly != "" {
+		b.logLogOnly(req, d.logOnly, d.elapsed)
+	}
+	if (d.challenge || !d.allowed) && b.warmingUp() {
+		b.logWouldBlock(req, d)
+		d = decision{allowed: true, elapsed: d.elapsed}
+	}
+	if d.challenge {
+		recordSpan(req, SpanChallenged, d)
+		b.writeChallenge(res, req)
+		return
+	}
+	if !d.allowed {
+		b.respondBlocked(res, req, d)
 		return
 	}
+	if d.softMiss {
+		b.logSoftMiss(req, d.elapsed)
+	}
 
-	// Check OS patterns if provided
-	if len(b.osRegexpsAllow) > 0 {
-		osMatch := false
-		for _, re := range b.osRegexpsAllow {
-			if re.MatchString(userAgent) {
-				osMatch = true
-				break
-			}
+	// Enforce rate limits for matching User-Agents
+	userAgents := b.userAgentValues(req)
+	for _, rl := range b.rateLimiters {
+		if !matchesAny(rl.re, userAgents) {
+			continue
 		}
-		if !osMatch {
-			b.logBlockedRequest(req, "Unsupported OS")
-			res.WriteHeader(http.StatusForbidden)
+		ok, wait := true, time.Duration(0)
+		b.guard.run("rate limiter", func() { ok, wait = rl.allow(b.clientIP(req), b.clock.Now()) })
+		if !ok {
+			res.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			b.respondBlocked(res, req, decision{reason: "Rate Limited: " + rl.name, status: http.StatusTooManyRequests})
 			return
 		}
 	}
 
+	if b.annotate {
+		b.annotateRequest(req)
+	}
+
+	// Rewrite the forwarded User-Agent; the decision was made on the original
+	if len(b.normalizeRules) > 0 {
+		userAgent := req.UserAgent()
+		for _, rule := range b.normalizeRules {
+			userAgent = rule.re.ReplaceAllString(userAgent, rule.replacement)
+		}
+		req.Header.Set("User-Agent", userAgent)
+	}
+
+	recordSpan(req, SpanAllowed, d)
+	b.guard.run("expvar", func() { b.expvar.allowed() })
+	b.stats.allowedRequest()
+	if d.label != "" {
+		b.guard.run("expvar", func() { b.expvar.labeled(d.label, true) })
+		b.stats.labeled(d.label, true)
+	}
+	b.forward(res, req)
+}
+
+// forward passes the request on to the next handler, recovering its panics
+// when RecoverDownstream is set.
+func (b *BlockUserAgents) forward(res http.ResponseWriter, req *http.Request) {
+	if b.next == nil {
+		log.Printf("%s: no next handler configured, cannot forward request", b.name)
+		res.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if b.recoverDownstream {
+		defer b.recoverNext(res, req)
+	}
 	b.next.ServeHTTP(res, req)
 }
 
-// logBlockedRequest logs details of a blocked request.
-func (b *BlockUserAgents) logBlockedRequest(req *http.Request, reason string) {
+// applyDecisionHook lets the decision hook override the built-in decision.
+func (b *BlockUserAgents) applyDecisionHook(req *http.Request, d decision) decision {
+	allowed, reason := b.DecisionHook(req, d.allowed)
+	switch {
+	case allowed && !d.allowed:
+		return decision{allowed: true, logOnly: d.logOnly, softMiss: d.softMiss}
+	case !allowed && d.allowed:
+		if reason == "" {
+			reason = "Decision Hook"
+		}
+		return decision{reason: reason, status: http.StatusForbidden, logOnly: d.logOnly}
+	}
+	return d
+}
+
+// respondBlocked logs a blocked request and writes the block response.
+// Configured response headers are set before the status is written.
+func (b *BlockUserAgents) respondBlocked(res http.ResponseWriter, req *http.Request, d decision) {
+	if d.status == http.StatusForbidden {
+		d.status = b.blockStatus(d.reason)
+	}
+	recordSpan(req, SpanBlocked, d)
+	b.logBlockedRequest(req, d.reason, d.elapsed)
+	category := reasonCategory(d.reason)
+	b.guard.run("expvar", func() { b.expvar.blockedRequest(category) })
+	b.stats.blockedRequest(category)
+	if d.label != "" {
+		b.guard.run("expvar", func() { b.expvar.labeled(d.label, false) })
+		b.stats.labeled(d.label, false)
+	}
+	b.guard.run("distinct alert", func() { b.recordDistinctBlock(req.UserAgent()) })
+
+	for key, value := range b.blockResponseHeaders {
+		res.Header().Set(key, value)
+	}
+	if b.exposeReasonHeader {
+		res.Header().Set("X-Block-Reason", d.reason)
+	}
+
+	if isGRPC(req) {
+		writeGRPCBlock(res, d)
+		return
+	}
+	if d.redirectURL != "" {
+		http.Redirect(res, req, d.redirectURL, d.status)
+		return
+	}
+
+	var body []byte
+	contentType := "text/html; charset=utf-8"
+	if page := b.blockPageFor(d.reason); page != nil {
+		body, contentType = page.content, page.contentType
+	} else {
+		body = b.renderBlockBody(req, d.reason)
+		if b.blockTemplate == nil {
+			contentType = "text/plain; charset=utf-8"
+		}
+	}
+	if body == nil {
+		res.WriteHeader(d.status)
+		return
+	}
+	if len(body) > b.maxBlockBodyBytes {
+		body = body[:b.maxBlockBodyBytes]
+	}
+	if res.Header().Get("Content-Type") == "" {
+		res.Header().Set("Content-Type", contentType)
+	}
+	if b.compressBlockResponse && len(body) >= minCompressBytes {
+		res.Header().Add("Vary", "Accept-Encoding")
+		if acceptsGzip(req.Header.Get("Accept-Encoding")) {
+			if compressed := gzipBody(body); compressed != nil {
+				body = compressed
+				res.Header().Set("Content-Encoding", "gzip")
+			}
+		}
+	}
+	res.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	res.WriteHeader(d.status)
+	if req.Method == http.MethodHead {
+		return // HEAD responses carry the headers of the body but not the body
+	}
+	n, err := res.Write(body)
+	b.blockBodyBytes.Add(uint64(n))
+	if err != nil {
+		log.Printf("%s: error writing block response: %v", b.name, err)
+	}
+}
+
+// BlockBodyBytes returns the total number of block response body bytes written.
+func (b *BlockUserAgents) BlockBodyBytes() uint64 {
+	return b.blockBodyBytes.Load()
+}
+
+// renderBlockBody renders the block response template, returning nil when no
+// template is configured or rendering fails so the plain response is used.
+func (b *BlockUserAgents) renderBlockBody(req *http.Request, reason string) []byte {
+	message := b.blockMessage(reason)
+	if b.blockTemplate == nil {
+		if message == "" {
+			return nil
+		}
+		return []byte(message)
+	}
+	var buf bytes.Buffer
+	data := BlockTemplateData{Reason: reason, Message: message, UserAgent: req.UserAgent(), Host: req.Host}
+	if err := b.blockTemplate.Execute(&buf, data); err != nil {
+		log.Printf("%s: error rendering block response template: %v", b.name, err)
+		return nil
+	}
+	return buf.Bytes()
+}
+
+// userAgentValues returns the non-empty User-Agent values to match against.
+// All values of a repeated header are returned when matchAllHeaderValues is set.
+func (b *BlockUserAgents) userAgentValues(req *http.Request) []string {
+	_, values := b.matchedHeader(req)
+	return values
+}
+
+// matchedHeader returns the first of the match headers carrying a non-empty
+// value, and its values. It returns no values when all of them are empty.
+func (b *BlockUserAgents) matchedHeader(req *http.Request) (string, []string) {
+	for _, header := range b.matchHeaders {
+		if !b.matchAllHeaderValues {
+			if value := req.Header.Get(header); value != "" {
+				return header, []string{b.decodeUserAgent(value)}
+			}
+			continue
+		}
+		values := make([]string, 0, 1)
+		for _, value := range req.Header.Values(header) {
+			if value != "" {
+				values = append(values, b.decodeUserAgent(value))
+			}
+		}
+		if len(values) > 0 {
+			return header, values
+		}
+	}
+	return "", nil
+}
+
+// decodeUserAgent URL-decodes and HTML-unescapes a User-Agent when decoding
+// is enabled. A value that fails to decode is returned unchanged.
+func (b *BlockUserAgents) decodeUserAgent(value string) string {
+	if !b.decodeUserAgents {
+		return value
+	}
+	if strings.Contains(value, "%") {
+		if decoded, err := url.PathUnescape(value); err == nil {
+			value = decoded
+		}
+	}
+	if strings.Contains(value, "&") {
+		value = html.UnescapeString(value)
+	}
+	return value
+}
+
+// matchesBrowser reports whether an allowed browser pattern matches at least
+// one of the values. With minMatchLength set, matches of fewer bytes do not
+// count; every match of a value is tried, since the leftmost one may be
+// short while a later one is long enough.
+func (b *BlockUserAgents) matchesBrowser(re *regexp.Regexp, values []string) bool {
+	if b.minMatchLength == 0 {
+		return matchesAny(re, values)
+	}
+	for _, value := range values {
+		for _, loc := range re.FindAllStringIndex(value, -1) {
+			if loc[1]-loc[0] >= b.minMatchLength {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchesAny reports whether re matches at least one of the values.
+func matchesAny(re *regexp.Regexp, values []string) bool {
+	for _, value := range values {
+		if re.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchBlockedRule returns the matching blocked rule with the strictest action.
+// Ties are resolved in favor of the rule listed first.
+func (b *BlockUserAgents) matchBlockedRule(method string, userAgents []string) *browserRule {
+	var matched *browserRule
+	for i := range b.blockedRules {
+		rule := &b.blockedRules[i]
+		if !rule.appliesTo(method) || !matchesAny(rule.re, userAgents) {
+			continue
+		}
+		if matched == nil || actionPrecedence[rule.action] > actionPrecedence[matched.action] {
+			matched = rule
+		}
+	}
+	return matched
+}
+
+// newMessage builds the log message describing a request.
+func newMessage(req *http.Request) *BlockUserAgentsMessage {
 	message := &BlockUserAgentsMessage{
 		UserAgent:  req.UserAgent(),
 		RemoteAddr: req.RemoteAddr,
 		Host:       req.Host,
 		RequestURI: req.RequestURI,
 	}
-	jsonMessage, err := json.Marshal(message)
-	if err == nil {
-		log.Printf("%s: Blocked (%s) - %s", b.name, reason, jsonMessage)
-	} else {
-		log.Printf("%s: Blocked (%s) - %s", b.name, reason, req.UserAgent())
+	message.ParsedBrowser, message.ParsedOS = parseUserAgent(message.UserAgent)
+	return message
+}
+
+// logBlockedRequest logs details of a blocked request, and records it in the
+// block log file when configured. Only a sample of the events is logged when
+// a log sample rate is configured, and at most LogMaxPerReason per reason
+// and window when set; the block log file records all of them.
+func (b *BlockUserAgents) logBlockedRequest(req *http.Request, reason string, elapsed time.Duration) {
+	b.recordEvent(req, "Blocked", reason, elapsed)
+}
+
+// logLogOnly logs a request matching a log-only rule, which is not blocked,
+// like a blocked request but with its own event.
+func (b *BlockUserAgents) logLogOnly(req *http.Request, reason string, elapsed time.Duration) {
+	b.recordEvent(req, "LogOnly", reason, elapsed)
+}
+
+// recordEvent sends a request event to the block log file and the webhook,
+// then logs it subject to sampling and the per-reason cap.
+func (b *BlockUserAgents) recordEvent(req *http.Request, event, reason string, elapsed time.Duration) {
+	if b.blockLog != nil || b.webhook != nil {
+		message := b.eventMessage(req, elapsed)
+		message.Event, message.Name, message.Reason = event, b.name, reason
+		message.Timestamp = b.clock.Now().UTC().Format(time.RFC3339)
+		if b.blockLog != nil {
+			b.blockLog.write(message)
+		}
+		if b.webhook != nil {
+			b.webhook.send(message)
+		}
+	}
+	sampled := true
+	b.guard.run("log sampler", func() { sampled = b.logSampler.sample(reason) })
+	if !sampled || !b.dedupeLog(reason) {
+		return
+	}
+	b.logEvent(req, event, reason, elapsed)
+}
+
+// dedupeLog reports whether a block event with the given reason is under
+// the per-reason cap, logging the summaries of the windows that are over.
+func (b *BlockUserAgents) dedupeLog(reason string) bool {
+	if b.logDeduper == nil {
+		return true
+	}
+	logged := true
+	b.guard.run("log deduper", func() {
+		var summaries []suppressedReason
+		logged, summaries = b.logDeduper.allow(reason, b.clock.Now())
+		for _, s := range summaries {
+			log.Printf("%s: suppressed %d blocked request logs with reason %q in the last %s", b.name, s.count, s.reason, b.logDeduper.window)
+		}
+	})
+	return logged
+}
+
+// logSoftMiss logs an allowed request that missed the soft allowlist.
+// It is sampled like block events.
+func (b *BlockUserAgents) logSoftMiss(req *http.Request, elapsed time.Duration) {
+	sampled := true
+	b.guard.run("log sampler", func() { sampled = b.logSampler.sample("Soft-Miss") })
+	if !sampled {
+		return
 	}
+	b.logEvent(req, "Soft-Miss", "", elapsed)
 }
@@ -0,0 +1,1040 @@
+package traefik_plugin_block_useragents
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestNilGuards(t *testing.T) {
+	if _, err := New(context.Background(), nil, testConfig(), "test"); err == nil {
+		t.Error("New with a nil next handler = nil error, want an error")
+	}
+
+	h := newTestHandler(t, testConfig(), nil)
+	h.ServeHTTP(nil, httptest.NewRequest(http.MethodGet, "http://example.com/", nil)) // Dropped without panicking
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status for a nil request = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestGlobToRegex(t *testing.T) {
+	tests := []struct {
+		glob    string
+		want    string
+		matches []string
+		misses  []string
+	}{
+		{"Chrome/*", `^Chrome/.*$`, []string{"Chrome/", "Chrome/131.0"}, []string{"NotChrome/131", "chrome/131"}},
+		{"curl/?.?", `^curl/.\..$`, []string{"curl/8.0"}, []string{"curl/8x0", "curl/10.0"}},
+		{"*Firefox/*", `^.*Firefox/.*$`, []string{firefoxUA}, []string{chromeUA}},
+		{"a+b(c)[d]{2}|^$", `^a\+b\(c\)\[d\]\{2\}\|\^\$$`, []string{"a+b(c)[d]{2}|^$"}, []string{"aab(c)d"}},
+		{"Ünïcode*", `^Ünïcode.*$`, []string{"Ünïcode/1"}, []string{"Unicode/1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.glob, func(t *testing.T) {
+			got := globToRegex(tt.glob)
+			if got != tt.want {
+				t.Errorf("globToRegex(%q) = %q, want %q", tt.glob, got, tt.want)
+			}
+			re := regexp.MustCompile(got)
+			for _, s := range tt.matches {
+				if !re.MatchString(s) {
+					t.Errorf("%q does not match %q", got, s)
+				}
+			}
+			for _, s := range tt.misses {
+				if re.MatchString(s) {
+					t.Errorf("%q matches %q", got, s)
+				}
+			}
+		})
+	}
+}
+
+func TestGlobBrowsers(t *testing.T) {
+	config := CreateConfig()
+	config.GlobBrowsers = []string{"*Chrome/13?.*", "curl/*"}
+	h := newTestHandler(t, config, nil)
+
+	tests := []struct {
+		userAgent string
+		want      int
+	}{
+		{chromeUA, http.StatusOK},
+		{curlUA, http.StatusOK},
+		{"Mozilla/5.0 Chrome/120.0.0.0", http.StatusForbidden},
+		{"xcurl/8.0", http.StatusForbidden},
+		{firefoxUA, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		if rec := serve(h, tt.userAgent); rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.userAgent, rec.Code, tt.want)
+		}
+	}
+}
+
+func TestMatchAllHeaderValues(t *testing.T) {
+	tests := []struct {
+		name       string
+		matchAll   bool
+		userAgents []string
+		want       int
+	}{
+		{"first value hides a blocked one", false, []string{chromeUA, "BadBot/1.0"}, http.StatusOK},
+		{"blocked second value caught", true, []string{chromeUA, "BadBot/1.0"}, http.StatusForbidden},
+		{"blocked first value caught", true, []string{"BadBot/1.0", chromeUA}, http.StatusForbidden},
+		{"first value only, not allowed", false, []string{curlUA, chromeUA}, http.StatusForbidden},
+		{"any value allowed", true, []string{curlUA, chromeUA}, http.StatusOK},
+		{"empty value skipped", true, []string{"", chromeUA}, http.StatusOK},
+		{"single value", true, []string{chromeUA}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.BlockedBrowsers = []BrowserConfig{{Name: "BadBot", Regex: `^BadBot/`}}
+			config.MatchAllHeaderValues = tt.matchAll
+			h := newTestHandler(t, config, nil)
+			rec := serve(h, "", func(req *http.Request) { req.Header["User-Agent"] = tt.userAgents })
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestMethodScopedRules(t *testing.T) {
+	config := CreateConfig()
+	config.AllowedBrowsers = []BrowserConfig{
+		{Name: "Any client (read-only)", Regex: ".*", Methods: []string{"GET", "head"}},
+		{Name: "Chrome", Regex: `Chrome/\d+`},
+	}
+	config.BlockedBrowsers = []BrowserConfig{{Name: "Old Chrome writes", Regex: `Chrome/1[01]\d\.`, Methods: []string{"POST"}}}
+	h := newTestHandler(t, config, nil)
+
+	oldChromeUA := "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/109.0.0.0 Safari/537.36"
+	tests := []struct {
+		method    string
+		userAgent string
+		want      int
+	}{
+		{http.MethodGet, curlUA, http.StatusOK},
+		{http.MethodHead, curlUA, http.StatusOK},
+		{http.MethodPost, curlUA, http.StatusForbidden},
+		{http.MethodPut, curlUA, http.StatusForbidden},
+		{http.MethodPost, chromeUA, http.StatusOK},
+		{http.MethodGet, oldChromeUA, http.StatusOK},
+		{http.MethodPost, oldChromeUA, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.userAgent, func(t *testing.T) {
+			if rec := serve(h, tt.userAgent, withMethod(tt.method)); rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestDenyBrowsersPrecedence(t *testing.T) {
+	tests := []struct {
+		name      string
+		allowed   []BrowserConfig
+		userAgent string
+		want      int
+		reason    string
+	}{
+		{"default allow", nil, firefoxUA, http.StatusOK, ""},
+		{"deny beats default allow", nil, curlUA, http.StatusForbidden, "Denied Browser: Tools"},
+		{"override beats deny", nil, "curl/8.0 (monitoring)", http.StatusOK, ""},
+		{"allowlist still applies", []BrowserConfig{{Name: "Chrome", Regex: `Chrome/`}}, firefoxUA, http.StatusForbidden, "Unsupported Browser"},
+		{"override does not bypass the allowlist", []BrowserConfig{{Name: "Chrome", Regex: `Chrome/`}}, "curl/8.0 (monitoring)", http.StatusForbidden, "Unsupported Browser"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := CreateConfig()
+			config.AllowedBrowsers = tt.allowed
+			config.DenyBrowsers = []BrowserConfig{{Name: "Tools", Regex: `^(?:curl|wget)/`}}
+			config.AllowOverrides = []string{`\(monitoring\)$`}
+			config.ExposeReasonHeader = true
+			h := newTestHandler(t, config, nil)
+			rec := serve(h, tt.userAgent)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if got := rec.Header().Get("X-Block-Reason"); got != tt.reason {
+				t.Errorf("reason = %q, want %q", got, tt.reason)
+			}
+		})
+	}
+}
+
+func TestCombinePatterns(t *testing.T) {
+	userAgents := []string{"Browser0/100.0", "Mozilla/5.0 Browser199/350.2", "Browser42/99.0", curlUA, ""}
+	perRule := newTestHandler(t, largeRulesetConfig(), nil)
+	config := largeRulesetConfig()
+	config.CombinePatterns = true
+	combined := newTestHandler(t, config, nil)
+	if combined.combinedRegexp == nil {
+		t.Fatal("patterns not combined")
+	}
+	for _, userAgent := range userAgents {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+		req.Header.Set("User-Agent", userAgent)
+		if got, want := combined.evaluate(req), perRule.evaluate(req); got.allowed != want.allowed || got.reason != want.reason {
+			t.Errorf("%q: combined decision %+v, per-rule decision %+v", userAgent, got, want)
+		}
+	}
+}
+
+// BenchmarkAllowedBrowsers evaluates a User-Agent matching the last of many
+// allowed browsers, and one matching none, with and without combinePatterns.
+func BenchmarkAllowedBrowsers(b *testing.B) {
+	for _, combine := range []bool{false, true} {
+		config := largeRulesetConfig()
+		config.CombinePatterns = combine
+		h := newTestHandler(b, config, nil)
+		name := "per-rule"
+		if combine {
+			name = "combined"
+		}
+		for _, tt := range []struct{ name, userAgent string }{{"match", "Mozilla/5.0 Browser199/350.2"}, {"miss", chromeUA}} {
+			req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+			req.Header.Set("User-Agent", tt.userAgent)
+			b.Run(name+"/"+tt.name, func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					h.evaluate(req)
+				}
+			})
+		}
+	}
+}
+
+func TestMinMatchLength(t *testing.T) {
+	tests := []struct {
+		name      string
+		min       int
+		userAgent string
+		want      int
+	}{
+		{"short match allowed when disabled", 0, "CCBot/2.0", http.StatusOK},
+		{"short match blocked", 5, "CCBot/2.0", http.StatusForbidden},
+		{"long match allowed", 5, chromeUA, http.StatusOK},
+		{"later long match counts", 5, "CCBot Crawler", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := CreateConfig()
+			config.AllowedBrowsers = []BrowserConfig{{Name: "Permissive", Regex: `C[a-z]*`}}
+			config.MinMatchLength = tt.min
+			h := newTestHandler(t, config, nil)
+			if rec := serve(h, tt.userAgent); rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+// BenchmarkMinMatchLength measures the cost of finding the match bounds
+// for minMatchLength over a plain match test.
+func BenchmarkMinMatchLength(b *testing.B) {
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+	req.Header.Set("User-Agent", chromeUA)
+	for _, minLength := range []int{0, 6} {
+		config := testConfig()
+		config.MinMatchLength = minLength
+		h := newTestHandler(b, config, nil)
+		name := "disabled"
+		if minLength > 0 {
+			name = "enabled"
+		}
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				h.evaluate(req)
+			}
+		})
+	}
+}
+
+func TestFingerprintHeader(t *testing.T) {
+	tests := []struct {
+		name        string
+		fingerprint string
+		want        int
+		reason      string
+	}{
+		{"allowed fingerprint", "771,4865-4866", http.StatusOK, ""},
+		{"compared case-insensitively", " 771,4865-4866-ABC ", http.StatusOK, ""},
+		{"unknown fingerprint", "769,47-53", http.StatusForbidden, "Unsupported Fingerprint"},
+		{"missing fingerprint", "", http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.FingerprintHeader = "X-JA3"
+			config.AllowedFingerprints = []string{"771,4865-4866", "771,4865-4866-abc"}
+			config.ExposeReasonHeader = true
+			h := newTestHandler(t, config, nil)
+			var edits []func(*http.Request)
+			if tt.fingerprint != "" {
+				edits = append(edits, withHeader("X-JA3", tt.fingerprint))
+			}
+			rec := serve(h, chromeUA, edits...)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if got := rec.Header().Get("X-Block-Reason"); got != tt.reason {
+				t.Errorf("reason = %q, want %q", got, tt.reason)
+			}
+		})
+	}
+
+	config := testConfig()
+	config.AllowedFingerprints = []string{"771,4865-4866"}
+	if err := ValidateConfig(config); !errors.Is(err, ErrMissingSetting) {
+		t.Errorf("ValidateConfig without fingerprintHeader = %v, want %v", err, ErrMissingSetting)
+	}
+}
+
+func TestSelfTestUserAgents(t *testing.T) {
+	tests := []struct {
+		name       string
+		userAgents []string
+		wantErr    string // Empty when New succeeds
+	}{
+		{"all allowed", []string{chromeUA}, ""},
+		{"one blocked", []string{chromeUA, curlUA}, `self-test failed, expected User-Agents would be blocked: "curl/8.0" (Unsupported Browser)`},
+		{"every failure listed", []string{firefoxUA, curlUA}, `Firefox/128.0" (Unsupported Browser), "curl/8.0" (Unsupported Browser)`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.SelfTestUserAgents = tt.userAgents
+			_, err := New(context.Background(), okHandler, config, "test")
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("New: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("New error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBlockResponseHeaders(t *testing.T) {
+	config := testConfig()
+	config.BlockResponseHeaders = map[string]string{"Cache-Control": "no-store", "X-Blocked-By": "block-useragents"}
+	h := newTestHandler(t, config, nil)
+
+	rec := serve(h, curlUA)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+	for key, want := range config.BlockResponseHeaders {
+		if got := rec.Header().Get(key); got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+
+	// Allowed responses are left alone
+	rec = serve(h, chromeUA)
+	for key := range config.BlockResponseHeaders {
+		if got := rec.Header().Get(key); got != "" {
+			t.Errorf("%s = %q on an allowed response, want none", key, got)
+		}
+	}
+
+	config = testConfig()
+	config.BlockResponseHeaders = map[string]string{"": "value"}
+	if err := ValidateConfig(config); !errors.Is(err, ErrInvalidValue) {
+		t.Errorf("ValidateConfig with an empty header name = %v, want %v", err, ErrInvalidValue)
+	}
+}
+
+func TestCheckOrigin(t *testing.T) {
+	tests := []struct {
+		name   string
+		edits  []func(*http.Request)
+		want   int
+		reason string
+	}{
+		{"allowed Origin", []func(*http.Request){withHeader("Origin", "https://example.com")}, http.StatusOK, ""},
+		{"disallowed Origin", []func(*http.Request){withHeader("Origin", "https://evil.example")}, http.StatusForbidden, "Disallowed Origin"},
+		{"allowed Referer", []func(*http.Request){withHeader("Referer", "https://example.com/page")}, http.StatusOK, ""},
+		{"disallowed Referer", []func(*http.Request){withHeader("Referer", "https://evil.example/page")}, http.StatusForbidden, "Disallowed Origin"},
+		{"Origin takes precedence over Referer", []func(*http.Request){withHeader("Origin", "https://example.com"), withHeader("Referer", "https://evil.example/")}, http.StatusOK, ""},
+		{"neither header", nil, http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.CheckOrigin = true
+			config.AllowedOrigins = []string{`^https://example\.com(/|$)`}
+			config.ExposeReasonHeader = true
+			h := newTestHandler(t, config, nil)
+			rec := serve(h, chromeUA, tt.edits...)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if got := rec.Header().Get("X-Block-Reason"); got != tt.reason {
+				t.Errorf("reason = %q, want %q", got, tt.reason)
+			}
+		})
+	}
+}
+
+func TestNormalizeForward(t *testing.T) {
+	tests := []struct {
+		name    string
+		enabled bool
+		want    string
+	}{
+		{"rewritten in order", true, "Mozilla/5.0 (Windows) Chrome/131"},
+		{"disabled", false, chromeUA},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var forwarded string
+			next := http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+				forwarded = req.UserAgent()
+				res.WriteHeader(http.StatusOK)
+			})
+			config := testConfig()
+			config.NormalizeForward = tt.enabled
+			config.NormalizeRules = []ReplaceRule{
+				{Regex: `\(Windows[^)]*\)`, Replacement: "(Windows)"},
+				{Regex: `Chrome/(\d+)[.\d]*`, Replacement: "Chrome/${1}"},
+				{Regex: ` AppleWebKit/\S+ \(KHTML, like Gecko\)| Safari/\S+`, Replacement: ""},
+			}
+			h := newTestHandler(t, config, next)
+			if rec := serve(h, chromeUA); rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+			}
+			if forwarded != tt.want {
+				t.Errorf("forwarded User-Agent = %q, want %q", forwarded, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequireMatchCount(t *testing.T) {
+	tests := []struct {
+		name      string
+		count     int
+		userAgent string
+		want      int
+	}{
+		{"one match by default", 0, "Mozilla/5.0 Chrome/131.0", http.StatusOK},
+		{"two matches required", 2, chromeUA, http.StatusOK},
+		{"one of two matches", 2, "Mozilla/5.0 Chrome/131.0", http.StatusForbidden},
+		{"three matches required", 3, chromeUA, http.StatusOK},
+		{"two of three matches", 3, "Mozilla/5.0 (X11) Chrome/131.0 Safari/537.36", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := CreateConfig()
+			config.AllowedBrowsers = []BrowserConfig{
+				{Name: "Chrome", Regex: `Chrome/\d+`},
+				{Name: "Safari token", Regex: `Safari/\d+`},
+				{Name: "Windows", Regex: `Windows NT`},
+			}
+			config.RequireMatchCount = tt.count
+			h := newTestHandler(t, config, nil)
+			if rec := serve(h, tt.userAgent); rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+
+	config := testConfig()
+	config.RequireMatchCount = 2
+	if err := ValidateConfig(config); !errors.Is(err, ErrInvalidValue) {
+		t.Errorf("ValidateConfig with more matches than rules = %v, want %v", err, ErrInvalidValue)
+	}
+}
+
+func TestOSMatchMode(t *testing.T) {
+	tests := []struct {
+		name      string
+		mode      string
+		userAgent string
+		want      int
+		reason    string
+	}{
+		{"allow mode match", "", chromeUA, http.StatusOK, ""},
+		{"allow mode miss", OSMatchAllow, "Mozilla/5.0 (X11; Linux x86_64) Chrome/131.0", http.StatusForbidden, "Unsupported OS"},
+		{"block mode match", OSMatchBlock, chromeUA, http.StatusForbidden, "Banned OS"},
+		{"block mode miss", OSMatchBlock, "Mozilla/5.0 (X11; Linux x86_64) Chrome/131.0", http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.AllowedOSTypes = []string{`Windows NT`}
+			config.OSMatchMode = tt.mode
+			config.ExposeReasonHeader = true
+			h := newTestHandler(t, config, nil)
+			rec := serve(h, tt.userAgent)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if got := rec.Header().Get("X-Block-Reason"); got != tt.reason {
+				t.Errorf("reason = %q, want %q", got, tt.reason)
+			}
+		})
+	}
+
+	config := testConfig()
+	config.OSMatchMode = "deny"
+	if err := ValidateConfig(config); !errors.Is(err, ErrInvalidValue) {
+		t.Errorf("ValidateConfig with osMatchMode deny = %v, want %v", err, ErrInvalidValue)
+	}
+}
+
+func TestMaxBlockBodyBytes(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxBytes int
+		message  int
+		want     int
+	}{
+		{"default cap", 0, defaultMaxBlockBodyBytes + 10, defaultMaxBlockBodyBytes},
+		{"truncated", 8, 20, 8},
+		{"within the cap", 32, 20, 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.MaxBlockBodyBytes = tt.maxBytes
+			config.MessagesByReason = map[string]string{"Unsupported Browser": strings.Repeat("x", tt.message)}
+			h := newTestHandler(t, config, nil)
+			rec := serve(h, curlUA)
+			if got := rec.Body.Len(); got != tt.want {
+				t.Errorf("body length = %d, want %d", got, tt.want)
+			}
+			if got := rec.Header().Get("Content-Length"); got != strconv.Itoa(tt.want) {
+				t.Errorf("Content-Length = %s, want %d", got, tt.want)
+			}
+		})
+	}
+
+	config := testConfig()
+	config.MaxBlockBodyBytes = -1
+	if err := ValidateConfig(config); !errors.Is(err, ErrInvalidValue) {
+		t.Errorf("ValidateConfig with a negative maxBlockBodyBytes = %v, want %v", err, ErrInvalidValue)
+	}
+}
+
+func TestCheckLanguage(t *testing.T) {
+	tests := []struct {
+		name     string
+		language string
+		want     int
+		reason   string
+	}{
+		{"allowed language", "en-US,en;q=0.9", http.StatusOK, ""},
+		{"unsupported language", "xx-XX", http.StatusForbidden, "Unsupported Language"},
+		{"no Accept-Language", "", http.StatusForbidden, "No Accept-Language"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.CheckLanguage = true
+			config.AllowedLanguages = []string{`^(en|fr)\b`}
+			config.ExposeReasonHeader = true
+			h := newTestHandler(t, config, nil)
+			var edits []func(*http.Request)
+			if tt.language != "" {
+				edits = append(edits, withHeader("Accept-Language", tt.language))
+			}
+			rec := serve(h, chromeUA, edits...)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if got := rec.Header().Get("X-Block-Reason"); got != tt.reason {
+				t.Errorf("reason = %q, want %q", got, tt.reason)
+			}
+		})
+	}
+}
+
+func TestDefaultDecision(t *testing.T) {
+	tests := []struct {
+		name      string
+		decision  string
+		userAgent string
+		want      int
+	}{
+		{"block by default", "", firefoxUA, http.StatusForbidden},
+		{"explicit block", DefaultDecisionBlock, firefoxUA, http.StatusForbidden},
+		{"allow", DefaultDecisionAllow, firefoxUA, http.StatusOK},
+		{"allow keeps the deny list", DefaultDecisionAllow, curlUA, http.StatusForbidden},
+		{"allow keeps allowed browsers", DefaultDecisionAllow, chromeUA, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.DenyBrowsers = []BrowserConfig{{Name: "Tools", Regex: `^curl/`}}
+			config.DefaultDecision = tt.decision
+			h := newTestHandler(t, config, nil)
+			if rec := serve(h, tt.userAgent); rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+
+	// An allow decision is enough of a ruleset on its own
+	config := CreateConfig()
+	config.DefaultDecision = DefaultDecisionAllow
+	h := newTestHandler(t, config, nil)
+	if rec := serve(h, curlUA); rec.Code != http.StatusOK {
+		t.Errorf("status without browser rules = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	config = testConfig()
+	config.DefaultDecision = "deny"
+	if err := ValidateConfig(config); !errors.Is(err, ErrInvalidValue) {
+		t.Errorf("ValidateConfig with defaultDecision deny = %v, want %v", err, ErrInvalidValue)
+	}
+	config = testConfig()
+	config.DefaultDecision = DefaultDecisionAllow
+	config.DefaultAction = RuleDeny
+	if err := ValidateConfig(config); !errors.Is(err, ErrConflictingSettings) {
+		t.Errorf("ValidateConfig with defaultAction = %v, want %v", err, ErrConflictingSettings)
+	}
+}
+
+func TestEmptyConfigBehavior(t *testing.T) {
+	tests := []struct {
+		name     string
+		behavior string
+		want     int // Zero when the config is rejected
+	}{
+		{"rejected by default", "", 0},
+		{"explicit error", EmptyConfigError, 0},
+		{"allow all", EmptyConfigAllowAll, http.StatusOK},
+		{"block all", EmptyConfigBlockAll, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := CreateConfig()
+			config.EmptyConfigBehavior = tt.behavior
+			if tt.want == 0 {
+				if err := ValidateConfig(config); !errors.Is(err, ErrNoBrowsers) {
+					t.Errorf("ValidateConfig = %v, want %v", err, ErrNoBrowsers)
+				}
+				return
+			}
+			h := newTestHandler(t, config, nil)
+			for _, userAgent := range []string{chromeUA, curlUA} {
+				if rec := serve(h, userAgent); rec.Code != tt.want {
+					t.Errorf("%s: status = %d, want %d", userAgent, rec.Code, tt.want)
+				}
+			}
+		})
+	}
+
+	// A non-empty ruleset ignores the behavior
+	config := testConfig()
+	config.EmptyConfigBehavior = EmptyConfigBlockAll
+	h := newTestHandler(t, config, nil)
+	if rec := serve(h, chromeUA); rec.Code != http.StatusOK {
+		t.Errorf("status with browser rules = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	config = CreateConfig()
+	config.EmptyConfigBehavior = "ignore"
+	if err := ValidateConfig(config); !errors.Is(err, ErrInvalidValue) {
+		t.Errorf("ValidateConfig with emptyConfigBehavior ignore = %v, want %v", err, ErrInvalidValue)
+	}
+}
+
+func TestBlockedBrowsers(t *testing.T) {
+	tests := []struct {
+		name      string
+		userAgent string
+		want      int
+		location  string
+	}{
+		{"block", "python-requests/2.31", http.StatusForbidden, ""},
+		{"redirect", "Mozilla/5.0 (compatible; MSIE 10.0; Windows NT 6.1)", http.StatusFound, "https://example.com/upgrade"},
+		{"log-only goes on to the allowlist", "Mozilla/5.0 (Windows NT 10.0) Chrome/131.0 Bot", http.StatusOK, ""},
+		{"log-only still needs the allowlist", "Bot/1.0", http.StatusForbidden, ""},
+		{"strictest action wins", "Mozilla/5.0 (compatible; MSIE 10.0; Windows NT 6.1) Bot", http.StatusFound, "https://example.com/upgrade"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.BlockedBrowsers = []BrowserConfig{
+				{Name: "Bots", Regex: `Bot`, Action: ActionLogOnly},
+				{Name: "Python", Regex: `^python-requests/`},
+				{Name: "IE", Regex: `MSIE`, Action: ActionRedirect, RedirectURL: "https://example.com/upgrade"},
+			}
+			h := newTestHandler(t, config, nil)
+			rec := serve(h, tt.userAgent)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if got := rec.Header().Get("Location"); got != tt.location {
+				t.Errorf("Location = %q, want %q", got, tt.location)
+			}
+		})
+	}
+
+	config := testConfig()
+	config.BlockedBrowsers = []BrowserConfig{{Name: "IE", Regex: `MSIE`, Action: ActionRedirect}}
+	if err := ValidateConfig(config); !errors.Is(err, ErrMissingSetting) {
+		t.Errorf("ValidateConfig without redirectUrl = %v, want %v", err, ErrMissingSetting)
+	}
+	config.BlockedBrowsers = []BrowserConfig{{Name: "IE", Regex: `MSIE`, Action: "tarpit"}}
+	if err := ValidateConfig(config); !errors.Is(err, ErrInvalidValue) {
+		t.Errorf("ValidateConfig with action tarpit = %v, want %v", err, ErrInvalidValue)
+	}
+}
+
+func TestExceptPatterns(t *testing.T) {
+	const edgeUA = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.0.0 Safari/537.36 Edg/131.0.0.0"
+	tests := []struct {
+		name      string
+		userAgent string
+		want      int
+	}{
+		{"match without exception", chromeUA, http.StatusOK},
+		{"exception blocks the match", edgeUA, http.StatusForbidden},
+		{"no match", firefoxUA, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := CreateConfig()
+			config.AllowedBrowsers = []BrowserConfig{{Name: "Chrome", Regex: `Chrome/\d+`, Except: []string{`Edg/`, `OPR/`}}}
+			h := newTestHandler(t, config, nil)
+			if rec := serve(h, tt.userAgent); rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestSkipInvalidPatterns(t *testing.T) {
+	config := testConfig()
+	config.AllowedBrowsers = append(config.AllowedBrowsers, BrowserConfig{Name: "Broken", Regex: `Firefox/(`})
+	config.AllowedOSTypes = []string{`Windows`, `(`}
+	if _, err := New(context.Background(), okHandler, config, "test"); err == nil {
+		t.Error("New with invalid patterns = nil error, want an error")
+	}
+
+	config.SkipInvalidPatterns = true
+	h := newTestHandler(t, config, nil)
+	for userAgent, want := range map[string]int{chromeUA: http.StatusOK, firefoxUA: http.StatusForbidden} {
+		if rec := serve(h, userAgent); rec.Code != want {
+			t.Errorf("%s: status = %d, want %d", userAgent, rec.Code, want)
+		}
+	}
+
+	config.AllowedBrowsers = config.AllowedBrowsers[1:]
+	if err := ValidateConfig(config); !errors.Is(err, ErrNoBrowsers) {
+		t.Errorf("ValidateConfig without a valid browser = %v, want %v", err, ErrNoBrowsers)
+	}
+}
+
+func TestNewWithCancelledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	tests := []struct {
+		name   string
+		config func(*Config)
+	}{
+		{"rules file", func(c *Config) {
+			c.RulesFile = writeRules(t, "rules.json", `{"allowedBrowsers": [{"name": "Chrome", "regex": "Chrome/"}]}`)
+		}},
+		{"self-test", func(c *Config) { c.SelfTestUserAgents = []string{chromeUA} }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			tt.config(config)
+			if _, err := New(ctx, okHandler, config, "test"); !errors.Is(err, context.Canceled) {
+				t.Errorf("New() error = %v, want %v", err, context.Canceled)
+			}
+		})
+	}
+}
+
+func TestBlockResponseTemplate(t *testing.T) {
+	config := testConfig()
+	config.BlockResponseTemplate = "<p>{{.Reason}} for {{.UserAgent}} on {{.Host}}</p>"
+	h := newTestHandler(t, config, nil)
+
+	rec := serve(h, curlUA)
+	if want := "<p>Unsupported Browser for curl/8.0 on example.com</p>"; rec.Body.String() != want {
+		t.Errorf("body = %q, want %q", rec.Body.String(), want)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "text/html; charset=utf-8" {
+		t.Errorf("Content-Type = %q, want text/html", got)
+	}
+	if rec := serve(h, chromeUA); rec.Body.Len() != 0 {
+		t.Errorf("allowed request body = %q, want the backend response", rec.Body.String())
+	}
+
+	config.BlockResponseTemplate = "{{.Reason"
+	if _, err := New(context.Background(), okHandler, config, "test"); err == nil {
+		t.Error("New with an invalid template = nil error, want an error")
+	}
+}
+
+func TestEvaluationOrder(t *testing.T) {
+	const macFirefoxUA = "Mozilla/5.0 (Macintosh; Intel Mac OS X 14.5; rv:128.0) Gecko/20100101 Firefox/128.0"
+	tests := []struct {
+		order []string
+		want  string
+	}{
+		{nil, "Unsupported Browser"},
+		{[]string{DimensionOS}, "Unsupported OS"},
+		{[]string{DimensionOS, DimensionBrowser}, "Unsupported OS"},
+		{[]string{DimensionBrowser, DimensionOS}, "Unsupported Browser"},
+	}
+	for _, tt := range tests {
+		config := testConfig()
+		config.AllowedOSTypes = []string{`Windows NT`}
+		config.EvaluationOrder = tt.order
+		h := newTestHandler(t, config, nil)
+
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+		req.Header.Set("User-Agent", macFirefoxUA)
+		if d := h.evaluate(req); d.allowed || d.reason != tt.want {
+			t.Errorf("order %q: evaluate = (allowed %v, reason %q), want reason %q", tt.order, d.allowed, d.reason, tt.want)
+		}
+	}
+
+	for _, order := range [][]string{{"referer"}, {DimensionOS, DimensionOS}} {
+		config := testConfig()
+		config.EvaluationOrder = order
+		if err := ValidateConfig(config); !errors.Is(err, ErrInvalidValue) {
+			t.Errorf("ValidateConfig with evaluationOrder %q = %v, want %v", order, err, ErrInvalidValue)
+		}
+	}
+}
+
+func TestSoftAllowedBrowsers(t *testing.T) {
+	tests := []struct {
+		name      string
+		userAgent string
+		want      int
+		softMiss  bool
+	}{
+		{"soft match", chromeUA, http.StatusOK, false},
+		{"soft miss", firefoxUA, http.StatusOK, true},
+		{"blocked requests are not soft misses", curlUA, http.StatusForbidden, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.AllowedBrowsers = append(config.AllowedBrowsers, BrowserConfig{Name: "Firefox", Regex: `Firefox/\d+`})
+			config.SoftAllowedBrowsers = []BrowserConfig{{Name: "Chrome", Regex: `Chrome/13\d`}}
+			h := newTestHandler(t, config, nil)
+			logs := captureLog(t)
+
+			if rec := serve(h, tt.userAgent); rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if got := strings.Contains(logs.String(), "Soft-Miss"); got != tt.softMiss {
+				t.Errorf("Soft-Miss logged = %v, want %v: %s", got, tt.softMiss, logs)
+			}
+		})
+	}
+}
+
+func TestNewWithHook(t *testing.T) {
+	var defaults []bool
+	hook := func(req *http.Request, defaultAllow bool) (bool, string) {
+		defaults = append(defaults, defaultAllow)
+		switch req.Header.Get("X-Hook") {
+		case "allow":
+			return true, ""
+		case "deny":
+			return false, "Hook Denied"
+		case "deny-silently":
+			return false, ""
+		}
+		return defaultAllow, ""
+	}
+	config := testConfig()
+	config.ExposeReasonHeader = true
+	handler, err := NewWithHook(context.Background(), okHandler, config, "test", hook)
+	if err != nil {
+		t.Fatalf("NewWithHook: %v", err)
+	}
+	h := handler.(*BlockUserAgents)
+	t.Cleanup(func() { _ = h.Close() })
+
+	tests := []struct {
+		name      string
+		userAgent string
+		hook      string
+		want      int
+		reason    string
+	}{
+		{"default allow kept", chromeUA, "", http.StatusOK, ""},
+		{"default block kept", curlUA, "", http.StatusForbidden, "Unsupported Browser"},
+		{"block overridden", curlUA, "allow", http.StatusOK, ""},
+		{"allow overridden", chromeUA, "deny", http.StatusForbidden, "Hook Denied"},
+		{"allow overridden without a reason", chromeUA, "deny-silently", http.StatusForbidden, "Decision Hook"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defaults = nil
+			rec := serve(h, tt.userAgent, withHeader("X-Hook", tt.hook))
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if got := rec.Header().Get("X-Block-Reason"); got != tt.reason {
+				t.Errorf("reason = %q, want %q", got, tt.reason)
+			}
+			if wantDefault := tt.userAgent == chromeUA; len(defaults) != 1 || defaults[0] != wantDefault {
+				t.Errorf("hook called with %v, want once with %v", defaults, wantDefault)
+			}
+		})
+	}
+}
+
+func TestRegexFlags(t *testing.T) {
+	tests := []struct {
+		name      string
+		flags     string
+		userAgent string
+		want      int
+	}{
+		{"case-sensitive by default", "", strings.ToLower(chromeUA), http.StatusForbidden},
+		{"case-insensitive", "i", strings.ToLower(chromeUA), http.StatusOK},
+		{"case-insensitive still matches", "i", chromeUA, http.StatusOK},
+		{"multiple flags", "is", strings.ToLower(chromeUA), http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.AllowedBrowsers = []BrowserConfig{{Name: "Chrome", Regex: `Chrome/\d+`, Flags: tt.flags}}
+			h := newTestHandler(t, config, nil)
+			if rec := serve(h, tt.userAgent); rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+
+	config := testConfig()
+	config.BlockedBrowsers = []BrowserConfig{{Name: "BadBot", Regex: "badbot", Flags: "x"}}
+	if err := ValidateConfig(config); !errors.Is(err, ErrInvalidValue) {
+		t.Errorf("ValidateConfig with flag x = %v, want %v", err, ErrInvalidValue)
+	}
+}
+
+func TestMatchHeaders(t *testing.T) {
+	tests := []struct {
+		name     string
+		original string
+		agent    string
+		want     int
+	}{
+		{"first header used", chromeUA, curlUA, http.StatusOK},
+		{"first header blocked", curlUA, chromeUA, http.StatusForbidden},
+		{"falls back when empty", "", chromeUA, http.StatusOK},
+		{"falls back and blocks", "", curlUA, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.MatchHeaders = []string{" x-original-user-agent ", "User-Agent"}
+			h := newTestHandler(t, config, nil)
+			rec := serve(h, tt.agent, withHeader("X-Original-User-Agent", tt.original))
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+
+	config := testConfig()
+	config.MatchHeaders = []string{"X-Original-User-Agent"}
+	config.LogFormat = LogFormatLogfmt
+	h := newTestHandler(t, config, nil)
+	logs := captureLog(t)
+	serve(h, chromeUA, withHeader("X-Original-User-Agent", curlUA))
+	if want := "user-agent=curl/8.0 "; !strings.Contains(logs.String(), want) {
+		t.Errorf("log = %q, want %q", logs, want)
+	}
+	if want := "matchHeader=X-Original-User-Agent"; !strings.Contains(logs.String(), want) {
+		t.Errorf("log = %q, want %q", logs, want)
+	}
+
+	config.MatchHeaders = []string{" "}
+	if err := ValidateConfig(config); !errors.Is(err, ErrInvalidValue) {
+		t.Errorf("ValidateConfig with an empty match header = %v, want %v", err, ErrInvalidValue)
+	}
+}
+
+func TestMaintenanceMode(t *testing.T) {
+	tests := []struct {
+		name        string
+		maintenance bool
+		userAgent   string
+		want        int
+	}{
+		{"allowed browser", false, chromeUA, http.StatusOK},
+		{"allowed browser in maintenance", true, chromeUA, http.StatusServiceUnavailable},
+		{"blocked browser in maintenance", true, curlUA, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.MaintenanceMode = tt.maintenance
+			config.ExposeReasonHeader = true
+			h := newTestHandler(t, config, nil)
+			rec := serve(h, tt.userAgent)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if got := rec.Header().Get("X-Block-Reason"); tt.maintenance && got != "Maintenance" {
+				t.Errorf("reason = %q, want %q", got, "Maintenance")
+			}
+		})
+	}
+}
+
+func TestDisabledRules(t *testing.T) {
+	disabled, enabled := false, true
+	tests := []struct {
+		name      string
+		userAgent string
+		want      int
+	}{
+		{"disabled allowed rule", chromeUA, http.StatusForbidden},
+		{"enabled allowed rule", firefoxUA, http.StatusOK},
+		{"disabled blocked rule", "BadBot/1.0 " + firefoxUA, http.StatusOK},
+		{"enabled blocked rule", "EvilBot/1.0 " + firefoxUA, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := CreateConfig()
+			config.AllowedBrowsers = []BrowserConfig{
+				{Name: "Chrome", Regex: `Chrome/\d+`, Enabled: &disabled, Comment: "Paused during the rollout"},
+				{Name: "Firefox", Regex: `Firefox/\d+`, Enabled: &enabled},
+			}
+			config.BlockedBrowsers = []BrowserConfig{
+				{Name: "BadBot", Regex: "BadBot/", Enabled: &disabled},
+				{Name: "EvilBot", Regex: "EvilBot/", Comment: "Enabled by default"},
+			}
+			h := newTestHandler(t, config, nil)
+			if rec := serve(h, tt.userAgent); rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
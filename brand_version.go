@@ -0,0 +1,236 @@
+package traefik_plugin_block_useragents
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// fullVersionListHeader is the client hint listing each brand with its full
+// version, e.g. `"Chromium";v="131.0.6778.86", "Not_A Brand";v="24.0.0.0"`.
+const fullVersionListHeader = "Sec-CH-UA-Full-Version-List"
+
+// brandVersionRulePattern splits a brand version rule such as
+// "Google Chrome>=120.0.6099" into the brand, the operator and the version.
+var brandVersionRulePattern = regexp.MustCompile(`^\s*([^<>=!"]*[^<>=!"\s])\s*(>=|<=|==|!=|>|<|=)\s*(\d+(?:\.\d+)*)\s*$`)
+
+// brandVersion is a brand of the full version list with its version.
+type brandVersion struct {
+	brand   string
+	version string
+}
+
+// brandVersionRule requires the full version of a brand to compare to a
+// version with op.
+type brandVersionRule struct {
+	source  string
+	brand   string // Lowercased
+	op      string
+	version []int
+}
+
+// parseBrandVersionRule parses a brand version rule: a brand as listed in
+// Sec-CH-UA-Full-Version-List, an operator (>=, >, <=, <, ==, =, !=) and a
+// version.
+func parseBrandVersionRule(rule string) (brandVersionRule, error) {
+	m := brandVersionRulePattern.FindStringSubmatch(rule)
+	if m == nil {
+		return brandVersionRule{}, fmt.Errorf("invalid brand version rule %q: expected <brand><op><version>, e.g. Google Chrome>=120.0.6099", rule)
+	}
+	version, err := parseVersion(m[3])
+	if err != nil {
+		return brandVersionRule{}, fmt.Errorf("invalid brand version rule %q: %w", rule, err)
+	}
+	return brandVersionRule{source: strings.TrimSpace(rule), brand: strings.ToLower(m[1]), op: m[2], version: version}, nil
+}
+
+// parseFullVersionList parses a Sec-CH-UA-Full-Version-List value, a
+// structured-header list of quoted brands whose "v" parameter holds the
+// version. Members without a "v" parameter are skipped; inner lists and
+// malformed values are rejected.
+func parseFullVersionList(value string) ([]brandVersion, error) {
+	p := &structuredParser{s: value}
+	brands := make([]brandVersion, 0, 4)
+	p.skip(" \t")
+	if p.done() {
+		return brands, nil
+	}
+	for {
+		brand, err := p.bareItem()
+		if err != nil {
+			return nil, err
+		}
+		bv := brandVersion{brand: brand}
+		hasVersion := false
+		for p.peek() == ';' {
+			p.pos++
+			p.skip(" ")
+			key, err := p.key()
+			if err != nil {
+				return nil, err
+			}
+			param := "?1" // Parameters without a value are true
+			if p.peek() == '=' {
+				p.pos++
+				if param, err = p.bareItem(); err != nil {
+					return nil, err
+				}
+			}
+			if key == "v" {
+				bv.version, hasVersion = param, true
+			}
+		}
+		if hasVersion {
+			brands = append(brands, bv)
+		}
+		p.skip(" \t")
+		if p.done() {
+			return brands, nil
+		}
+		if p.peek() != ',' {
+			return nil, fmt.Errorf("invalid %s: expected ',' at offset %d", fullVersionListHeader, p.pos)
+		}
+		p.pos++
+		p.skip(" \t")
+		if p.done() {
+			return nil, fmt.Errorf("invalid %s: trailing ','", fullVersionListHeader)
+		}
+	}
+}
+
+// structuredParser reads the parts of the structured-header grammar (RFC
+// 8941) used by the client hints.
+type structuredParser struct {
+	s   string
+	pos int
+}
+
+func (p *structuredParser) done() bool {
+	return p.pos >= len(p.s)
+}
+
+func (p *structuredParser) peek() byte {
+	if p.done() {
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+func (p *structuredParser) skip(chars string) {
+	for !p.done() && strings.IndexByte(chars, p.s[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+// bareItem reads a string or a token; numbers and booleans are read as
+// tokens, which is enough to skip them.
+func (p *structuredParser) bareItem() (string, error) {
+	if p.peek() == '"' {
+		return p.quotedString()
+	}
+	start := p.pos
+	for !p.done() && isTokenChar(p.s[p.pos]) {
+		p.pos++
+	}
+	if p.pos == start {
+		return "", fmt.Errorf("invalid %s: expected an item at offset %d", fullVersionListHeader, start)
+	}
+	return p.s[start:p.pos], nil
+}
+
+// quotedString reads a quoted string, where only \" and \\ are escapes.
+func (p *structuredParser) quotedString() (string, error) {
+	var sb strings.Builder
+	for p.pos++; !p.done(); p.pos++ {
+		c := p.s[p.pos]
+		switch {
+		case c == '\\':
+			p.pos++
+			if p.done() || (p.s[p.pos] != '"' && p.s[p.pos] != '\\') {
+				return "", fmt.Errorf("invalid %s: bad escape at offset %d", fullVersionListHeader, p.pos)
+			}
+			sb.WriteByte(p.s[p.pos])
+		case c == '"':
+			p.pos++
+			return sb.String(), nil
+		case c < 0x20 || c > 0x7e:
+			return "", fmt.Errorf("invalid %s: bad character at offset %d", fullVersionListHeader, p.pos)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return "", fmt.Errorf("invalid %s: unterminated string", fullVersionListHeader)
+}
+
+// key reads a parameter key: a lowercase letter or '*', then lowercase
+// letters, digits, '_', '-', '.' or '*'.
+func (p *structuredParser) key() (string, error) {
+	start := p.pos
+	if c := p.peek(); (c < 'a' || c > 'z') && c != '*' {
+		return "", fmt.Errorf("invalid %s: expected a parameter key at offset %d", fullVersionListHeader, start)
+	}
+	for !p.done() {
+		c := p.s[p.pos]
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') && !strings.ContainsRune("_-.*", rune(c)) {
+			break
+		}
+		p.pos++
+	}
+	return p.s[start:p.pos], nil
+}
+
+// isTokenChar reports whether c may appear in a token or number item.
+func isTokenChar(c byte) bool {
+	return c > 0x20 && c < 0x7f && !strings.ContainsRune(`"(),;<=>?@[\]{}`, rune(c))
+}
+
+// validateBrandVersionRules checks the brand version rules.
+func validateBrandVersionRules(config *Config) error {
+	var errs ConfigErrors
+	for _, rule := range config.BrandVersionRules {
+		if _, err := parseBrandVersionRule(rule); err != nil {
+			errs.add(configErrorf(ErrInvalidValue, "%w", err))
+		}
+	}
+	return errs.err()
+}
+
+// compileBrandVersionRules parses validated brand version rules.
+func compileBrandVersionRules(rules []string) []brandVersionRule {
+	compiled := make([]brandVersionRule, 0, len(rules))
+	for _, rule := range rules {
+		r, _ := parseBrandVersionRule(rule)
+		compiled = append(compiled, r)
+	}
+	return compiled
+}
+
+// checkBrandVersion blocks requests listing a brand in
+// Sec-CH-UA-Full-Version-List whose full version fails one of the brand
+// version rules. Requests without the header, or with a malformed one, and
+// brands no rule names are not restricted.
+func (b *BlockUserAgents) checkBrandVersion(e *evaluation) *decision {
+	if len(b.brandVersionRules) == 0 {
+		return nil
+	}
+	value := e.req.Header.Get(fullVersionListHeader)
+	if value == "" {
+		return nil
+	}
+	brands, err := parseFullVersionList(value)
+	if err != nil {
+		return nil
+	}
+	for _, rule := range b.brandVersionRules {
+		for _, bv := range brands {
+			if strings.ToLower(bv.brand) != rule.brand {
+				continue
+			}
+			version, err := parseVersion(bv.version)
+			if err != nil || !satisfiesVersion(compareVersions(version, rule.version), rule.op) {
+				return blockDecision("Unsupported Brand Version: " + rule.source)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package traefik_plugin_block_useragents
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+// chromeFullVersionList is the Sec-CH-UA-Full-Version-List sent by Chrome 131.
+const chromeFullVersionList = `"Google Chrome";v="131.0.6778.86", "Chromium";v="131.0.6778.86", "Not_A Brand";v="24.0.0.0"`
+
+func TestParseFullVersionList(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    []brandVersion
+		wantErr bool
+	}{
+		{"Chrome", chromeFullVersionList, []brandVersion{
+			{"Google Chrome", "131.0.6778.86"}, {"Chromium", "131.0.6778.86"}, {"Not_A Brand", "24.0.0.0"},
+		}, false},
+		{"Edge", `"Microsoft Edge";v="131.0.2903.70", "Chromium";v="131.0.6778.86", "Not_A Brand";v="24.0.0.0"`, []brandVersion{
+			{"Microsoft Edge", "131.0.2903.70"}, {"Chromium", "131.0.6778.86"}, {"Not_A Brand", "24.0.0.0"},
+		}, false},
+		{"GREASE brand with escapes", `"Not\"A\\Brand";v="8.0.0.0"`, []brandVersion{{`Not"A\Brand`, "8.0.0.0"}}, false},
+		{"other parameters", `"Chromium";x;v="131.0";y=1`, []brandVersion{{"Chromium", "131.0"}}, false},
+		{"member without version", `"Chromium", "Google Chrome";v="131.0"`, []brandVersion{{"Google Chrome", "131.0"}}, false},
+		{"empty", "  ", []brandVersion{}, false},
+		{"unterminated string", `"Chromium;v="131"`, nil, true},
+		{"missing comma", `"Chromium";v="131" "Google Chrome";v="131"`, nil, true},
+		{"trailing comma", `"Chromium";v="131",`, nil, true},
+		{"inner list", `("Chromium");v="131"`, nil, true},
+		{"bad escape", `"Chro\mium";v="131"`, nil, true},
+		{"uppercase key", `"Chromium";V="131"`, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseFullVersionList(tt.value)
+			if !reflect.DeepEqual(got, tt.want) || (err != nil) != tt.wantErr {
+				t.Errorf("parseFullVersionList() = %q, %v, want %q, error %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBrandVersionRules(t *testing.T) {
+	config := testConfig()
+	config.BrandVersionRules = []string{"Google Chrome>=131.0.6778", "Microsoft Edge >= 130"}
+	config.ExposeReasonHeader = true
+	h := newTestHandler(t, config, nil)
+
+	tests := []struct {
+		name        string
+		versionList string
+		want        int
+	}{
+		{"current Chrome", chromeFullVersionList, http.StatusOK},
+		{"outdated Chrome patch", `"Google Chrome";v="131.0.6723.116", "Chromium";v="131.0.6723.116"`, http.StatusForbidden},
+		{"outdated Edge", `"Microsoft Edge";v="129.0.2792.89", "Chromium";v="129.0.6668.100"`, http.StatusForbidden},
+		{"unnamed brand", `"Brave";v="120.0.0.0"`, http.StatusOK},
+		{"unparsable version", `"Google Chrome";v="beta"`, http.StatusForbidden},
+		{"malformed header", `"Google Chrome";v="120`, http.StatusOK},
+		{"no header", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var edits []func(*http.Request)
+			if tt.versionList != "" {
+				edits = append(edits, withHeader(fullVersionListHeader, tt.versionList))
+			}
+			if rec := serve(h, chromeUA, edits...); rec.Code != tt.want {
+				t.Errorf("status = %d (%s), want %d", rec.Code, rec.Header().Get("X-Block-Reason"), tt.want)
+			}
+		})
+	}
+}
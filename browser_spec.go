@@ -0,0 +1,272 @@
+package traefik_plugin_block_useragents
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// browserSpecPattern splits a browser spec into the browser name and the
+// version constraint: "Chrome >= 100", "Firefox 90..120", "Safari != 14".
+var browserSpecPattern = regexp.MustCompile(`^\s*([A-Za-z][A-Za-z0-9_-]*)\s*(?:(>=|<=|!=|==|=|>|<)?\s*(\d+)(?:\s*\.\.\s*(\d+))?)?\s*$`)
+
+// browserVersionTokens maps browser names, lowercased, to the regex matching
+// the User-Agent text right before their major version. %s stands for the
+// version pattern. Other names use "<Name>/".
+var browserVersionTokens = map[string]string{
+	"chrome":  `Chrome/%s`,
+	"firefox": `Firefox/%s`,
+	"edge":    `Edg(?:e|A|iOS)?/%s`,
+	"opera":   `OPR/%s`,
+	"safari":  `Version/%s.*Safari/`,
+}
+
+// versionRange is an inclusive range of major versions; max is -1 when the
+// range is unbounded.
+type versionRange struct {
+	min, max int
+}
+
+// ParseBrowserSpec parses a browser spec into an allowed browser rule. A spec
+// is a browser name, optionally followed by a constraint on its major
+// version: an operator (>=, >, <=, <, =, ==, !=) and a version, or an
+// inclusive range "from..to". Chrome, Firefox, Edge, Opera and Safari are
+// matched on their usual version tokens, other names on "<Name>/<version>".
+func ParseBrowserSpec(spec string) (BrowserConfig, error) {
+	m := browserSpecPattern.FindStringSubmatch(spec)
+	if m == nil {
+		return BrowserConfig{}, fmt.Errorf("invalid browser spec %q: expected <name> [<op> <version> | <from>..<to>]", spec)
+	}
+	name, op, from, to := m[1], m[2], m[3], m[4]
+	if op != "" && to != "" {
+		return BrowserConfig{}, fmt.Errorf("invalid browser spec %q: a range takes no operator", spec)
+	}
+
+	token, ok := browserVersionTokens[strings.ToLower(name)]
+	if !ok {
+		token = regexp.QuoteMeta(name) + "/%s"
+	}
+	if from == "" {
+		return BrowserConfig{Name: strings.TrimSpace(spec), Regex: fmt.Sprintf(token, `\d`)}, nil
+	}
+
+	ranges, err := specRanges(op, from, to)
+	if err != nil {
+		return BrowserConfig{}, fmt.Errorf("invalid browser spec %q: %w", spec, err)
+	}
+	alternatives := make([]string, 0)
+	for _, r := range ranges {
+		alternatives = append(alternatives, rangePatterns(r)...)
+	}
+	version := `(?:` + strings.Join(alternatives, "|") + `)\b`
+	return BrowserConfig{Name: strings.TrimSpace(spec), Regex: fmt.Sprintf(token, version)}, nil
+}
+
+// specRanges returns the version ranges selected by a constraint.
+func specRanges(op, from, to string) ([]versionRange, error) {
+	v, err := parseMajorVersion(from)
+	if err != nil {
+		return nil, err
+	}
+	if to != "" {
+		upper, err := parseMajorVersion(to)
+		if err != nil {
+			return nil, err
+		}
+		if upper < v {
+			return nil, fmt.Errorf("range %d..%d is empty", v, upper)
+		}
+		return []versionRange{{v, upper}}, nil
+	}
+	switch op {
+	case "", "=", "==":
+		return []versionRange{{v, v}}, nil
+	case ">=":
+		return []versionRange{{v, -1}}, nil
+	case ">":
+		return []versionRange{{v + 1, -1}}, nil
+	case "<=":
+		return []versionRange{{0, v}}, nil
+	case "<":
+		if v == 0 {
+			return nil, fmt.Errorf("no version is below 0")
+		}
+		return []versionRange{{0, v - 1}}, nil
+	default: // "!="
+		if v == 0 {
+			return []versionRange{{1, -1}}, nil
+		}
+		return []versionRange{{0, v - 1}, {v + 1, -1}}, nil
+	}
+}
+
+// parseMajorVersion parses a major version number, bounded to keep the
+// generated patterns small.
+func parseMajorVersion(s string) (int, error) {
+	v, err := strconv.Atoi(s)
+	if err != nil || v > math.MaxInt32 {
+		return 0, fmt.Errorf("invalid version %q", s)
+	}
+	return v, nil
+}
+
+// rangePatterns returns regex alternatives matching the decimal numbers of a
+// range, without leading zeros.
+func rangePatterns(r versionRange) []string {
+	patterns := make([]string, 0)
+	upper := r.max
+	if upper < 0 {
+		upper = int(math.Pow10(len(strconv.Itoa(r.min)))) - 1
+	}
+	for digits := len(strconv.Itoa(r.min)); digits <= len(strconv.Itoa(upper)); digits++ {
+		lo, hi := int(math.Pow10(digits-1)), int(math.Pow10(digits))-1
+		if digits == 1 {
+			lo = 0
+		}
+		lo, hi = max(lo, r.min), min(hi, upper)
+		if lo <= hi {
+			patterns = append(patterns, sameLengthPatterns(strconv.Itoa(lo), strconv.Itoa(hi))...)
+		}
+	}
+	if r.max < 0 {
+		// Any number with more digits than the lower bound
+		patterns = append(patterns, fmt.Sprintf(`[1-9]\d{%d,}`, len(strconv.Itoa(r.min))))
+	}
+	return patterns
+}
+
+// sameLengthPatterns returns regex alternatives matching the numbers from lo
+// to hi, both with the same number of digits.
+func sameLengthPatterns(lo, hi string) []string {
+	if lo == "" {
+		return []string{""}
+	}
+	if lo[0] == hi[0] {
+		patterns := sameLengthPatterns(lo[1:], hi[1:])
+		for i, p := range patterns {
+			patterns[i] = lo[:1] + p
+		}
+		return patterns
+	}
+
+	rest := len(lo) - 1
+	lowFull := strings.Trim(lo[1:], "0") == ""
+	highFull := strings.Trim(hi[1:], "9") == ""
+	patterns := make([]string, 0)
+	first, last := lo[0], hi[0]
+	if !lowFull {
+		for _, p := range sameLengthPatterns(lo[1:], strings.Repeat("9", rest)) {
+			patterns = append(patterns, lo[:1]+p)
+		}
+		first++
+	}
+	if !highFull {
+		last--
+	}
+	if first <= last {
+		p := digitClass(first, last)
+		switch {
+		case rest == 1:
+			p += `\d`
+		case rest > 1:
+			p += fmt.Sprintf(`\d{%d}`, rest)
+		}
+		patterns = append(patterns, p)
+	}
+	if !highFull {
+		for _, p := range sameLengthPatterns(strings.Repeat("0", rest), hi[1:]) {
+			patterns = append(patterns, hi[:1]+p)
+		}
+	}
+	return patterns
+}
+
+// digitClass returns a regex matching one digit from first to last.
+func digitClass(first, last byte) string {
+	if first == last {
+		return string(first)
+	}
+	return "[" + string(first) + "-" + string(last) + "]"
+}
+
+// withBrowserSpecs returns a copy of config with its BrowserSpecs parsed and
+// appended to AllowedBrowsers.
+func withBrowserSpecs(config *Config) (*Config, error) {
+	if len(config.BrowserSpecs) == 0 {
+		return config, nil
+	}
+	merged := *config
+	merged.AllowedBrowsers = append([]BrowserConfig{}, config.AllowedBrowsers...)
+	for _, spec := range config.BrowserSpecs {
+		bc, err := ParseBrowserSpec(spec)
+		if err != nil {
+			return nil, err
+		}
+		merged.AllowedBrowsers = append(merged.AllowedBrowsers, bc)
+	}
+	merged.BrowserSpecs = nil
+	return &merged, nil
+}
+
+// namesPattern returns the regex matching any of the browser Names, each on
+// its version token as in ParseBrowserSpec, with Version as the shared
+// constraint: "Chrome", "Chromium" and "Edg" with ">= 100" match any of them
+// from version 100 on.
+func (bc BrowserConfig) namesPattern() (string, error) {
+	alternatives := make([]string, 0, len(bc.Names))
+	for _, name := range bc.Names {
+		spec, err := ParseBrowserSpec(name + " " + bc.Version)
+		if err != nil {
+			return "", fmt.Errorf("invalid names %q: %w", bc.Names, err)
+		}
+		alternatives = append(alternatives, spec.Regex)
+	}
+	return `(?:` + strings.Join(alternatives, "|") + `)`, nil
+}
+
+// withBrowserNames returns a copy of config with the Regex of the browser
+// entries listing Names generated from them. Entries setting both are
+// rejected.
+func withBrowserNames(config *Config) (*Config, error) {
+	merged := *config
+	for _, list := range []*[]BrowserConfig{&merged.AllowedBrowsers, &merged.BlockedBrowsers, &merged.DenyBrowsers, &merged.SoftAllowedBrowsers} {
+		expanded, err := expandBrowserNames(*list)
+		if err != nil {
+			return nil, err
+		}
+		*list = expanded
+	}
+	return &merged, nil
+}
+
+// expandBrowserNames generates the Regex of the entries listing Names,
+// returning the list itself when none does.
+func expandBrowserNames(list []BrowserConfig) ([]BrowserConfig, error) {
+	var expanded []BrowserConfig
+	for i, bc := range list {
+		if len(bc.Names) == 0 {
+			continue
+		}
+		if bc.Regex != "" {
+			return nil, fmt.Errorf("regex and names are mutually exclusive for browser: %s", bc.Name)
+		}
+		pattern, err := bc.namesPattern()
+		if err != nil {
+			return nil, err
+		}
+		if expanded == nil {
+			expanded = append([]BrowserConfig{}, list...)
+		}
+		if bc.Name == "" {
+			expanded[i].Name = strings.Join(bc.Names, ", ")
+		}
+		expanded[i].Regex = pattern
+		expanded[i].Names = nil
+	}
+	if expanded == nil {
+		return list, nil
+	}
+	return expanded, nil
+}
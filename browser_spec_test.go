@@ -0,0 +1,155 @@
+package traefik_plugin_block_useragents
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestParseBrowserSpec(t *testing.T) {
+	tests := []struct {
+		spec    string
+		match   []string
+		noMatch []string
+	}{
+		{"Chrome", []string{"Chrome/1.0", chromeUA}, []string{firefoxUA}},
+		{"Chrome >= 100", []string{"Chrome/100.0", "Chrome/131.0", "Chrome/1000.0"}, []string{"Chrome/99.0", "Chrome/10.0"}},
+		{"Chrome>99", []string{"Chrome/100.0"}, []string{"Chrome/99.0"}},
+		{"firefox <= 90", []string{"Firefox/90.0", "Firefox/9.0"}, []string{"Firefox/91.0", "Firefox/900.0"}},
+		{"Firefox < 10", []string{"Firefox/9.0"}, []string{"Firefox/10.0"}},
+		{"Firefox 90..120", []string{"Firefox/90.0", "Firefox/105.0", "Firefox/120.0"}, []string{"Firefox/89.0", "Firefox/121.0"}},
+		{"Safari != 14", []string{"Version/13.1 Safari/605", "Version/15.0 Safari/605"}, []string{"Version/14.1 Safari/605"}},
+		{"Safari = 17", []string{safariUA}, []string{"Version/1.0 Safari/605", "Version/170.0 Safari/605"}},
+		{"Edge >= 120", []string{"Edg/131.0", "EdgA/120.0", "EdgiOS/125.0"}, []string{"Edg/119.0"}},
+		{"Opera == 100", []string{"OPR/100.0"}, []string{"OPR/101.0"}},
+		{"Vivaldi >= 6", []string{"Vivaldi/6.5"}, []string{"Vivaldi/5.9", "Chrome/131.0"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			bc, err := ParseBrowserSpec(tt.spec)
+			if err != nil {
+				t.Fatalf("ParseBrowserSpec() error: %v", err)
+			}
+			re := regexp.MustCompile(bc.Regex)
+			for _, userAgent := range tt.match {
+				if !re.MatchString(userAgent) {
+					t.Errorf("%s does not match %q", bc.Regex, userAgent)
+				}
+			}
+			for _, userAgent := range tt.noMatch {
+				if re.MatchString(userAgent) {
+					t.Errorf("%s matches %q", bc.Regex, userAgent)
+				}
+			}
+		})
+	}
+}
+
+func TestParseBrowserSpecErrors(t *testing.T) {
+	tests := []struct {
+		spec string
+		want string
+	}{
+		{"", "expected <name>"},
+		{"Chrome >=", "expected <name>"},
+		{"Chrome ~> 100", "expected <name>"},
+		{"1Chrome", "expected <name>"},
+		{"Chrome >= 90..100", "a range takes no operator"},
+		{"Chrome 120..90", "is empty"},
+		{"Chrome < 0", "no version is below 0"},
+		{"Chrome >= 99999999999", "invalid version"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			_, err := ParseBrowserSpec(tt.spec)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ParseBrowserSpec() = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRangePatterns(t *testing.T) {
+	ranges := []versionRange{{0, 0}, {0, 9}, {5, 5}, {7, 42}, {90, 120}, {99, 1000}, {100, -1}, {0, -1}, {123, 987}}
+	for _, r := range ranges {
+		t.Run(fmt.Sprint(r), func(t *testing.T) {
+			re := regexp.MustCompile(`^(?:` + strings.Join(rangePatterns(r), "|") + `)$`)
+			for v := 0; v <= 2000; v++ {
+				want := v >= r.min && (r.max < 0 || v <= r.max)
+				if got := re.MatchString(fmt.Sprint(v)); got != want {
+					t.Fatalf("%s matches %d = %v, want %v", re, v, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestBrowserNames(t *testing.T) {
+	tests := []struct {
+		name    string
+		entry   BrowserConfig
+		match   []string
+		noMatch []string
+	}{
+		{
+			name:    "names with a shared version",
+			entry:   BrowserConfig{Names: []string{"Chrome", "Chromium", "Edge"}, Version: ">= 120"},
+			match:   []string{chromeUA, "Chromium/120.0", "Edg/125.0"},
+			noMatch: []string{"Chrome/119.0", "Edg/100.0", firefoxUA},
+		},
+		{
+			name:    "names without version",
+			entry:   BrowserConfig{Names: []string{"Firefox", "Safari"}},
+			match:   []string{firefoxUA, safariUA},
+			noMatch: []string{chromeUA[:strings.Index(chromeUA, " Safari")]},
+		},
+		{
+			name:    "names with a range",
+			entry:   BrowserConfig{Names: []string{"Firefox"}, Version: "115..128"},
+			match:   []string{firefoxUA, "Firefox/115.0"},
+			noMatch: []string{"Firefox/114.0", "Firefox/129.0"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expanded, err := expandBrowserNames([]BrowserConfig{tt.entry})
+			if err != nil {
+				t.Fatalf("expandBrowserNames() error: %v", err)
+			}
+			if expanded[0].Name != strings.Join(tt.entry.Names, ", ") || expanded[0].Names != nil {
+				t.Errorf("expanded entry = %+v", expanded[0])
+			}
+			re := regexp.MustCompile(expanded[0].Regex)
+			for _, userAgent := range tt.match {
+				if !re.MatchString(userAgent) {
+					t.Errorf("%s does not match %q", re, userAgent)
+				}
+			}
+			for _, userAgent := range tt.noMatch {
+				if re.MatchString(userAgent) {
+					t.Errorf("%s matches %q", re, userAgent)
+				}
+			}
+		})
+	}
+}
+
+func TestBrowserNamesErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry BrowserConfig
+		want  string
+	}{
+		{"regex and names", BrowserConfig{Name: "Chrome", Regex: "Chrome", Names: []string{"Chrome"}}, "mutually exclusive"},
+		{"invalid version", BrowserConfig{Names: []string{"Chrome"}, Version: "~> 100"}, "invalid names"},
+		{"invalid name", BrowserConfig{Names: []string{"Chrome 1"}, Version: ">= 100"}, "invalid names"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := expandBrowserNames([]BrowserConfig{tt.entry}); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expandBrowserNames() = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package traefik_plugin_block_useragents
+
+import (
+	"crypto/subtle"
+	"net/http"
+)
+
+// validateBypass checks that the bypass header and its tokens are set together.
+func validateBypass(config *Config) error {
+	if config.BypassHeaderName == "" && len(config.BypassHeaderValues) == 0 {
+		return nil
+	}
+	var errs ConfigErrors
+	if config.BypassHeaderName == "" {
+		errs.add(configErrorf(ErrMissingSetting, "bypassHeaderName must be provided when bypassHeaderValues is set"))
+	}
+	if len(config.BypassHeaderValues) == 0 {
+		errs.add(configErrorf(ErrMissingSetting, "bypassHeaderValues must be provided when bypassHeaderName is set"))
+	}
+	for _, token := range config.BypassHeaderValues {
+		if token == "" {
+			errs.add(configErrorf(ErrInvalidValue, "bypassHeaderValues must not contain an empty value"))
+			break
+		}
+	}
+	return errs.err()
+}
+
+// bypassed reports whether the request carries a valid bypass token. The
+// header is removed in any case so it never reaches the backend.
+func (b *BlockUserAgents) bypassed(req *http.Request) bool {
+	if b.bypassHeader == "" {
+		return false
+	}
+	value := req.Header.Get(b.bypassHeader)
+	req.Header.Del(b.bypassHeader)
+	if value == "" {
+		return false
+	}
+	// Compare against every token so the timing does not reveal which one matched
+	match := 0
+	for _, token := range b.bypassTokens {
+		match |= subtle.ConstantTimeCompare([]byte(value), []byte(token))
+	}
+	return match == 1
+}
@@ -0,0 +1,72 @@
+package traefik_plugin_block_useragents
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestBypassHeader(t *testing.T) {
+	config := testConfig()
+	config.BypassHeaderName = "X-Bypass"
+	config.BypassHeaderValues = []string{"first-token", "second-token"}
+
+	var forwarded http.Header
+	next := http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		forwarded = req.Header.Clone()
+		res.WriteHeader(http.StatusOK)
+	})
+	h := newTestHandler(t, config, next)
+
+	tests := []struct {
+		name      string
+		userAgent string
+		token     string
+		want      int
+	}{
+		{"absent", curlUA, "", http.StatusForbidden},
+		{"first token", curlUA, "first-token", http.StatusOK},
+		{"second token", curlUA, "second-token", http.StatusOK},
+		{"wrong token", curlUA, "third-token", http.StatusForbidden},
+		{"token prefix", curlUA, "first", http.StatusForbidden},
+		{"wrong token on allowed browser", chromeUA, "third-token", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forwarded = nil
+			var edits []func(*http.Request)
+			if tt.token != "" {
+				edits = append(edits, withHeader("X-Bypass", tt.token))
+			}
+			if rec := serve(h, tt.userAgent, edits...); rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if forwarded != nil && forwarded.Get("X-Bypass") != "" {
+				t.Error("bypass header forwarded to the backend")
+			}
+		})
+	}
+}
+
+func TestValidateBypass(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		values  []string
+		wantErr bool
+	}{
+		{"unset", "", nil, false},
+		{"set", "X-Bypass", []string{"token"}, false},
+		{"header without values", "X-Bypass", nil, true},
+		{"values without header", "", []string{"token"}, true},
+		{"empty value", "X-Bypass", []string{"token", ""}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := CreateConfig()
+			config.BypassHeaderName, config.BypassHeaderValues = tt.header, tt.values
+			if err := validateBypass(config); (err != nil) != tt.wantErr {
+				t.Errorf("validateBypass() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
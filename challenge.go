@@ -0,0 +1,143 @@
+package traefik_plugin_block_useragents
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultChallengeCookieName is used when ChallengeCookieName is not set.
+const defaultChallengeCookieName = "ua_challenge"
+
+// defaultChallengeTTL is how long a passed challenge stays valid when
+// ChallengeTTL is not set.
+const defaultChallengeTTL = 24 * time.Hour
+
+// maxChallengeClockSkew tolerates cookies issued slightly in the future, by
+// another instance whose clock runs ahead.
+const maxChallengeClockSkew = time.Minute
+
+// cookieNamePattern restricts challenge cookie names to safe token characters.
+var cookieNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// challengePage is the built-in JavaScript challenge. It sets the challenge
+// cookie and reloads the page, which clients without JavaScript won't do.
+const challengePage = `<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Checking your browser</title></head>
+<body>
+<noscript>Please enable JavaScript to continue.</noscript>
+<script>
+document.cookie = "%s=%s; path=/; max-age=%d; SameSite=Lax";
+location.reload();
+</script>
+</body></html>
+`
+
+// validateChallenge checks the challenge settings.
+func validateChallenge(config *Config) error {
+	if len(config.ChallengeBrowsers) == 0 {
+		return nil
+	}
+	var errs ConfigErrors
+	if config.ChallengeSecret == "" {
+		errs.add(configErrorf(ErrMissingSetting, "challengeSecret must be provided when challengeBrowsers is set"))
+	}
+	if config.ChallengeCookieName != "" && !cookieNamePattern.MatchString(config.ChallengeCookieName) {
+		errs.add(configErrorf(ErrInvalidValue, "invalid challengeCookieName %q", config.ChallengeCookieName))
+	}
+	if config.ChallengeTTL != "" {
+		ttl, err := time.ParseDuration(config.ChallengeTTL)
+		switch {
+		case err != nil:
+			errs.add(configErrorf(ErrInvalidValue, "invalid challengeTtl %q: %w", config.ChallengeTTL, err))
+		case ttl < time.Second:
+			errs.add(configErrorf(ErrInvalidValue, "challengeTtl must be at least 1s"))
+		}
+	}
+	return errs.err()
+}
+
+// newChallengeTTL returns the challenge lifetime of a validated config.
+func newChallengeTTL(config *Config) time.Duration {
+	if config.ChallengeTTL == "" {
+		return defaultChallengeTTL
+	}
+	ttl, _ := time.ParseDuration(config.ChallengeTTL)
+	return ttl
+}
+
+// challengeMAC signs the issue time of a challenge cookie for the client IP
+// and User-Agent of the request with the challenge secret.
+func (b *BlockUserAgents) challengeMAC(req *http.Request, issued string) []byte {
+	mac := hmac.New(sha256.New, []byte(b.challengeSecret))
+	mac.Write([]byte(b.clientIP(req) + "\x00" + req.UserAgent() + "\x00" + issued))
+	return mac.Sum(nil)
+}
+
+// challengeToken returns the cookie value issued to the client of the
+// request: the issue time in Unix seconds and its signature, dot-separated.
+func (b *BlockUserAgents) challengeToken(req *http.Request) string {
+	issued := strconv.FormatInt(b.clock.Now().Unix(), 10)
+	return issued + "." + hex.EncodeToString(b.challengeMAC(req, issued))
+}
+
+// challengePassed reports whether the request carries a challenge cookie
+// issued to the same client IP and User-Agent within the challenge TTL. The
+// signature is compared in constant time. A cookie replayed from another IP
+// or User-Agent, tampered with, expired or issued in the future fails.
+func (b *BlockUserAgents) challengePassed(req *http.Request) bool {
+	cookie, err := req.Cookie(b.challengeCookieName)
+	if err != nil {
+		return false
+	}
+	issued, signature, ok := strings.Cut(cookie.Value, ".")
+	if !ok {
+		return false
+	}
+	unix, err := strconv.ParseInt(issued, 10, 64)
+	if err != nil {
+		return false
+	}
+	age := b.clock.Now().Sub(time.Unix(unix, 0))
+	if age > b.challengeTTL || age < -maxChallengeClockSkew {
+		return false
+	}
+	mac, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(mac, b.challengeMAC(req, issued))
+}
+
+// hasChallengeCookie reports whether the request carries a challenge cookie.
+// Such requests skip the decision cache: whether the cookie is valid also
+// depends on the client IP and the time.
+func (b *BlockUserAgents) hasChallengeCookie(req *http.Request) bool {
+	if len(b.challengeRegexps) == 0 {
+		return false
+	}
+	_, err := req.Cookie(b.challengeCookieName)
+	return err == nil
+}
+
+// writeChallenge responds with the JavaScript challenge page.
+func (b *BlockUserAgents) writeChallenge(res http.ResponseWriter, req *http.Request) {
+	body := fmt.Sprintf(challengePage, b.challengeCookieName, b.challengeToken(req), int(b.challengeTTL.Seconds()))
+	res.Header().Set("Content-Type", "text/html; charset=utf-8")
+	res.Header().Set("Cache-Control", "no-store")
+	res.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	res.WriteHeader(http.StatusOK)
+	if req.Method == http.MethodHead {
+		return
+	}
+	if _, err := res.Write([]byte(body)); err != nil {
+		log.Printf("%s: error writing challenge response: %v", b.name, err)
+	}
+}
@@ -0,0 +1,122 @@
+package traefik_plugin_block_useragents
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// challengeCookiePattern extracts the cookie value set by the challenge page.
+var challengeCookiePattern = regexp.MustCompile(`ua_challenge=([^;]+);`)
+
+func TestChallengeCookie(t *testing.T) {
+	config := testConfig()
+	config.ChallengeBrowsers = []string{"^curl/"}
+	config.ChallengeSecret = "secret"
+	config.ChallengeTTL = "1h"
+	next := http.HandlerFunc(func(res http.ResponseWriter, _ *http.Request) {
+		res.WriteHeader(http.StatusNoContent)
+	})
+
+	tests := []struct {
+		name      string
+		token     func(token string) string
+		userAgent string
+		remote    string
+		advance   time.Duration
+		want      int
+	}{
+		{name: "valid", want: http.StatusNoContent},
+		{name: "near expiry", advance: 59 * time.Minute, want: http.StatusNoContent},
+		{name: "expired", advance: 61 * time.Minute, want: http.StatusOK},
+		{name: "issued in the future", advance: -2 * time.Minute, want: http.StatusOK},
+		{name: "tampered signature", token: func(token string) string { return token[:len(token)-1] + "0" }, want: http.StatusOK},
+		{name: "tampered issue time", token: func(token string) string {
+			issued, signature, _ := strings.Cut(token, ".")
+			return issued[:len(issued)-1] + "9." + signature
+		}, want: http.StatusOK},
+		{name: "malformed", token: func(string) string { return "garbage" }, want: http.StatusOK},
+		{name: "other User-Agent", userAgent: "curl/8.1", want: http.StatusOK},
+		{name: "other IP", remote: "198.51.100.1:1234", want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newFakeClock()
+			h := newTestHandler(t, config, next)
+			h.clock = clock
+
+			page := serve(h, curlUA)
+			m := challengeCookiePattern.FindStringSubmatch(page.Body.String())
+			if page.Code != http.StatusOK || m == nil {
+				t.Fatalf("challenge page = %d %q", page.Code, page.Body)
+			}
+			token := m[1]
+			if tt.token != nil {
+				token = tt.token(token)
+			}
+			userAgent := curlUA
+			if tt.userAgent != "" {
+				userAgent = tt.userAgent
+			}
+			clock.advance(tt.advance)
+
+			rec := serve(h, userAgent, func(req *http.Request) {
+				req.AddCookie(&http.Cookie{Name: "ua_challenge", Value: token})
+				if tt.remote != "" {
+					req.RemoteAddr = tt.remote
+				}
+			})
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestChallengeSkipsOtherBrowsers(t *testing.T) {
+	config := testConfig()
+	config.ChallengeBrowsers = []string{"^curl/"}
+	config.ChallengeSecret = "secret"
+	h := newTestHandler(t, config, nil)
+
+	if rec := serve(h, chromeUA); rec.Code != http.StatusOK || rec.Body.Len() != 0 {
+		t.Errorf("allowed browser got %d %q, want forwarded", rec.Code, rec.Body)
+	}
+	if rec := serve(h, "Wget/1.21"); rec.Code != http.StatusForbidden {
+		t.Errorf("unlisted tool got %d, want %d", rec.Code, http.StatusForbidden)
+	}
+}
+
+func TestChallengeCookieName(t *testing.T) {
+	config := testConfig()
+	config.ChallengeBrowsers = []string{"^curl/"}
+	config.ChallengeSecret = "secret"
+	config.ChallengeCookieName = "bot_check"
+	next := http.HandlerFunc(func(res http.ResponseWriter, _ *http.Request) {
+		res.WriteHeader(http.StatusNoContent)
+	})
+	h := newTestHandler(t, config, next)
+
+	page := serve(h, curlUA)
+	m := regexp.MustCompile(`bot_check=([^;]+);`).FindStringSubmatch(page.Body.String())
+	if page.Code != http.StatusOK || m == nil {
+		t.Fatalf("challenge page = %d %q, want the bot_check cookie", page.Code, page.Body)
+	}
+	tests := []struct {
+		cookie string
+		want   int
+	}{
+		{"bot_check", http.StatusNoContent},
+		{"ua_challenge", http.StatusOK}, // The default name is no longer read
+	}
+	for _, tt := range tests {
+		rec := serve(h, curlUA, func(req *http.Request) {
+			req.AddCookie(&http.Cookie{Name: tt.cookie, Value: m[1]})
+		})
+		if rec.Code != tt.want {
+			t.Errorf("cookie %s: status = %d, want %d", tt.cookie, rec.Code, tt.want)
+		}
+	}
+}
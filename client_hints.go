@@ -0,0 +1,50 @@
+package traefik_plugin_block_useragents
+
+import (
+	"net/http"
+	"strings"
+)
+
+// identityHints lists the User-Agent Client Hints appended to the combined
+// identity, in order.
+var identityHints = []string{
+	"Sec-CH-UA",
+	"Sec-CH-UA-Full-Version-List",
+	"Sec-CH-UA-Mobile",
+	"Sec-CH-UA-Model",
+	"Sec-CH-UA-Platform",
+	"Sec-CH-UA-Platform-Version",
+}
+
+// clientHints renders the client hints present on the request as
+// " | <header>: <value>" segments, in identityHints order.
+func clientHints(req *http.Request) string {
+	var sb strings.Builder
+	for _, header := range identityHints {
+		if value := req.Header.Get(header); value != "" {
+			sb.WriteString(" | ")
+			sb.WriteString(header)
+			sb.WriteString(": ")
+			sb.WriteString(value)
+		}
+	}
+	return sb.String()
+}
+
+// browserIdentities returns the values the browser rules are matched
+// against: the User-Agent values, each followed by the client hints when
+// MatchCombinedIdentity is set.
+func (b *BlockUserAgents) browserIdentities(e *evaluation) []string {
+	if !b.matchCombinedIdentity {
+		return e.userAgents
+	}
+	hints := clientHints(e.req)
+	if hints == "" {
+		return e.userAgents
+	}
+	identities := make([]string, 0, len(e.userAgents))
+	for _, userAgent := range e.userAgents {
+		identities = append(identities, userAgent+hints)
+	}
+	return identities
+}
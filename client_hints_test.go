@@ -0,0 +1,38 @@
+package traefik_plugin_block_useragents
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestMatchCombinedIdentity(t *testing.T) {
+	edgeHints := []func(*http.Request){
+		withHeader("Sec-CH-UA", `"Microsoft Edge";v="131", "Chromium";v="131"`),
+		withHeader("Sec-CH-UA-Platform", `"Windows"`),
+	}
+	tests := []struct {
+		name     string
+		combined bool
+		edits    []func(*http.Request)
+		want     int
+	}{
+		{"hints matched", true, edgeHints, http.StatusOK},
+		{"hints missing", true, nil, http.StatusForbidden},
+		{"hints ignored when disabled", false, edgeHints, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := CreateConfig()
+			// Edge on Windows, told apart from Chrome by its client hints
+			config.AllowedBrowsers = []BrowserConfig{{Name: "Edge", Regex: `Chrome/\d+.* \| Sec-CH-UA: .*"Microsoft Edge".* \| Sec-CH-UA-Platform: "Windows"`}}
+			config.MatchCombinedIdentity = tt.combined
+			config.CacheSize = 8 // The hints are part of the cache key
+			h := newTestHandler(t, config, nil)
+
+			serve(h, chromeUA) // Cached without hints
+			if rec := serve(h, chromeUA, tt.edits...); rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
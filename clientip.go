@@ -0,0 +1,141 @@
+package traefik_plugin_block_useragents
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// remoteIP extracts the IP from a request remote address.
+func remoteIP(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr
+	}
+	return host
+}
+
+// clientIP returns the client IP of the request. With trustForwardedHeader
+// set, the client IP headers are tried in order and the first valid IP is
+// used. With trusted proxies configured, the headers are only honored when
+// the connection comes from a trusted proxy, and the X-Forwarded-For chain is
+// walked from right to left, skipping trusted proxies, so entries prepended
+// by the client cannot spoof the IP.
+func (b *BlockUserAgents) clientIP(req *http.Request) string {
+	addr := remoteIP(req.RemoteAddr)
+	if !b.trustsForwardedHeaders(req) {
+		return addr
+	}
+	for _, header := range b.clientIPHeaders {
+		var ip string
+		if header == "X-Forwarded-For" {
+			ip = b.forwardedFor(req)
+		} else {
+			first, _, _ := strings.Cut(req.Header.Get(header), ",")
+			ip = strings.TrimSpace(first)
+		}
+		if net.ParseIP(ip) != nil {
+			return ip
+		}
+	}
+	return addr
+}
+
+// trustsForwardedHeaders reports whether the headers set by proxies can be
+// trusted: trustForwardedHeader is set and, with trusted proxies configured,
+// the connection comes from one of them.
+func (b *BlockUserAgents) trustsForwardedHeaders(req *http.Request) bool {
+	if !b.trustForwardedHeader {
+		return false
+	}
+	return len(b.trustedProxies) == 0 || containsIP(b.trustedProxies, remoteIP(req.RemoteAddr))
+}
+
+// forwardedFor returns the client IP from X-Forwarded-For: the first entry,
+// or with trusted proxies, the rightmost entry that is not a trusted proxy.
+// It returns an empty string when no IP can be resolved.
+func (b *BlockUserAgents) forwardedFor(req *http.Request) string {
+	if len(b.trustedProxies) == 0 {
+		first, _, _ := strings.Cut(req.Header.Get("X-Forwarded-For"), ",")
+		return strings.TrimSpace(first)
+	}
+	chain := forwardedChain(req)
+	ip := ""
+	for i := len(chain) - 1; i >= 0; i-- {
+		if net.ParseIP(chain[i]) == nil {
+			break // A malformed entry ends the part of the chain that can be trusted
+		}
+		if !containsIP(b.trustedProxies, chain[i]) {
+			return chain[i]
+		}
+		ip = chain[i]
+	}
+	return ip
+}
+
+// forwardedChain returns the X-Forwarded-For entries of all header lines, in order.
+func forwardedChain(req *http.Request) []string {
+	chain := make([]string, 0)
+	for _, value := range req.Header.Values("X-Forwarded-For") {
+		for _, entry := range strings.Split(value, ",") {
+			chain = append(chain, strings.TrimSpace(entry))
+		}
+	}
+	return chain
+}
+
+// headerNamePattern matches valid HTTP header names (RFC 9110 tokens).
+var headerNamePattern = regexp.MustCompile("^[!#$%&'*+.^_`|~0-9A-Za-z-]+$")
+
+// validateClientIPHeaders checks the client IP header names.
+func validateClientIPHeaders(headers []string) error {
+	var errs ConfigErrors
+	for _, header := range headers {
+		if !headerNamePattern.MatchString(header) {
+			errs.add(configErrorf(ErrInvalidValue, "invalid client IP header name %q", header))
+		}
+	}
+	return errs.err()
+}
+
+// parseIPNets parses a list of IPs and CIDRs. Single IPs become host networks.
+func parseIPNets(entries []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if strings.Contains(entry, "/") {
+			_, ipNet, err := net.ParseCIDR(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid CIDR %q: %w", entry, err)
+			}
+			nets = append(nets, ipNet)
+			continue
+		}
+		ip := net.ParseIP(entry)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP %q", entry)
+		}
+		bits := 8 * net.IPv6len
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, 8*net.IPv4len
+		}
+		nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+	}
+	return nets, nil
+}
+
+// containsIP reports whether ip falls within one of the networks.
+func containsIP(nets []*net.IPNet, ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, ipNet := range nets {
+		if ipNet.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,154 @@
+package traefik_plugin_block_useragents
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDeniedIPs(t *testing.T) {
+	tests := []struct {
+		name       string
+		trust      bool
+		remoteAddr string
+		forwarded  string
+		want       int
+	}{
+		{"allowed IP", false, "192.0.2.1:1234", "", http.StatusOK},
+		{"denied CIDR", false, "198.51.100.7:1234", "", http.StatusForbidden},
+		{"denied IPv6 address", false, "[2001:db8::1]:1234", "", http.StatusForbidden},
+		{"untrusted X-Forwarded-For", false, "192.0.2.1:1234", "198.51.100.7", http.StatusOK},
+		{"trusted X-Forwarded-For", true, "192.0.2.1:1234", "198.51.100.7, 192.0.2.1", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.DeniedIPs = []string{"198.51.100.0/24", "2001:db8::1"}
+			config.TrustForwardedHeader = tt.trust
+			h := newTestHandler(t, config, nil)
+			rec := serve(h, chromeUA, func(req *http.Request) {
+				req.RemoteAddr = tt.remoteAddr
+				if tt.forwarded != "" {
+					req.Header.Set("X-Forwarded-For", tt.forwarded)
+				}
+			})
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+
+	config := testConfig()
+	config.DeniedIPs = []string{"198.51.100.0/33"}
+	if err := ValidateConfig(config); !errors.Is(err, ErrInvalidValue) {
+		t.Errorf("ValidateConfig with an invalid CIDR = %v, want %v", err, ErrInvalidValue)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		trust      bool
+		proxies    []string
+		remoteAddr string
+		forwarded  []string // X-Forwarded-For header lines
+		want       string
+	}{
+		{"untrusted headers", false, nil, "192.0.2.1:1234", []string{"203.0.113.7"}, "192.0.2.1"},
+		{"trusted without proxies", true, nil, "192.0.2.1:1234", []string{"203.0.113.7, 10.0.0.1"}, "203.0.113.7"},
+		{"untrusted connection", true, []string{"10.0.0.0/8"}, "192.0.2.1:1234", []string{"203.0.113.7"}, "192.0.2.1"},
+		{"trusted proxy", true, []string{"10.0.0.0/8"}, "10.0.0.2:1234", []string{"203.0.113.7"}, "203.0.113.7"},
+		{"spoofed entry prepended", true, []string{"10.0.0.0/8"}, "10.0.0.2:1234", []string{"127.0.0.1, 203.0.113.7"}, "203.0.113.7"},
+		{"proxy chain", true, []string{"10.0.0.0/8"}, "10.0.0.2:1234", []string{"127.0.0.1, 203.0.113.7, 10.0.0.3"}, "203.0.113.7"},
+		{"chain over header lines", true, []string{"10.0.0.0/8"}, "10.0.0.2:1234", []string{"127.0.0.1, 203.0.113.7", "10.0.0.3"}, "203.0.113.7"},
+		{"malformed entry ends chain", true, []string{"10.0.0.0/8"}, "10.0.0.2:1234", []string{"203.0.113.7, bogus, 10.0.0.3"}, "10.0.0.3"},
+		{"only proxies", true, []string{"10.0.0.0/8"}, "10.0.0.2:1234", []string{"10.0.0.4, 10.0.0.3"}, "10.0.0.4"},
+		{"no header", true, []string{"10.0.0.0/8"}, "10.0.0.2:1234", nil, "10.0.0.2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.TrustForwardedHeader = tt.trust
+			config.TrustedProxies = tt.proxies
+			h := newTestHandler(t, config, nil)
+
+			req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.forwarded {
+				req.Header.Add("X-Forwarded-For", value)
+			}
+			if got := h.clientIP(req); got != tt.want {
+				t.Errorf("clientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeniedIPsWithSpoofedForwardedFor(t *testing.T) {
+	config := testConfig()
+	config.TrustForwardedHeader = true
+	config.TrustedProxies = []string{"10.0.0.0/8"}
+	config.DeniedIPs = []string{"203.0.113.7"}
+	h := newTestHandler(t, config, nil)
+
+	withChain := func(chain string) func(*http.Request) {
+		return func(req *http.Request) {
+			req.RemoteAddr = "10.0.0.2:1234"
+			req.Header.Set("X-Forwarded-For", chain)
+		}
+	}
+	tests := []struct {
+		chain string
+		want  int
+	}{
+		{"203.0.113.7", http.StatusForbidden},
+		{"198.51.100.1, 203.0.113.7", http.StatusForbidden},
+		{"203.0.113.7, 198.51.100.1", http.StatusOK},
+	}
+	for _, tt := range tests {
+		if rec := serve(h, chromeUA, withChain(tt.chain)); rec.Code != tt.want {
+			t.Errorf("X-Forwarded-For %q: status = %d, want %d", tt.chain, rec.Code, tt.want)
+		}
+	}
+}
+
+func TestClientIPHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		trust   bool
+		headers map[string]string
+		want    string
+	}{
+		{"first header", true, map[string]string{"CF-Connecting-IP": "203.0.113.7", "X-Real-IP": "198.51.100.1"}, "203.0.113.7"},
+		{"next header when the first is missing", true, map[string]string{"X-Real-IP": "198.51.100.1"}, "198.51.100.1"},
+		{"next header when the first is invalid", true, map[string]string{"CF-Connecting-IP": "bogus", "X-Real-IP": "198.51.100.1"}, "198.51.100.1"},
+		{"first entry of a list", true, map[string]string{"X-Real-IP": "198.51.100.1, 10.0.0.1"}, "198.51.100.1"},
+		{"X-Forwarded-For not tried unless listed", true, map[string]string{"X-Forwarded-For": "203.0.113.7"}, "192.0.2.1"},
+		{"untrusted headers", false, map[string]string{"CF-Connecting-IP": "203.0.113.7"}, "192.0.2.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.TrustForwardedHeader = tt.trust
+			if tt.trust {
+				config.ClientIPHeaders = []string{"cf-connecting-ip", "X-Real-IP"}
+			}
+			h := newTestHandler(t, config, nil)
+
+			req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			if got := h.clientIP(req); got != tt.want {
+				t.Errorf("clientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	config := testConfig()
+	config.ClientIPHeaders = []string{"X-Real-IP"}
+	if err := ValidateConfig(config); !errors.Is(err, ErrMissingSetting) {
+		t.Errorf("ValidateConfig without trustForwardedHeader = %v, want %v", err, ErrMissingSetting)
+	}
+}
@@ -0,0 +1,17 @@
+package traefik_plugin_block_useragents
+
+import "time"
+
+// clock is the time source of the time-based features (decision cache TTL,
+// rate limits). Tests can swap in a fake clock to control time.
+type clock interface {
+	Now() time.Time
+}
+
+// realClock reads the system time.
+type realClock struct{}
+
+// Now returns the current system time.
+func (realClock) Now() time.Time {
+	return time.Now()
+}
@@ -0,0 +1,66 @@
+package traefik_plugin_block_useragents
+
+// Close stops the background workers of the middleware and of its policies,
+// shadow and reloaded rulesets, flushes the block log file and releases the
+// cached state. Traefik does not call it, so the workers also stop with the
+// construction context; expvar counters stay published.
+// Close is idempotent and safe to call while requests are in flight.
+func (b *BlockUserAgents) Close() error {
+	b.closeOnce.Do(func() {
+		if b.reloadConfig != nil {
+			unregisterReload(b)
+		}
+		if b.reloadStop != nil {
+			close(b.reloadStop)
+		}
+		if b.rulesWatch != nil {
+			b.rulesWatch.close()
+		}
+		if reloaded := b.reloaded.Load(); reloaded != nil {
+			_ = reloaded.Close()
+		}
+		if b.cache != nil {
+			b.cache.clear()
+		}
+		for _, rl := range b.rateLimiters {
+			rl.reset()
+		}
+		if b.threatFeed != nil {
+			b.threatFeed.close()
+		}
+		if b.blockLog != nil {
+			b.blockLog.close()
+		}
+		if b.webhook != nil {
+			b.webhook.close()
+		}
+		if b.learner != nil {
+			b.learner.close()
+		}
+		if b.distinct != nil {
+			b.distinct.reset()
+		}
+		if b.shadow != nil {
+			_ = b.shadow.Close()
+		}
+		for _, policy := range b.policies {
+			_ = policy.Close()
+		}
+	})
+	return nil
+}
+
+// clear removes every cache entry.
+func (c *decisionCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.order.Init()
+	clear(c.entries)
+}
+
+// reset drops every token bucket.
+func (rl *rateLimiter) reset() {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	clear(rl.buckets)
+}
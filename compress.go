@@ -0,0 +1,50 @@
+package traefik_plugin_block_useragents
+
+import (
+	"bytes"
+	"compress/gzip"
+	"strconv"
+	"strings"
+)
+
+// minCompressBytes is the size below which block bodies are sent as is: the
+// gzip framing would outweigh the savings.
+const minCompressBytes = 1024
+
+// acceptsGzip reports whether an Accept-Encoding header value accepts gzip,
+// either by name or through "*", with a non-zero quality.
+func acceptsGzip(acceptEncoding string) bool {
+	accepted := false
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding != "gzip" && coding != "x-gzip" && coding != "*" {
+			continue
+		}
+		q := 1.0
+		if name, value, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.EqualFold(strings.TrimSpace(name), "q") {
+			if parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+				q = parsed
+			}
+		}
+		if coding != "*" {
+			return q > 0 // An explicit gzip entry overrides "*"
+		}
+		accepted = q > 0
+	}
+	return accepted
+}
+
+// gzipBody compresses a block body, returning nil on failure so the body is
+// sent uncompressed.
+func gzipBody(body []byte) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(body); err != nil {
+		return nil
+	}
+	if err := zw.Close(); err != nil {
+		return nil
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,88 @@
+package traefik_plugin_block_useragents
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestAcceptsGzip(t *testing.T) {
+	tests := []struct {
+		acceptEncoding string
+		want           bool
+	}{
+		{"", false},
+		{"gzip", true},
+		{"GZIP", true},
+		{"x-gzip", true},
+		{"deflate, br", false},
+		{"br, gzip;q=0.5", true},
+		{"gzip;q=0", false},
+		{"gzip; q=0.0", false},
+		{"*", true},
+		{"*;q=0", false},
+		{"*, gzip;q=0", false},
+		{"*;q=0, gzip", true},
+		{"gzip;q=0, *", false},
+		{"gzip;q=bogus", true},
+	}
+	for _, tt := range tests {
+		if got := acceptsGzip(tt.acceptEncoding); got != tt.want {
+			t.Errorf("acceptsGzip(%q) = %v, want %v", tt.acceptEncoding, got, tt.want)
+		}
+	}
+}
+
+func TestCompressBlockResponse(t *testing.T) {
+	tests := []struct {
+		name           string
+		compress       bool
+		bodyBytes      int
+		acceptEncoding string
+		wantGzip       bool
+		wantVary       bool
+	}{
+		{"compressed", true, minCompressBytes, "gzip", true, true},
+		{"below the cutoff", true, minCompressBytes - 1, "gzip", false, false},
+		{"gzip refused", true, minCompressBytes, "gzip;q=0", false, true},
+		{"no Accept-Encoding", true, minCompressBytes, "", false, true},
+		{"disabled", false, minCompressBytes, "gzip", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := strings.Repeat("x", tt.bodyBytes)
+			config := testConfig()
+			config.CompressBlockResponse = tt.compress
+			config.MessagesByReason = map[string]string{"Unsupported Browser": message}
+			h := newTestHandler(t, config, nil)
+
+			rec := serve(h, curlUA, withHeader("Accept-Encoding", tt.acceptEncoding))
+			if rec.Code != http.StatusForbidden {
+				t.Fatalf("status = %d, want %d", rec.Code, http.StatusForbidden)
+			}
+			if got := rec.Header().Get("Content-Encoding") == "gzip"; got != tt.wantGzip {
+				t.Errorf("Content-Encoding = %q, want gzip %v", rec.Header().Get("Content-Encoding"), tt.wantGzip)
+			}
+			if got := rec.Header().Get("Vary") == "Accept-Encoding"; got != tt.wantVary {
+				t.Errorf("Vary = %q, want Accept-Encoding %v", rec.Header().Get("Vary"), tt.wantVary)
+			}
+
+			body := rec.Body.Bytes()
+			if tt.wantGzip {
+				zr, err := gzip.NewReader(bytes.NewReader(body))
+				if err != nil {
+					t.Fatalf("gzip.NewReader: %v", err)
+				}
+				if body, err = io.ReadAll(zr); err != nil {
+					t.Fatalf("reading the gzip body: %v", err)
+				}
+			}
+			if string(body) != message {
+				t.Errorf("body is %d bytes, want the %d byte message", len(body), len(message))
+			}
+		})
+	}
+}
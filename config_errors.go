@@ -0,0 +1,102 @@
+package traefik_plugin_block_useragents
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Kinds of configuration problems reported by ValidateConfig, for use with
+// errors.Is. Every error returned by ValidateConfig matches ErrInvalidConfig;
+// the others are matched by the problems they describe.
+var (
+	ErrInvalidConfig       = errors.New("invalid configuration")
+	ErrNoBrowsers          = errors.New("at least one allowed browser must be specified")
+	ErrMissingRegex        = errors.New("missing regex")
+	ErrInvalidStatusCode   = errors.New("invalid status code")
+	ErrUnknownReason       = errors.New("unknown block reason")
+	ErrInvalidValue        = errors.New("invalid value")
+	ErrMissingSetting      = errors.New("missing setting")
+	ErrConflictingSettings = errors.New("conflicting settings")
+)
+
+// ConfigError is a configuration problem of a given kind. Its message is the
+// one of Err, so classifying a problem does not change how it reads.
+type ConfigError struct {
+	Kind error // One of the Err* kinds
+	Err  error
+}
+
+// configErrorf returns a ConfigError of the given kind with a formatted
+// message; %w wraps the cause as with fmt.Errorf.
+func configErrorf(kind error, format string, args ...any) error {
+	return &ConfigError{Kind: kind, Err: fmt.Errorf(format, args...)}
+}
+
+func (e *ConfigError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the kind and the error, so errors.Is and errors.As match
+// both.
+func (e *ConfigError) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+// ConfigErrors lists every problem ValidateConfig found, in the order of the
+// checks. Use errors.As to get them all from the returned error.
+type ConfigErrors []error
+
+// add records err, if any. The problems of a ConfigErrors are recorded one
+// by one, so validators reporting several problems keep the list flat.
+func (e *ConfigErrors) add(err error) {
+	if list, ok := err.(ConfigErrors); ok {
+		*e = append(*e, list...)
+	} else if err != nil {
+		*e = append(*e, err)
+	}
+}
+
+// err returns the problems as an error, or nil when there are none.
+func (e ConfigErrors) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Error joins the messages of the problems with "; ".
+func (e ConfigErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Unwrap returns the problems, so errors.Is and errors.As match each of them.
+func (e ConfigErrors) Unwrap() []error {
+	return e
+}
+
+// Is makes every ConfigErrors match ErrInvalidConfig.
+func (e ConfigErrors) Is(target error) bool {
+	return target == ErrInvalidConfig
+}
+
+// prefixErrors prefixes the message of every problem in err, keeping their
+// kinds, for the problems of a derived configuration.
+func prefixErrors(prefix string, err error) error {
+	list, ok := err.(ConfigErrors)
+	if !ok {
+		if err == nil {
+			return nil
+		}
+		return fmt.Errorf("%s: %w", prefix, err)
+	}
+	prefixed := make(ConfigErrors, 0, len(list))
+	for _, problem := range list {
+		prefixed = append(prefixed, fmt.Errorf("%s: %w", prefix, problem))
+	}
+	return prefixed
+}
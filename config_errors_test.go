@@ -0,0 +1,84 @@
+package traefik_plugin_block_useragents
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateConfigReportsEveryProblem(t *testing.T) {
+	config := testConfig()
+	config.RateLimits = []RateLimitRule{{Name: "crawlers", Requests: 0}}
+	config.ScoreRules = []ScoreRule{{Name: "bots", Regex: "bot"}}
+	config.BlockStatusCode = 200
+	config.StatusByReason = map[string]int{"No Such Reason": 403}
+	config.BypassHeaderValues = []string{""}
+	config.CacheTTL = "soon"
+	config.BlockLogMaxBytes = 1024
+	config.LogFormat = "xml"
+	config.RecoverStatusCode = 500
+	config.RepanicDownstream = true
+
+	err := ValidateConfig(config)
+	var problems ConfigErrors
+	if !errors.As(err, &problems) {
+		t.Fatalf("ValidateConfig() = %v, want ConfigErrors", err)
+	}
+	tests := []struct {
+		message string
+		kind    error
+	}{
+		{"regex must be provided for rate limit: crawlers", ErrMissingRegex},
+		{"requests must be positive for rate limit: crawlers", ErrInvalidValue},
+		{"weight must not be zero for score rule: bots", ErrInvalidValue},
+		{"blockStatusCode must be a 4xx or 5xx status", ErrInvalidStatusCode},
+		{`unknown block reason "No Such Reason" in statusByReason`, ErrUnknownReason},
+		{"bypassHeaderName must be provided", ErrMissingSetting},
+		{"bypassHeaderValues must not contain an empty value", ErrInvalidValue},
+		{`invalid cacheTTL "soon"`, ErrInvalidValue},
+		{"blockLogMaxBytes requires blockLogFile", ErrMissingSetting},
+		{`invalid logFormat "xml"`, ErrInvalidValue},
+		{"recoverStatusCode and repanicDownstream require recoverDownstream", ErrMissingSetting},
+		{"recoverStatusCode has no effect when repanicDownstream is set", ErrConflictingSettings},
+	}
+	if len(problems) != len(tests) {
+		t.Errorf("got %d problems, want %d: %v", len(problems), len(tests), err)
+	}
+	for _, tt := range tests {
+		found := false
+		for _, problem := range problems {
+			if strings.Contains(problem.Error(), tt.message) {
+				found = true
+				if !errors.Is(problem, tt.kind) {
+					t.Errorf("%q does not match %v", problem, tt.kind)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("problem %q not reported", tt.message)
+		}
+	}
+	if !errors.Is(err, ErrInvalidConfig) {
+		t.Error("ValidateConfig() does not match ErrInvalidConfig")
+	}
+}
+
+func TestValidateConfigPrefixesPolicyProblems(t *testing.T) {
+	config := testConfig()
+	config.Policies = map[string]PolicyConfig{
+		"shop": {AllowedBrowsers: []BrowserConfig{{Name: "NoRegex"}, {Name: "Flags", Regex: "x", Flags: "z"}}},
+	}
+	config.DefaultPolicy = "shop"
+
+	err := ValidateConfig(config)
+	var problems ConfigErrors
+	if !errors.As(err, &problems) || len(problems) != 2 {
+		t.Fatalf("ValidateConfig() = %v, want 2 problems", err)
+	}
+	if !errors.Is(problems[0], ErrMissingRegex) || !strings.HasPrefix(problems[0].Error(), "policy shop: ") {
+		t.Errorf("problems[0] = %q, want a missing regex of policy shop", problems[0])
+	}
+	if !errors.Is(problems[1], ErrInvalidValue) {
+		t.Errorf("problems[1] = %q, want an invalid value", problems[1])
+	}
+}
@@ -0,0 +1,259 @@
+package traefik_plugin_block_useragents
+
+// Merge returns a new Config layering override on top of c, which is left unchanged:
+//   - scalars: the override wins when it differs from the CreateConfig default,
+//     so an override can turn AllowPreflight off but cannot set a value back to its default,
+//   - lists: the override entries are appended to the base entries, except for
+//     Rules, where they are placed first so they are matched first, and
+//     EvaluationOrder, which the override replaces when set,
+//   - maps: entries are combined, the override winning for duplicate keys.
+//
+// A nil override returns a copy of c. Fields are merged one by one, so a new
+// Config field must be added here too.
+func (c *Config) Merge(override *Config) *Config {
+	o, def := override, CreateConfig()
+	if o == nil {
+		o = def
+	}
+	return &Config{
+		AllowedBrowsers:   appendBrowsers(c.AllowedBrowsers, o.AllowedBrowsers),
+		AllowedOSTypes:    appendStrings(c.AllowedOSTypes, o.AllowedOSTypes),
+		AllowedOSNames:    appendStrings(c.AllowedOSNames, o.AllowedOSNames),
+		OSVersionRules:    appendStrings(c.OSVersionRules, o.OSVersionRules),
+		BrandVersionRules: appendStrings(c.BrandVersionRules, o.BrandVersionRules),
+		BlockedBrowsers:   appendBrowsers(c.BlockedBrowsers, o.BlockedBrowsers),
+		RateLimits:        append(append([]RateLimitRule(nil), c.RateLimits...), o.RateLimits...),
+
+		RulesFile:     mergeString(c.RulesFile, o.RulesFile, def.RulesFile),
+		RulesURL:      mergeString(c.RulesURL, o.RulesURL, def.RulesURL),
+		MaxRulesBytes: mergeInt(c.MaxRulesBytes, o.MaxRulesBytes, def.MaxRulesBytes),
+
+		GlobBrowsers:          appendStrings(c.GlobBrowsers, o.GlobBrowsers),
+		BrowserSpecs:          appendStrings(c.BrowserSpecs, o.BrowserSpecs),
+		UseEmbeddedBaseline:   mergeBool(c.UseEmbeddedBaseline, o.UseEmbeddedBaseline, def.UseEmbeddedBaseline),
+		MatchAllHeaderValues:  mergeBool(c.MatchAllHeaderValues, o.MatchAllHeaderValues, def.MatchAllHeaderValues),
+		MatchHeaders:          appendStrings(c.MatchHeaders, o.MatchHeaders),
+		DecodeUserAgent:       mergeBool(c.DecodeUserAgent, o.DecodeUserAgent, def.DecodeUserAgent),
+		MatchCombinedIdentity: mergeBool(c.MatchCombinedIdentity, o.MatchCombinedIdentity, def.MatchCombinedIdentity),
+		SkipInvalidPatterns:   mergeBool(c.SkipInvalidPatterns, o.SkipInvalidPatterns, def.SkipInvalidPatterns),
+		FingerprintHeader:     mergeString(c.FingerprintHeader, o.FingerprintHeader, def.FingerprintHeader),
+		AllowedFingerprints:   appendStrings(c.AllowedFingerprints, o.AllowedFingerprints),
+		SelfTestUserAgents:    appendStrings(c.SelfTestUserAgents, o.SelfTestUserAgents),
+		BlockResponseHeaders:  mergeStringMaps(c.BlockResponseHeaders, o.BlockResponseHeaders),
+		ExposeReasonHeader:    mergeBool(c.ExposeReasonHeader, o.ExposeReasonHeader, def.ExposeReasonHeader),
+
+		Rules:               append(append([]Rule(nil), o.Rules...), c.Rules...),
+		DefaultAction:       mergeString(c.DefaultAction, o.DefaultAction, def.DefaultAction),
+		DefaultDecision:     mergeString(c.DefaultDecision, o.DefaultDecision, def.DefaultDecision),
+		EmptyConfigBehavior: mergeString(c.EmptyConfigBehavior, o.EmptyConfigBehavior, def.EmptyConfigBehavior),
+		StrictValidation:    mergeBool(c.StrictValidation, o.StrictValidation, def.StrictValidation),
+		BlockStatusCode:     mergeInt(c.BlockStatusCode, o.BlockStatusCode, def.BlockStatusCode),
+		StatusByReason:      mergeIntMaps(c.StatusByReason, o.StatusByReason),
+
+		ScoreRules:             append(append([]ScoreRule(nil), c.ScoreRules...), o.ScoreRules...),
+		ScoreThreshold:         mergeInt(c.ScoreThreshold, o.ScoreThreshold, def.ScoreThreshold),
+		CheckPlausibility:      mergeBool(c.CheckPlausibility, o.CheckPlausibility, def.CheckPlausibility),
+		MinPlausibility:        mergeInt(c.MinPlausibility, o.MinPlausibility, def.MinPlausibility),
+		MinUASegments:          mergeInt(c.MinUASegments, o.MinUASegments, def.MinUASegments),
+		MinTLSVersion:          mergeString(c.MinTLSVersion, o.MinTLSVersion, def.MinTLSVersion),
+		TLSVersionHeader:       mergeString(c.TLSVersionHeader, o.TLSVersionHeader, def.TLSVersionHeader),
+		BlockUnknownTLSVersion: mergeBool(c.BlockUnknownTLSVersion, o.BlockUnknownTLSVersion, def.BlockUnknownTLSVersion),
+		CheckSNIMatch:          mergeBool(c.CheckSNIMatch, o.CheckSNIMatch, def.CheckSNIMatch),
+		SNIHeader:              mergeString(c.SNIHeader, o.SNIHeader, def.SNIHeader),
+		BlockMissingSNI:        mergeBool(c.BlockMissingSNI, o.BlockMissingSNI, def.BlockMissingSNI),
+		BlockHTTP10:            mergeBool(c.BlockHTTP10, o.BlockHTTP10, def.BlockHTTP10),
+		MatchTimeout:           mergeString(c.MatchTimeout, o.MatchTimeout, def.MatchTimeout),
+
+		Policies:       mergePolicyMaps(c.Policies, o.Policies),
+		HostPolicyMap:  mergeStringMaps(c.HostPolicyMap, o.HostPolicyMap),
+		DefaultPolicy:  mergeString(c.DefaultPolicy, o.DefaultPolicy, def.DefaultPolicy),
+		Annotate:       mergeBool(c.Annotate, o.Annotate, def.Annotate),
+		ReloadOnSignal: mergeBool(c.ReloadOnSignal, o.ReloadOnSignal, def.ReloadOnSignal),
+
+		RequiredHeaders:  appendStrings(c.RequiredHeaders, o.RequiredHeaders),
+		ForbiddenHeaders: appendStrings(c.ForbiddenHeaders, o.ForbiddenHeaders),
+		ShadowBrowsers:   appendBrowsers(c.ShadowBrowsers, o.ShadowBrowsers),
+		ShadowOSTypes:    appendStrings(c.ShadowOSTypes, o.ShadowOSTypes),
+
+		CorrelationHeader:     mergeString(c.CorrelationHeader, o.CorrelationHeader, def.CorrelationHeader),
+		GenerateCorrelationID: mergeBool(c.GenerateCorrelationID, o.GenerateCorrelationID, def.GenerateCorrelationID),
+		Tracing:               mergeBool(c.Tracing, o.Tracing, def.Tracing),
+		BlockLogFile:          mergeString(c.BlockLogFile, o.BlockLogFile, def.BlockLogFile),
+		BlockLogMaxBytes:      mergeInt(c.BlockLogMaxBytes, o.BlockLogMaxBytes, def.BlockLogMaxBytes),
+		WebhookURL:            mergeString(c.WebhookURL, o.WebhookURL, def.WebhookURL),
+		WebhookAuthHeader:     mergeString(c.WebhookAuthHeader, o.WebhookAuthHeader, def.WebhookAuthHeader),
+		ThreatFeedURL:         mergeString(c.ThreatFeedURL, o.ThreatFeedURL, def.ThreatFeedURL),
+		ReloadInterval:        mergeString(c.ReloadInterval, o.ReloadInterval, def.ReloadInterval),
+
+		DistinctBlockAlertThreshold: mergeInt(c.DistinctBlockAlertThreshold, o.DistinctBlockAlertThreshold, def.DistinctBlockAlertThreshold),
+		DistinctBlockAlertWindow:    mergeString(c.DistinctBlockAlertWindow, o.DistinctBlockAlertWindow, def.DistinctBlockAlertWindow),
+
+		DenyBrowsers:          appendBrowsers(c.DenyBrowsers, o.DenyBrowsers),
+		AllowOverrides:        appendStrings(c.AllowOverrides, o.AllowOverrides),
+		BlockResponseTemplate: mergeString(c.BlockResponseTemplate, o.BlockResponseTemplate, def.BlockResponseTemplate),
+		MessagesByReason:      mergeStringMaps(c.MessagesByReason, o.MessagesByReason),
+		BlockPageFile:         mergeString(c.BlockPageFile, o.BlockPageFile, def.BlockPageFile),
+		BlockPagesByReason:    mergeStringMaps(c.BlockPagesByReason, o.BlockPagesByReason),
+		CheckOrigin:           mergeBool(c.CheckOrigin, o.CheckOrigin, def.CheckOrigin),
+		AllowedOrigins:        appendStrings(c.AllowedOrigins, o.AllowedOrigins),
+
+		ChallengeBrowsers:   appendStrings(c.ChallengeBrowsers, o.ChallengeBrowsers),
+		ChallengeCookieName: mergeString(c.ChallengeCookieName, o.ChallengeCookieName, def.ChallengeCookieName),
+		ChallengeSecret:     mergeString(c.ChallengeSecret, o.ChallengeSecret, def.ChallengeSecret),
+		ChallengeTTL:        mergeString(c.ChallengeTTL, o.ChallengeTTL, def.ChallengeTTL),
+
+		LogSampleRate:   mergeFloat(c.LogSampleRate, o.LogSampleRate, def.LogSampleRate),
+		LogMaxPerReason: mergeInt(c.LogMaxPerReason, o.LogMaxPerReason, def.LogMaxPerReason),
+		LogDedupeWindow: mergeString(c.LogDedupeWindow, o.LogDedupeWindow, def.LogDedupeWindow),
+		CacheSize:       mergeInt(c.CacheSize, o.CacheSize, def.CacheSize),
+		CacheTTL:        mergeString(c.CacheTTL, o.CacheTTL, def.CacheTTL),
+
+		NormalizeForward:  mergeBool(c.NormalizeForward, o.NormalizeForward, def.NormalizeForward),
+		NormalizeRules:    append(append([]ReplaceRule(nil), c.NormalizeRules...), o.NormalizeRules...),
+		EvaluationOrder:   replaceStrings(c.EvaluationOrder, o.EvaluationOrder),
+		RequireMatchCount: mergeInt(c.RequireMatchCount, o.RequireMatchCount, def.RequireMatchCount),
+		MinMatchLength:    mergeInt(c.MinMatchLength, o.MinMatchLength, def.MinMatchLength),
+
+		DeniedIPs:            appendStrings(c.DeniedIPs, o.DeniedIPs),
+		TrustForwardedHeader: mergeBool(c.TrustForwardedHeader, o.TrustForwardedHeader, def.TrustForwardedHeader),
+		TrustedProxies:       appendStrings(c.TrustedProxies, o.TrustedProxies),
+		ClientIPHeaders:      appendStrings(c.ClientIPHeaders, o.ClientIPHeaders),
+		OSMatchMode:          mergeString(c.OSMatchMode, o.OSMatchMode, def.OSMatchMode),
+		AllowGRPC:            mergeBool(c.AllowGRPC, o.AllowGRPC, def.AllowGRPC),
+		AllowPreflight:       mergeBool(c.AllowPreflight, o.AllowPreflight, def.AllowPreflight),
+
+		RecoverDownstream: mergeBool(c.RecoverDownstream, o.RecoverDownstream, def.RecoverDownstream),
+		RecoverStatusCode: mergeInt(c.RecoverStatusCode, o.RecoverStatusCode, def.RecoverStatusCode),
+		RepanicDownstream: mergeBool(c.RepanicDownstream, o.RepanicDownstream, def.RepanicDownstream),
+
+		LearnMode:     mergeBool(c.LearnMode, o.LearnMode, def.LearnMode),
+		LearnInterval: mergeString(c.LearnInterval, o.LearnInterval, def.LearnInterval),
+		LearnFile:     mergeString(c.LearnFile, o.LearnFile, def.LearnFile),
+
+		EnableValidateEndpoint: mergeBool(c.EnableValidateEndpoint, o.EnableValidateEndpoint, def.EnableValidateEndpoint),
+		ValidatePath:           mergeString(c.ValidatePath, o.ValidatePath, def.ValidatePath),
+		ValidateAllowedIPs:     appendStrings(c.ValidateAllowedIPs, o.ValidateAllowedIPs),
+		MetricsPath:            mergeString(c.MetricsPath, o.MetricsPath, def.MetricsPath),
+		MetricsAllowedIPs:      appendStrings(c.MetricsAllowedIPs, o.MetricsAllowedIPs),
+
+		SoftAllowedBrowsers:   appendBrowsers(c.SoftAllowedBrowsers, o.SoftAllowedBrowsers),
+		MaxBlockBodyBytes:     mergeInt(c.MaxBlockBodyBytes, o.MaxBlockBodyBytes, def.MaxBlockBodyBytes),
+		CompressBlockResponse: mergeBool(c.CompressBlockResponse, o.CompressBlockResponse, def.CompressBlockResponse),
+		CheckLanguage:         mergeBool(c.CheckLanguage, o.CheckLanguage, def.CheckLanguage),
+		AllowedLanguages:      appendStrings(c.AllowedLanguages, o.AllowedLanguages),
+		Expvar:                mergeBool(c.Expvar, o.Expvar, def.Expvar),
+		RequireScheme:         mergeString(c.RequireScheme, o.RequireScheme, def.RequireScheme),
+		SchemeAction:          mergeString(c.SchemeAction, o.SchemeAction, def.SchemeAction),
+		CombinePatterns:       mergeBool(c.CombinePatterns, o.CombinePatterns, def.CombinePatterns),
+		AutoAnchor:            mergeString(c.AutoAnchor, o.AutoAnchor, def.AutoAnchor),
+		EnforcementDelay:      mergeString(c.EnforcementDelay, o.EnforcementDelay, def.EnforcementDelay),
+		LogFormat:             mergeString(c.LogFormat, o.LogFormat, def.LogFormat),
+		LogTiming:             mergeBool(c.LogTiming, o.LogTiming, def.LogTiming),
+
+		BypassHeaderName:   mergeString(c.BypassHeaderName, o.BypassHeaderName, def.BypassHeaderName),
+		BypassHeaderValues: appendStrings(c.BypassHeaderValues, o.BypassHeaderValues),
+		MaintenanceMode:    mergeBool(c.MaintenanceMode, o.MaintenanceMode, def.MaintenanceMode),
+	}
+}
+
+// mergeString returns override when it differs from the default, else base.
+func mergeString(base, override, def string) string {
+	if override != def {
+		return override
+	}
+	return base
+}
+
+// mergeBool returns override when it differs from the default, else base.
+func mergeBool(base, override, def bool) bool {
+	if override != def {
+		return override
+	}
+	return base
+}
+
+// mergeInt returns override when it differs from the default, else base.
+func mergeInt(base, override, def int) int {
+	if override != def {
+		return override
+	}
+	return base
+}
+
+// mergeFloat returns override when it differs from the default, else base.
+func mergeFloat(base, override, def float64) float64 {
+	if override != def {
+		return override
+	}
+	return base
+}
+
+// appendStrings returns a new list with the base then the override entries.
+// A nil base stays nil when there is nothing to add.
+func appendStrings(base, override []string) []string {
+	if base == nil && len(override) == 0 {
+		return nil
+	}
+	return append(append(make([]string, 0, len(base)+len(override)), base...), override...)
+}
+
+// replaceStrings returns a copy of override when set, else of base.
+func replaceStrings(base, override []string) []string {
+	if len(override) > 0 {
+		return appendStrings(nil, override)
+	}
+	return appendStrings(base, nil)
+}
+
+// appendBrowsers returns a new list with the base then the override entries.
+func appendBrowsers(base, override []BrowserConfig) []BrowserConfig {
+	if base == nil && len(override) == 0 {
+		return nil
+	}
+	return append(append(make([]BrowserConfig, 0, len(base)+len(override)), base...), override...)
+}
+
+// mergeStringMaps returns a new map with the base and override entries.
+func mergeStringMaps(base, override map[string]string) map[string]string {
+	if base == nil && len(override) == 0 {
+		return nil
+	}
+	merged := make(map[string]string, len(base)+len(override))
+	for key, value := range base {
+		merged[key] = value
+	}
+	for key, value := range override {
+		merged[key] = value
+	}
+	return merged
+}
+
+// mergeIntMaps returns a new map with the base and override entries.
+func mergeIntMaps(base, override map[string]int) map[string]int {
+	if base == nil && len(override) == 0 {
+		return nil
+	}
+	merged := make(map[string]int, len(base)+len(override))
+	for key, value := range base {
+		merged[key] = value
+	}
+	for key, value := range override {
+		merged[key] = value
+	}
+	return merged
+}
+
+// mergePolicyMaps returns a new map with the base and override policies.
+func mergePolicyMaps(base, override map[string]PolicyConfig) map[string]PolicyConfig {
+	if base == nil && len(override) == 0 {
+		return nil
+	}
+	merged := make(map[string]PolicyConfig, len(base)+len(override))
+	for name, policy := range base {
+		merged[name] = policy
+	}
+	for name, policy := range override {
+		merged[name] = policy
+	}
+	return merged
+}
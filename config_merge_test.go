@@ -0,0 +1,104 @@
+package traefik_plugin_block_useragents
+
+import (
+	"reflect"
+	"testing"
+)
+
+// filledConfig returns a Config with every field set to a non-default value.
+func filledConfig(t *testing.T) *Config {
+	t.Helper()
+	config := &Config{}
+	v := reflect.ValueOf(config).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		switch field.Kind() {
+		case reflect.String:
+			field.SetString("set")
+		case reflect.Bool:
+			field.SetBool(!reflect.ValueOf(*CreateConfig()).Field(i).Bool())
+		case reflect.Int:
+			field.SetInt(7)
+		case reflect.Float64:
+			field.SetFloat(0.5)
+		case reflect.Slice:
+			field.Set(reflect.MakeSlice(field.Type(), 1, 1))
+		case reflect.Map:
+			field.Set(reflect.MakeMap(field.Type()))
+			field.SetMapIndex(reflect.New(field.Type().Key()).Elem(), reflect.New(field.Type().Elem()).Elem())
+		default:
+			t.Fatalf("field %s: unhandled kind %s", v.Type().Field(i).Name, field.Kind())
+		}
+	}
+	return config
+}
+
+func TestMergeCoversAllFields(t *testing.T) {
+	base := filledConfig(t)
+	if merged := base.Merge(nil); !reflect.DeepEqual(merged, base) {
+		v, m := reflect.ValueOf(base).Elem(), reflect.ValueOf(merged).Elem()
+		for i := 0; i < v.NumField(); i++ {
+			if !reflect.DeepEqual(v.Field(i).Interface(), m.Field(i).Interface()) {
+				t.Errorf("Merge(nil) lost %s", v.Type().Field(i).Name)
+			}
+		}
+	}
+	if merged := CreateConfig().Merge(base); !reflect.DeepEqual(merged.Policies, base.Policies) || merged.BlockStatusCode != 7 || merged.AllowPreflight {
+		t.Errorf("Merge() dropped override fields: %+v", merged)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	base := CreateConfig()
+	base.AllowPreflight = false
+	base.LogSampleRate = 0.1
+	base.BlockStatusCode = 404
+	base.AllowedOSTypes = []string{"Windows"}
+	base.Rules = []Rule{{Action: RuleDeny, Pattern: "base"}}
+	base.EvaluationOrder = []string{"deny", "allow"}
+	base.MessagesByReason = map[string]string{"Denied Browser": "base", "Rate Limited": "slow down"}
+
+	override := CreateConfig()
+	override.Annotate = true
+	override.AllowedOSTypes = []string{"Linux"}
+	override.Rules = []Rule{{Action: RuleAllow, Pattern: "override"}}
+	override.MessagesByReason = map[string]string{"Denied Browser": "override"}
+
+	merged := base.Merge(override)
+	tests := []struct {
+		name      string
+		got, want any
+	}{
+		{"default boolean keeps base false", merged.AllowPreflight, false},
+		{"default rate keeps base", merged.LogSampleRate, 0.1},
+		{"unset int keeps base", merged.BlockStatusCode, 404},
+		{"set boolean wins", merged.Annotate, true},
+		{"lists appended", merged.AllowedOSTypes, []string{"Windows", "Linux"}},
+		{"rules prepended", merged.Rules, []Rule{{Action: RuleAllow, Pattern: "override"}, {Action: RuleDeny, Pattern: "base"}}},
+		{"unset order kept", merged.EvaluationOrder, []string{"deny", "allow"}},
+		{"maps combined", merged.MessagesByReason, map[string]string{"Denied Browser": "override", "Rate Limited": "slow down"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !reflect.DeepEqual(tt.got, tt.want) {
+				t.Errorf("got %v, want %v", tt.got, tt.want)
+			}
+		})
+	}
+
+	disable := CreateConfig()
+	disable.AllowPreflight = false
+	if CreateConfig().Merge(disable).AllowPreflight {
+		t.Error("override could not turn AllowPreflight off")
+	}
+	override.EvaluationOrder = []string{"allow"}
+	if got := base.Merge(override).EvaluationOrder; !reflect.DeepEqual(got, []string{"allow"}) {
+		t.Errorf("EvaluationOrder = %q, want replaced", got)
+	}
+
+	merged.AllowedOSTypes[0] = "changed"
+	merged.MessagesByReason["Denied Browser"] = "changed"
+	if base.AllowedOSTypes[0] != "Windows" || base.MessagesByReason["Denied Browser"] != "base" {
+		t.Error("merged config aliases the base config")
+	}
+}
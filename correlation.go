@@ -0,0 +1,48 @@
+package traefik_plugin_block_useragents
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net/http"
+)
+
+// defaultCorrelationHeader carries the request correlation ID when
+// CorrelationHeader is not set.
+const defaultCorrelationHeader = "X-Request-ID"
+
+// validateCorrelation checks the correlation ID settings.
+func validateCorrelation(config *Config) error {
+	if config.CorrelationHeader != "" && !headerNamePattern.MatchString(config.CorrelationHeader) {
+		return configErrorf(ErrInvalidValue, "invalid correlationHeader %q", config.CorrelationHeader)
+	}
+	return nil
+}
+
+// newCorrelationID returns a random 128-bit ID in hex.
+func newCorrelationID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
+
+// correlate gives requests without a correlation ID a new one, forwarded
+// upstream, and echoes the ID on the response. It does nothing unless
+// GenerateCorrelationID is set.
+func (b *BlockUserAgents) correlate(res http.ResponseWriter, req *http.Request) {
+	if !b.generateCorrelationID {
+		return
+	}
+	id := req.Header.Get(b.correlationHeader)
+	if id == "" {
+		var err error
+		if id, err = newCorrelationID(); err != nil {
+			log.Printf("%s: error generating correlation ID: %v", b.name, err)
+			return
+		}
+		req.Header.Set(b.correlationHeader, id)
+	}
+	res.Header().Set(b.correlationHeader, id)
+}
@@ -0,0 +1,70 @@
+package traefik_plugin_block_useragents
+
+import (
+	"net/http"
+	"regexp"
+	"testing"
+)
+
+func TestCorrelationID(t *testing.T) {
+	generated := regexp.MustCompile(`^[0-9a-f]{32}$`)
+	tests := []struct {
+		name      string
+		header    string // CorrelationHeader
+		userAgent string
+		incoming  string
+		disabled  bool
+	}{
+		{"generated for an allowed request", "", chromeUA, "", false},
+		{"generated for a blocked request", "", curlUA, "", false},
+		{"incoming ID kept", "", chromeUA, "abc-123", false},
+		{"custom header", "X-Correlation-ID", chromeUA, "", false},
+		{"custom header keeps the incoming ID", "X-Correlation-ID", chromeUA, "abc-123", false},
+		{"disabled", "", chromeUA, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := tt.header
+			if header == "" {
+				header = defaultCorrelationHeader
+			}
+			var upstream string
+			next := http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+				upstream = req.Header.Get(header)
+				res.WriteHeader(http.StatusOK)
+			})
+			config := testConfig()
+			config.GenerateCorrelationID = !tt.disabled
+			config.CorrelationHeader = tt.header
+			h := newTestHandler(t, config, next)
+
+			var edits []func(*http.Request)
+			if tt.incoming != "" {
+				edits = append(edits, withHeader(header, tt.incoming))
+			}
+			rec := serve(h, tt.userAgent, edits...)
+			echoed := rec.Header().Get(header)
+
+			switch {
+			case tt.disabled:
+				if echoed != "" || upstream != "" {
+					t.Errorf("correlation ID %q echoed, %q forwarded, want none", echoed, upstream)
+				}
+				return
+			case tt.incoming != "":
+				if echoed != tt.incoming {
+					t.Errorf("echoed ID = %q, want the incoming %q", echoed, tt.incoming)
+				}
+			case !generated.MatchString(echoed):
+				t.Errorf("echoed ID = %q, want 32 hex digits", echoed)
+			}
+			allowed := rec.Code == http.StatusOK
+			if allowed && upstream != echoed {
+				t.Errorf("forwarded ID = %q, want the echoed %q", upstream, echoed)
+			}
+			if !allowed && upstream != "" {
+				t.Errorf("blocked request reached the backend with ID %q", upstream)
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package traefik_plugin_block_useragents
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxCacheKeyBytes is the longest cache key stored as-is; longer keys are hashed.
+const maxCacheKeyBytes = 512
+
+// decisionCache is an LRU cache of evaluation results with a per-entry TTL.
+type decisionCache struct {
+	size int
+	ttl  time.Duration // Zero means entries never expire
+
+	mu      sync.Mutex
+	order   *list.List // Front is most recently used
+	entries map[string]*list.Element
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+type cacheEntry struct {
+	key     string
+	d       decision
+	expires time.Time
+}
+
+// validateCache checks the cache settings.
+func validateCache(config *Config) error {
+	var errs ConfigErrors
+	if config.CacheSize < 0 {
+		errs.add(configErrorf(ErrInvalidValue, "cacheSize must not be negative"))
+	}
+	if config.CacheTTL != "" {
+		ttl, err := time.ParseDuration(config.CacheTTL)
+		switch {
+		case err != nil:
+			errs.add(configErrorf(ErrInvalidValue, "invalid cacheTTL %q: %w", config.CacheTTL, err))
+		case ttl < 0:
+			errs.add(configErrorf(ErrInvalidValue, "cacheTTL must not be negative"))
+		}
+	}
+	return errs.err()
+}
+
+// newDecisionCache returns a cache for the validated settings, or nil when caching is disabled.
+func newDecisionCache(config *Config) *decisionCache {
+	if config.CacheSize == 0 {
+		return nil
+	}
+	var ttl time.Duration
+	if config.CacheTTL != "" {
+		ttl, _ = time.ParseDuration(config.CacheTTL)
+	}
+	return &decisionCache{
+		size:    config.CacheSize,
+		ttl:     ttl,
+		order:   list.New(),
+		entries: make(map[string]*list.Element, config.CacheSize),
+	}
+}
+
+// get returns the cached decision for key if present and not expired.
+func (c *decisionCache) get(key string, now time.Time) (decision, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		c.misses.Add(1)
+		return decision{}, false
+	}
+	entry := elem.Value.(*cacheEntry)
+	if c.ttl > 0 && now.After(entry.expires) {
+		c.order.Remove(elem)
+		delete(c.entries, key)
+		c.misses.Add(1)
+		return decision{}, false
+	}
+	c.order.MoveToFront(elem)
+	c.hits.Add(1)
+	return entry.d, true
+}
+
+// put stores a decision, evicting the least recently used entry when full.
+func (c *decisionCache) put(key string, d decision, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		entry := elem.Value.(*cacheEntry)
+		entry.d = d
+		entry.expires = now.Add(c.ttl)
+		c.order.MoveToFront(elem)
+		return
+	}
+	if c.order.Len() >= c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, d: d, expires: now.Add(c.ttl)})
+}
+
+// cacheKey builds the cache key from every request property the rules can
+// depend on, so requests sharing a key always get the same decision.
+func (b *BlockUserAgents) cacheKey(req *http.Request) string {
+	parts := []string{req.Method}
+	parts = append(parts, b.userAgentValues(req)...)
+	if b.matchCombinedIdentity {
+		parts = append(parts, "hints="+clientHints(req))
+	}
+	if len(b.brandVersionRules) > 0 {
+		parts = append(parts, "brands="+req.Header.Get(fullVersionListHeader))
+	}
+	if b.allowGRPC && isGRPC(req) {
+		parts = append(parts, "grpc")
+	}
+	if b.hasPathRules && req.URL != nil {
+		parts = append(parts, "path="+req.URL.Path)
+	}
+	if len(b.allowedFingerprints) > 0 {
+		parts = append(parts, "fp="+req.Header.Get(b.fingerprintHeader))
+	}
+	if b.checkOrigin {
+		parts = append(parts, "origin="+req.Header.Get("Origin"), "referer="+req.Referer())
+	}
+	if b.checkLanguageEnabled {
+		parts = append(parts, "lang="+req.Header.Get("Accept-Language"))
+	}
+	if len(b.requiredHeaders) > 0 || len(b.forbiddenHeaders) > 0 {
+		parts = append(parts, "headers="+b.headerPresence(req))
+	}
+	key := strings.Join(parts, "\x00")
+	if len(key) > maxCacheKeyBytes {
+		// Bound the memory held per entry for oversized headers
+		sum := sha256.Sum256([]byte(key))
+		key = "sha256:" + hex.EncodeToString(sum[:])
+	}
+	return key
+}
+
+// cachedEvaluate evaluates the request, using the decision cache when enabled.
+func (b *BlockUserAgents) cachedEvaluate(req *http.Request) decision {
+	if b.cache == nil || b.hasChallengeCookie(req) {
+		d, _ := b.timedEvaluate(req)
+		return d
+	}
+	var (
+		key string
+		d   decision
+		hit bool
+	)
+	now := b.clock.Now()
+	if b.guard.run("decision cache", func() {
+		key = b.cacheKey(req)
+		d, hit = b.cache.get(key, now)
+	}) {
+		b.guard.run("expvar", func() { b.expvar.cacheResult(hit) })
+	}
+	if hit {
+		return d
+	}
+	d, completed := b.timedEvaluate(req)
+	if completed {
+		b.guard.run("decision cache", func() { b.cache.put(key, d, now) })
+	}
+	return d
+}
+
+// CacheStats returns the number of decision cache hits and misses.
+func (b *BlockUserAgents) CacheStats() (hits, misses uint64) {
+	if b.cache == nil {
+		return 0, 0
+	}
+	return b.cache.hits.Load(), b.cache.misses.Load()
+}
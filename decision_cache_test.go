@@ -0,0 +1,114 @@
+package traefik_plugin_block_useragents
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock the tests move forward by hand.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestDecisionCacheTTL(t *testing.T) {
+	tests := []struct {
+		name       string
+		ttl        string
+		advance    time.Duration
+		wantHits   uint64
+		wantMisses uint64
+	}{
+		{"no TTL", "", 24 * time.Hour, 1, 1},
+		{"before expiry", "1m", 59 * time.Second, 1, 1},
+		{"expired", "1m", 61 * time.Second, 0, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.CacheSize = 8
+			config.CacheTTL = tt.ttl
+			h := newTestHandler(t, config, nil)
+			clock := newFakeClock()
+			h.clock = clock
+
+			serve(h, chromeUA)
+			clock.advance(tt.advance)
+			if rec := serve(h, chromeUA); rec.Code != http.StatusOK {
+				t.Errorf("status = %d, want %d", rec.Code, http.StatusOK)
+			}
+			if hits, misses := h.CacheStats(); hits != tt.wantHits || misses != tt.wantMisses {
+				t.Errorf("CacheStats() = %d, %d, want %d, %d", hits, misses, tt.wantHits, tt.wantMisses)
+			}
+		})
+	}
+}
+
+func TestDecisionCacheEviction(t *testing.T) {
+	c := newDecisionCache(&Config{CacheSize: 2})
+	now := time.Now()
+	c.put("a", allow(), now)
+	c.put("b", block("Unsupported Browser"), now)
+	c.get("a", now) // b is now the least recently used
+	c.put("c", allow(), now)
+	for key, want := range map[string]bool{"a": true, "b": false, "c": true} {
+		if _, ok := c.get(key, now); ok != want {
+			t.Errorf("get(%q) found = %v, want %v", key, ok, want)
+		}
+	}
+}
+
+func TestDecisionCacheClearedOnReload(t *testing.T) {
+	rulesFile := filepath.Join(t.TempDir(), "rules.yaml")
+	writeRulesFile := func(regex string) {
+		if err := os.WriteFile(rulesFile, []byte("allowedBrowsers:\n  - name: Browser\n    regex: \""+regex+"\"\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeRulesFile("Chrome/")
+	config := CreateConfig()
+	config.RulesFile = rulesFile
+	config.ReloadOnSignal = true
+	config.CacheSize = 8
+	h := newTestHandler(t, config, nil)
+
+	if rec := serve(h, chromeUA); rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	serve(h, chromeUA) // Cached
+	if hits, _ := h.CacheStats(); hits != 1 {
+		t.Fatalf("cache hits = %d, want 1", hits)
+	}
+
+	writeRulesFile("Firefox/")
+	h.reload()
+	if n := len(h.cache.entries); n != 0 {
+		t.Errorf("%d decisions still cached after the reload", n)
+	}
+	if rec := serve(h, chromeUA); rec.Code != http.StatusForbidden {
+		t.Errorf("status after reload = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+	if hits, misses := h.Stats().CacheHits, h.Stats().CacheMisses; hits != 0 || misses != 1 {
+		t.Errorf("cache hits, misses after reload = %d, %d, want 0, 1", hits, misses)
+	}
+}
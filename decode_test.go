@@ -0,0 +1,51 @@
+package traefik_plugin_block_useragents
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestDecodeUserAgent(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"plain", chromeUA, chromeUA},
+		{"URL-encoded", "curl%2F8.0", "curl/8.0"},
+		{"HTML entities", "curl&#47;8.0", "curl/8.0"},
+		{"named entity", "Bad&amp;Bot", "Bad&Bot"},
+		{"URL-encoded entity", "curl%26%2347%3B8.0", "curl/8.0"},
+		{"invalid escape kept", "curl%ZZ/8.0", "curl%ZZ/8.0"},
+	}
+	h := &BlockUserAgents{decodeUserAgents: true}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := h.decodeUserAgent(tt.value); got != tt.want {
+				t.Errorf("decodeUserAgent(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+	if got := (&BlockUserAgents{}).decodeUserAgent("curl%2F8.0"); got != "curl%2F8.0" {
+		t.Errorf("decodeUserAgent() decoded with decoding off: %q", got)
+	}
+}
+
+func TestDecodeUserAgentBlocksEncodedBots(t *testing.T) {
+	tests := []struct {
+		decode bool
+		want   int
+	}{
+		{false, http.StatusOK},
+		{true, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		config := testConfig()
+		config.BlockedBrowsers = []BrowserConfig{{Name: "BadBot", Regex: "BadBot/"}}
+		config.DecodeUserAgent = tt.decode
+		h := newTestHandler(t, config, nil)
+		if rec := serve(h, chromeUA+" BadBot%2F1.0"); rec.Code != tt.want {
+			t.Errorf("decodeUserAgent %v: status = %d, want %d", tt.decode, rec.Code, tt.want)
+		}
+	}
+}
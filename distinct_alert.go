@@ -0,0 +1,163 @@
+package traefik_plugin_block_useragents
+
+import (
+	"container/list"
+	"log"
+	"sync"
+	"time"
+)
+
+// defaultDistinctBlockAlertWindow is the rolling window of the distinct
+// blocked User-Agent alert when DistinctBlockAlertWindow is not set.
+const defaultDistinctBlockAlertWindow = 5 * time.Minute
+
+// maxDistinctBlockAlertThreshold bounds the threshold, and with it the number
+// of User-Agents kept by the tracker.
+const maxDistinctBlockAlertThreshold = 100000
+
+// distinctTracker counts the distinct blocked User-Agents seen within a
+// rolling window and raises an alert when the count reaches the threshold.
+// It keeps at most threshold User-Agents: once full, a new User-Agent evicts
+// the least recently seen one, which keeps the count at the threshold.
+type distinctTracker struct {
+	threshold int
+	window    time.Duration
+
+	mu       sync.Mutex
+	order    *list.List // Front is least recently blocked
+	seen     map[string]*list.Element
+	alerting bool
+}
+
+type distinctEntry struct {
+	userAgent string
+	at        time.Time // Last blocked time
+}
+
+// validateDistinctBlockAlert checks the distinct blocked User-Agent alert settings.
+func validateDistinctBlockAlert(config *Config) error {
+	var errs ConfigErrors
+	if config.DistinctBlockAlertThreshold < 0 || config.DistinctBlockAlertThreshold > maxDistinctBlockAlertThreshold {
+		errs.add(configErrorf(ErrInvalidValue, "distinctBlockAlertThreshold must be between 0 and %d, got %d", maxDistinctBlockAlertThreshold, config.DistinctBlockAlertThreshold))
+	}
+	if config.DistinctBlockAlertWindow == "" {
+		return errs.err()
+	}
+	if config.DistinctBlockAlertThreshold == 0 {
+		errs.add(configErrorf(ErrMissingSetting, "distinctBlockAlertWindow requires distinctBlockAlertThreshold"))
+	}
+	window, err := time.ParseDuration(config.DistinctBlockAlertWindow)
+	switch {
+	case err != nil:
+		errs.add(configErrorf(ErrInvalidValue, "invalid distinctBlockAlertWindow %q: %w", config.DistinctBlockAlertWindow, err))
+	case window <= 0:
+		errs.add(configErrorf(ErrInvalidValue, "distinctBlockAlertWindow must be positive"))
+	}
+	return errs.err()
+}
+
+// newDistinctTracker returns the tracker for a validated config, or nil when
+// the alert is disabled.
+func newDistinctTracker(config *Config) *distinctTracker {
+	if config.DistinctBlockAlertThreshold == 0 {
+		return nil
+	}
+	window := defaultDistinctBlockAlertWindow
+	if config.DistinctBlockAlertWindow != "" {
+		window, _ = time.ParseDuration(config.DistinctBlockAlertWindow)
+	}
+	return &distinctTracker{
+		threshold: config.DistinctBlockAlertThreshold,
+		window:    window,
+		order:     list.New(),
+		seen:      make(map[string]*list.Element),
+	}
+}
+
+// record notes a blocked User-Agent and returns the distinct count within the
+// window, along with whether the alert was raised (1) or cleared (-1) by it.
+func (t *distinctTracker) record(userAgent string, now time.Time) (count, change int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.expire(now)
+	if elem, ok := t.seen[userAgent]; ok {
+		elem.Value.(*distinctEntry).at = now
+		t.order.MoveToBack(elem)
+	} else {
+		if t.order.Len() >= t.threshold {
+			oldest := t.order.Front()
+			t.order.Remove(oldest)
+			delete(t.seen, oldest.Value.(*distinctEntry).userAgent)
+		}
+		t.seen[userAgent] = t.order.PushBack(&distinctEntry{userAgent: userAgent, at: now})
+	}
+
+	count = len(t.seen)
+	switch {
+	case count >= t.threshold && !t.alerting:
+		t.alerting = true
+		change = 1
+	case count < t.threshold && t.alerting:
+		t.alerting = false
+		change = -1
+	}
+	return count, change
+}
+
+// expire forgets the User-Agents last blocked before the window. The caller
+// holds t.mu.
+func (t *distinctTracker) expire(now time.Time) {
+	cutoff := now.Add(-t.window)
+	for elem := t.order.Front(); elem != nil; elem = t.order.Front() {
+		entry := elem.Value.(*distinctEntry)
+		if !entry.at.Before(cutoff) {
+			return
+		}
+		t.order.Remove(elem)
+		delete(t.seen, entry.userAgent)
+	}
+}
+
+// reset forgets every User-Agent and clears the alert.
+func (t *distinctTracker) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.order.Init()
+	clear(t.seen)
+	t.alerting = false
+}
+
+// recordDistinctBlock feeds a blocked request to the distinct User-Agent
+// tracker, logging when the alert is raised or cleared.
+func (b *BlockUserAgents) recordDistinctBlock(userAgent string) {
+	if b.distinct == nil {
+		return
+	}
+	count, change := b.distinct.record(userAgent, b.clock.Now())
+	switch change {
+	case 1:
+		log.Printf("%s: WARNING: %d distinct User-Agents blocked within %s, possible User-Agent rotation", b.name, count, b.distinct.window)
+	case -1:
+		log.Printf("%s: distinct blocked User-Agents back below %d within %s", b.name, b.distinct.threshold, b.distinct.window)
+	}
+	b.expvar.distinctBlocked(count, b.distinct.threshold)
+}
+
+// active returns the number of User-Agents blocked within the window.
+func (t *distinctTracker) active(now time.Time) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.expire(now)
+	return len(t.seen)
+}
+
+// DistinctBlockAlert reports whether the number of distinct User-Agents
+// blocked within distinctBlockAlertWindow is at or above
+// distinctBlockAlertThreshold. It is false when the alert is disabled.
+func (b *BlockUserAgents) DistinctBlockAlert() bool {
+	if b.distinct == nil {
+		return false
+	}
+	return b.distinct.active(b.clock.Now()) >= b.distinct.threshold
+}
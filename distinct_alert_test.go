@@ -0,0 +1,78 @@
+package traefik_plugin_block_useragents
+
+import (
+	"expvar"
+	"testing"
+	"time"
+)
+
+func TestDistinctTracker(t *testing.T) {
+	tracker := newDistinctTracker(&Config{DistinctBlockAlertThreshold: 3, DistinctBlockAlertWindow: "1m"})
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	steps := []struct {
+		userAgent  string
+		after      time.Duration
+		wantCount  int
+		wantChange int
+	}{
+		{"a", 0, 1, 0},
+		{"b", 10 * time.Second, 2, 0},
+		{"a", 10 * time.Second, 2, 0},  // Seen again, now the most recent
+		{"c", 10 * time.Second, 3, 1},  // Reaches the threshold
+		{"d", 10 * time.Second, 3, 0},  // Full: evicts b, the least recently blocked
+		{"e", 51 * time.Second, 2, -1}, // a and c expired, d kept
+		{"f", 2 * time.Minute, 1, 0},   // Everything expired
+		{"f", 10 * time.Second, 1, 0},  // Same User-Agent counted once
+		{"g", 0, 2, 0},
+		{"h", 0, 3, 1},                 // Raised again
+		{"i", 61 * time.Second, 1, -1}, // Cleared after the window
+	}
+	for i, step := range steps {
+		now = now.Add(step.after)
+		count, change := tracker.record(step.userAgent, now)
+		if count != step.wantCount || change != step.wantChange {
+			t.Errorf("step %d (%s): record = (%d, %d), want (%d, %d)", i, step.userAgent, count, change, step.wantCount, step.wantChange)
+		}
+	}
+	if got := tracker.active(now.Add(2 * time.Minute)); got != 0 {
+		t.Errorf("active after the window = %d, want 0", got)
+	}
+}
+
+func TestDistinctBlockAlert(t *testing.T) {
+	config := testConfig()
+	config.DistinctBlockAlertThreshold = 2
+	config.Expvar = true
+	h := newTestHandler(t, config, nil)
+	clock := newFakeClock()
+	h.clock = clock
+
+	serve(h, curlUA)
+	if h.DistinctBlockAlert() {
+		t.Error("alert raised below the threshold")
+	}
+	serve(h, firefoxUA)
+	if !h.DistinctBlockAlert() {
+		t.Error("alert not raised at the threshold")
+	}
+	vars := expvar.Get(expvarPrefix + "test").(*expvar.Map)
+	if got := vars.Get("distinct_blocked_uas").String(); got != "2" {
+		t.Errorf("distinct_blocked_uas = %s, want 2", got)
+	}
+	if got := vars.Get("distinct_block_alert").String(); got != "1" {
+		t.Errorf("distinct_block_alert = %s, want 1", got)
+	}
+
+	clock.advance(defaultDistinctBlockAlertWindow + time.Second)
+	if h.DistinctBlockAlert() {
+		t.Error("alert still raised after the window")
+	}
+	serve(h, safariUA)
+	if got := vars.Get("distinct_blocked_uas").String(); got != "1" {
+		t.Errorf("distinct_blocked_uas after the window = %s, want 1", got)
+	}
+	if got := vars.Get("distinct_block_alert").String(); got != "0" {
+		t.Errorf("distinct_block_alert after the window = %s, want 0", got)
+	}
+}
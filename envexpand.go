@@ -0,0 +1,84 @@
+package traefik_plugin_block_useragents
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// expandEnv replaces ${VAR} and ${VAR:default} tokens in a pattern with values
+// from the environment and "$$" with a literal "$". Any other "$" is left
+// untouched so regex end anchors keep working. Referencing an unset variable
+// without a default is an error.
+func expandEnv(pattern string) (string, error) {
+	if !strings.Contains(pattern, "$") {
+		return pattern, nil
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] != '$' || i+1 >= len(pattern) {
+			sb.WriteByte(pattern[i])
+			continue
+		}
+		switch pattern[i+1] {
+		case '$':
+			sb.WriteByte('$')
+			i++
+		case '{':
+			end := strings.IndexByte(pattern[i+2:], '}')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated ${ in pattern %q", pattern)
+			}
+			token := pattern[i+2 : i+2+end]
+			name, def, hasDefault := strings.Cut(token, ":")
+			if name == "" {
+				return "", fmt.Errorf("empty variable name in pattern %q", pattern)
+			}
+			value, ok := os.LookupEnv(name)
+			if !ok {
+				if !hasDefault {
+					return "", fmt.Errorf("environment variable %s referenced in pattern %q is not set", name, pattern)
+				}
+				value = def
+			}
+			sb.WriteString(value)
+			i += end + 2
+		default:
+			sb.WriteByte('$')
+		}
+	}
+	return sb.String(), nil
+}
+
+// validateEnvPatterns checks that every variable referenced by the browser and OS patterns can be expanded.
+func validateEnvPatterns(config *Config) error {
+	var errs ConfigErrors
+	for _, bc := range config.AllowedBrowsers {
+		if _, err := expandEnv(bc.Regex); err != nil {
+			errs.add(configErrorf(ErrInvalidValue, "browser %s: %w", bc.Name, err))
+		}
+	}
+	for _, osPattern := range config.AllowedOSTypes {
+		if _, err := expandEnv(osPattern); err != nil {
+			errs.add(configErrorf(ErrInvalidValue, "%w", err))
+		}
+	}
+	return errs.err()
+}
+
+// withExpandedEnv returns a copy of a validated config with environment
+// variables expanded in the browser and OS patterns.
+func withExpandedEnv(config *Config) *Config {
+	expanded := *config
+	expanded.AllowedBrowsers = make([]BrowserConfig, len(config.AllowedBrowsers))
+	for i, bc := range config.AllowedBrowsers {
+		bc.Regex, _ = expandEnv(bc.Regex)
+		expanded.AllowedBrowsers[i] = bc
+	}
+	expanded.AllowedOSTypes = make([]string, len(config.AllowedOSTypes))
+	for i, osPattern := range config.AllowedOSTypes {
+		expanded.AllowedOSTypes[i], _ = expandEnv(osPattern)
+	}
+	return &expanded
+}
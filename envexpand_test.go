@@ -0,0 +1,40 @@
+package traefik_plugin_block_useragents
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestExpandEnv(t *testing.T) {
+	t.Setenv("TEST_CHROME_VERSION", "13[0-9]")
+	tests := []struct {
+		pattern string
+		want    string
+		wantErr bool
+	}{
+		{"Chrome/${TEST_CHROME_VERSION}", "Chrome/13[0-9]", false},
+		{"Firefox/${TEST_MISSING_VERSION:12[0-9]}", "Firefox/12[0-9]", false},
+		{"^curl/$", "^curl/$", false},
+		{"price $$5", "price $5", false},
+		{"Chrome/${TEST_MISSING_VERSION}", "", true},
+		{"Chrome/${TEST_CHROME_VERSION", "", true},
+		{"Chrome/${}", "", true},
+	}
+	for _, tt := range tests {
+		got, err := expandEnv(tt.pattern)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("expandEnv(%q) = %q, %v, want %q (error %v)", tt.pattern, got, err, tt.want, tt.wantErr)
+		}
+	}
+
+	config := CreateConfig()
+	config.AllowedBrowsers = []BrowserConfig{{Name: "Chrome", Regex: "Chrome/${TEST_CHROME_VERSION}"}}
+	h := newTestHandler(t, config, nil)
+	if rec := serve(h, chromeUA); rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	config.AllowedOSTypes = []string{"${TEST_MISSING_OS}"}
+	if err := ValidateConfig(config); err == nil {
+		t.Error("ValidateConfig with an unset variable = nil, want an error")
+	}
+}
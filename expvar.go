@@ -0,0 +1,164 @@
+package traefik_plugin_block_useragents
+
+import (
+	"expvar"
+	"sync"
+)
+
+// expvarPrefix namespaces the published variables of each plugin instance.
+const expvarPrefix = "block_useragents."
+
+// expvarMu serializes lookups and registrations in the global expvar registry.
+var expvarMu sync.Mutex
+
+// expvarMetrics publishes the plugin counters through expvar (/debug/vars).
+// A nil *expvarMetrics records nothing.
+type expvarMetrics struct {
+	vars    *expvar.Map
+	blocked *expvar.Map
+
+	distinct      *expvar.Int // Set by registerDistinct
+	distinctAlert *expvar.Int
+}
+
+// newExpvarMetrics returns the counters published for the instance name.
+// expvar cannot unregister variables and panics on duplicate names, while
+// Traefik recreates instances with the same name on every configuration
+// reload, so instances sharing a name share (and keep) the same counters.
+// If the name is taken by a variable of another type, nothing is published.
+func newExpvarMetrics(name string) *expvarMetrics {
+	expvarMu.Lock()
+	defer expvarMu.Unlock()
+
+	key := expvarPrefix + name
+	vars, ok := expvar.Get(key).(*expvar.Map)
+	if !ok {
+		if expvar.Get(key) != nil {
+			return nil
+		}
+		vars = expvar.NewMap(key)
+	}
+	blocked, ok := vars.Get("blocked").(*expvar.Map)
+	if !ok {
+		blocked = new(expvar.Map).Init()
+		vars.Set("blocked", blocked)
+	}
+	return &expvarMetrics{vars: vars, blocked: blocked}
+}
+
+// allowed counts an allowed request.
+func (m *expvarMetrics) allowed() {
+	if m != nil {
+		m.vars.Add("allowed", 1)
+	}
+}
+
+// blockedRequest counts a blocked request by reason.
+func (m *expvarMetrics) blockedRequest(reason string) {
+	if m != nil {
+		m.blocked.Add(reason, 1)
+	}
+}
+
+// cacheResult counts a decision cache hit or miss.
+func (m *expvarMetrics) cacheResult(hit bool) {
+	if m == nil {
+		return
+	}
+	if hit {
+		m.vars.Add("cache_hits", 1)
+	} else {
+		m.vars.Add("cache_misses", 1)
+	}
+}
+
+// evalTimeout counts an evaluation abandoned after the match timeout.
+func (m *expvarMetrics) evalTimeout() {
+	if m != nil {
+		m.vars.Add("eval_timeouts", 1)
+	}
+}
+
+// registerLabels publishes zeroed allowed and blocked counters for each
+// rule label under "labels". Only these labels are counted, which bounds the
+// number of published variables to the configured labels.
+func (m *expvarMetrics) registerLabels(labels []string) {
+	if m == nil || len(labels) == 0 {
+		return
+	}
+	expvarMu.Lock()
+	defer expvarMu.Unlock()
+
+	all, ok := m.vars.Get("labels").(*expvar.Map)
+	if !ok {
+		all = new(expvar.Map).Init()
+		m.vars.Set("labels", all)
+	}
+	for _, label := range labels {
+		if _, ok := all.Get(label).(*expvar.Map); ok {
+			continue
+		}
+		counters := new(expvar.Map).Init()
+		counters.Add("allowed", 0)
+		counters.Add("blocked", 0)
+		all.Set(label, counters)
+	}
+}
+
+// labeled counts a request allowed or blocked by a labeled rule.
+func (m *expvarMetrics) labeled(label string, allowed bool) {
+	if m == nil {
+		return
+	}
+	all, ok := m.vars.Get("labels").(*expvar.Map)
+	if !ok {
+		return
+	}
+	counters, ok := all.Get(label).(*expvar.Map)
+	if !ok {
+		return
+	}
+	if allowed {
+		counters.Add("allowed", 1)
+	} else {
+		counters.Add("blocked", 1)
+	}
+}
+
+// registerDistinct publishes the distinct blocked User-Agent count and alert
+// state, updated by distinctBlocked.
+func (m *expvarMetrics) registerDistinct() {
+	if m == nil {
+		return
+	}
+	expvarMu.Lock()
+	defer expvarMu.Unlock()
+
+	m.distinct = m.publishedInt("distinct_blocked_uas")
+	m.distinctAlert = m.publishedInt("distinct_block_alert")
+}
+
+// publishedInt returns the integer published under key, publishing a new one
+// if needed. The caller holds expvarMu.
+func (m *expvarMetrics) publishedInt(key string) *expvar.Int {
+	v, ok := m.vars.Get(key).(*expvar.Int)
+	if !ok {
+		v = new(expvar.Int)
+		m.vars.Set(key, v)
+	}
+	return v
+}
+
+// distinctBlocked publishes the distinct blocked User-Agent count and whether
+// it reached the alert threshold.
+func (m *expvarMetrics) distinctBlocked(count, threshold int) {
+	if m == nil || m.distinct == nil {
+		return
+	}
+	m.distinct.Set(int64(count))
+	alert := int64(0)
+	if count >= threshold {
+		alert = 1
+	}
+	m.distinctAlert.Set(alert)
+}
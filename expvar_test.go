@@ -0,0 +1,47 @@
+package traefik_plugin_block_useragents
+
+import (
+	"context"
+	"expvar"
+	"testing"
+)
+
+func TestExpvar(t *testing.T) {
+	config := testConfig()
+	config.Expvar = true
+	config.CacheSize = 8
+	handler, err := New(context.Background(), okHandler, config, "expvar-test")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	h := handler.(*BlockUserAgents)
+	t.Cleanup(func() { _ = h.Close() })
+
+	serve(h, chromeUA)
+	serve(h, chromeUA)
+	serve(h, curlUA)
+
+	vars, ok := expvar.Get("block_useragents.expvar-test").(*expvar.Map)
+	if !ok {
+		t.Fatal("block_useragents.expvar-test is not published")
+	}
+	for key, want := range map[string]string{"allowed": "2", "cache_hits": "1", "cache_misses": "2"} {
+		if got := vars.Get(key); got == nil || got.String() != want {
+			t.Errorf("%s = %v, want %s", key, got, want)
+		}
+	}
+	if got := h.expvar.blocked.Get("Unsupported Browser"); got == nil || got.String() != "1" {
+		t.Errorf("blocked[Unsupported Browser] = %v, want 1", got)
+	}
+
+	// A reloaded instance with the same name keeps counting.
+	if again := newExpvarMetrics("expvar-test"); again == nil || again.vars != vars {
+		t.Error("newExpvarMetrics with a published name did not share its counters")
+	}
+	expvar.NewString("block_useragents.expvar-taken")
+	if m := newExpvarMetrics("expvar-taken"); m != nil {
+		t.Errorf("newExpvarMetrics with a name taken by another type = %v, want nil", m)
+	}
+	m := (*expvarMetrics)(nil)
+	m.allowed() // A nil *expvarMetrics records nothing
+}
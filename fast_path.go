@@ -0,0 +1,48 @@
+package traefik_plugin_block_useragents
+
+import (
+	"net/http"
+	"regexp"
+	"slices"
+)
+
+// singleRuleFastPath returns the pattern of the only allowed browser rule when
+// the configuration consists of nothing else, the most common shape. Such
+// requests are then evaluated without going through each dimension. It
+// returns nil when any other check is configured.
+func (b *BlockUserAgents) singleRuleFastPath() *regexp.Regexp {
+	if len(b.allowedRules) != 1 || b.requireMatchCount > 1 || b.allowGRPC || b.defaultAllow {
+		return nil
+	}
+	rule := b.allowedRules[0]
+	if len(rule.except) > 0 || len(rule.methods) > 0 || rule.label != "" {
+		return nil
+	}
+	if len(b.osRegexpsAllow) > 0 || len(b.osVersionRules) > 0 || len(b.brandVersionRules) > 0 || len(b.blockedRules) > 0 || len(b.denyRules) > 0 ||
+		len(b.challengeRegexps) > 0 || len(b.orderedRules) > 0 || b.defaultAction != "" ||
+		len(b.allowedFingerprints) > 0 || b.checkOrigin || b.checkLanguageEnabled || len(b.softRules) > 0 ||
+		len(b.scoreRules) > 0 || len(b.requiredHeaders) > 0 || len(b.forbiddenHeaders) > 0 ||
+		b.checkPlausibilityEnabled || b.minUASegments > 0 || b.matchCombinedIdentity {
+		return nil
+	}
+	return rule.re
+}
+
+// evaluateSingleRule is the fast path of evaluate for single-rule
+// configurations. It returns the same decisions as the generic pipeline.
+func (b *BlockUserAgents) evaluateSingleRule(req *http.Request) decision {
+	userAgents := b.userAgentValues(req)
+	if len(userAgents) == 0 && b.userAgentCheckedFirst() {
+		return block("No User-Agent")
+	}
+	if !b.matchesBrowser(b.singleRule, userAgents) {
+		return block("Unsupported Browser")
+	}
+	return allow()
+}
+
+// userAgentCheckedFirst reports whether the missing User-Agent check runs
+// before the browser check, which decides the reason for an empty User-Agent.
+func (b *BlockUserAgents) userAgentCheckedFirst() bool {
+	return slices.Index(b.evaluationOrder, DimensionUserAgent) < slices.Index(b.evaluationOrder, DimensionBrowser)
+}
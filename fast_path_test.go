@@ -0,0 +1,77 @@
+package traefik_plugin_block_useragents
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSingleRuleFastPath(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   func(*Config)
+		wantFast bool
+	}{
+		{"single rule", func(*Config) {}, true},
+		{"two rules", func(c *Config) {
+			c.AllowedBrowsers = append(c.AllowedBrowsers, BrowserConfig{Name: "Firefox", Regex: "Firefox/"})
+		}, false},
+		{"blocked browsers", func(c *Config) { c.BlockedBrowsers = []BrowserConfig{{Name: "curl", Regex: "curl"}} }, false},
+		{"OS types", func(c *Config) { c.AllowedOSTypes = []string{"Windows"} }, false},
+		{"labeled rule", func(c *Config) { c.AllowedBrowsers[0].Label = "chrome" }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			tt.config(config)
+			h := newTestHandler(t, config, nil)
+			if got := h.singleRule != nil; got != tt.wantFast {
+				t.Errorf("fast path = %v, want %v", got, tt.wantFast)
+			}
+		})
+	}
+}
+
+func TestSingleRuleFastPathDecisions(t *testing.T) {
+	orders := [][]string{nil, {DimensionBrowser, DimensionUserAgent}}
+	for _, order := range orders {
+		config := testConfig()
+		config.EvaluationOrder = order
+		h := newTestHandler(t, config, nil)
+		if h.singleRule == nil {
+			t.Fatal("fast path not taken")
+		}
+		generic := newTestHandler(t, config, nil)
+		generic.singleRule = nil
+
+		for _, userAgent := range []string{chromeUA, firefoxUA, curlUA, ""} {
+			req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+			req.Header.Set("User-Agent", userAgent)
+			if got, want := h.evaluate(req), generic.evaluate(req); got.allowed != want.allowed || got.reason != want.reason {
+				t.Errorf("order %q, %q: fast path decision %+v, generic decision %+v", order, userAgent, got, want)
+			}
+		}
+	}
+}
+
+// BenchmarkSingleRule evaluates a single allowed browser on the fast path and
+// through the generic pipeline.
+func BenchmarkSingleRule(b *testing.B) {
+	h := newTestHandler(b, testConfig(), nil)
+	generic := newTestHandler(b, testConfig(), nil)
+	generic.singleRule = nil
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+	req.Header.Set("User-Agent", chromeUA)
+
+	for _, tt := range []struct {
+		name string
+		h    *BlockUserAgents
+	}{{"fast", h}, {"generic", generic}} {
+		b.Run(tt.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				tt.h.evaluate(req)
+			}
+		})
+	}
+}
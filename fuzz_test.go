@@ -0,0 +1,72 @@
+package traefik_plugin_block_useragents
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// FuzzEvaluate feeds arbitrary User-Agents and allowed browser patterns
+// through the middleware, checking that it never panics and decides the same
+// way with and without the decision cache.
+func FuzzEvaluate(f *testing.F) {
+	f.Add(chromeUA, `Chrome/\d+`)
+	f.Add(curlUA, `^curl/`)
+	f.Add("\xff\xfe\xfd", `.`)
+	f.Add(strings.Repeat("Mozilla/5.0 ", 2000), `Mozilla`)
+	f.Add("Chrome/%ZZ&amp;", `Chrome/`)
+	f.Add("", `^$`)
+	log.SetOutput(io.Discard) // Every input builds two instances and may block
+	f.Cleanup(func() { log.SetOutput(os.Stderr) })
+	f.Fuzz(func(t *testing.T, userAgent, pattern string) {
+		if _, err := regexp.Compile(pattern); err != nil {
+			t.Skip()
+		}
+		var handlers []*BlockUserAgents
+		for _, cacheSize := range []int{0, 16} {
+			config := CreateConfig()
+			config.AllowedBrowsers = []BrowserConfig{{Name: "Fuzz", Regex: pattern}}
+			config.AllowedOSTypes = []string{`Windows|Linux|Mac`}
+			config.DecodeUserAgent = true
+			config.CacheSize = cacheSize
+			handler, err := New(context.Background(), okHandler, config, "fuzz")
+			if err != nil {
+				t.Skip() // Patterns rejected by validation, e.g. too long
+			}
+			b := handler.(*BlockUserAgents)
+			defer b.Close()
+			handlers = append(handlers, b)
+		}
+		withUserAgent := func(req *http.Request) { req.Header["User-Agent"] = []string{userAgent} }
+		uncached := serve(handlers[0], "", withUserAgent).Code
+		if uncached != http.StatusOK && uncached != http.StatusForbidden {
+			t.Fatalf("status = %d, want 200 or 403", uncached)
+		}
+		for i := 0; i < 2; i++ { // Miss, then hit
+			if cached := serve(handlers[1], "", withUserAgent).Code; cached != uncached {
+				t.Fatalf("cached status = %d, uncached %d", cached, uncached)
+			}
+		}
+	})
+}
+
+// FuzzParseUserAgent checks that the best-effort parser never panics and
+// that the version parser accepts only what it can compare.
+func FuzzParseUserAgent(f *testing.F) {
+	f.Add(chromeUA)
+	f.Add("Firefox/1.2.3.4.5.6.7.8.9.99999999999999999999")
+	f.Add("Version/\xff Safari/")
+	f.Fuzz(func(t *testing.T, userAgent string) {
+		parseUserAgent(userAgent)
+		if _, version, ok := firstToken(browserTokens, userAgent); ok {
+			if parsed, err := parseVersion(version); err == nil {
+				compareVersions(parsed, parsed)
+			}
+		}
+	})
+}
@@ -1,3 +1,5 @@
 module github.com/mkopnsrc/traefik-plugin-block-useragents
 
-go 1.22.0
\ No newline at end of file
+go 1.22.0
+
+require gopkg.in/yaml.v3 v3.0.1
@@ -0,0 +1,32 @@
+package traefik_plugin_block_useragents
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// gRPC status codes used for block responses.
+const (
+	grpcPermissionDenied  = 7
+	grpcResourceExhausted = 8
+)
+
+// isGRPC reports whether the request is a gRPC call.
+func isGRPC(req *http.Request) bool {
+	return strings.HasPrefix(req.Header.Get("Content-Type"), "application/grpc")
+}
+
+// writeGRPCBlock writes a trailers-only gRPC error response, which gRPC
+// clients can parse, instead of a plain HTTP error status.
+func writeGRPCBlock(res http.ResponseWriter, d decision) {
+	code := grpcPermissionDenied
+	if d.status == http.StatusTooManyRequests {
+		code = grpcResourceExhausted
+	}
+	res.Header().Set("Content-Type", "application/grpc")
+	res.Header().Set("Grpc-Status", strconv.Itoa(code))
+	res.Header().Set("Grpc-Message", url.PathEscape(d.reason))
+	res.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,58 @@
+package traefik_plugin_block_useragents
+
+import (
+	"net/http"
+	"testing"
+)
+
+const grpcUA = "grpc-go/1.64.0"
+
+func TestGRPC(t *testing.T) {
+	tests := []struct {
+		name        string
+		allowGRPC   bool
+		contentType string
+		wantStatus  int
+		wantGRPC    string // Grpc-Status, empty for a plain HTTP response
+	}{
+		{"blocked gRPC call", false, "application/grpc", http.StatusOK, "7"},
+		{"blocked gRPC+proto call", false, "application/grpc+proto", http.StatusOK, "7"},
+		{"allowed gRPC call", true, "application/grpc", http.StatusOK, ""},
+		{"plain request with allowGrpc", true, "application/json", http.StatusForbidden, ""},
+		{"plain request", false, "", http.StatusForbidden, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.AllowGRPC = tt.allowGRPC
+			h := newTestHandler(t, config, nil)
+
+			rec := serve(h, grpcUA, withMethod(http.MethodPost), withHeader("Content-Type", tt.contentType))
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Grpc-Status"); got != tt.wantGRPC {
+				t.Errorf("Grpc-Status = %q, want %q", got, tt.wantGRPC)
+			}
+			if tt.wantGRPC != "" && rec.Header().Get("Grpc-Message") != "Unsupported%20Browser" {
+				t.Errorf("Grpc-Message = %q", rec.Header().Get("Grpc-Message"))
+			}
+		})
+	}
+}
+
+func TestGRPCRateLimited(t *testing.T) {
+	config := testConfig()
+	config.AllowGRPC = true
+	config.RateLimits = []RateLimitRule{{Name: "grpc", Regex: "^grpc-go/", Requests: 1}}
+	h := newTestHandler(t, config, nil)
+
+	var got []string
+	for i := 0; i < 2; i++ {
+		rec := serve(h, grpcUA, withMethod(http.MethodPost), withHeader("Content-Type", "application/grpc"))
+		got = append(got, rec.Header().Get("Grpc-Status"))
+	}
+	if got[0] != "" || got[1] != "8" {
+		t.Errorf("Grpc-Status = %q, want the second call RESOURCE_EXHAUSTED (8)", got)
+	}
+}
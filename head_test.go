@@ -0,0 +1,48 @@
+package traefik_plugin_block_useragents
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestHEADResponsesHaveNoBody(t *testing.T) {
+	tests := []struct {
+		name       string
+		config     func(*Config)
+		userAgent  string
+		wantStatus int
+	}{
+		{"block message", func(c *Config) {
+			c.MessagesByReason = map[string]string{"Unsupported Browser": "Please use a supported browser."}
+		}, curlUA, http.StatusForbidden},
+		{"block template", func(c *Config) {
+			c.BlockResponseTemplate = "<p>{{.Reason}} for {{.UserAgent}}</p>"
+		}, curlUA, http.StatusForbidden},
+		{"challenge page", func(c *Config) {
+			c.ChallengeBrowsers = []string{"^curl/"}
+			c.ChallengeSecret = "secret"
+		}, curlUA, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			tt.config(config)
+			h := newTestHandler(t, config, nil)
+
+			get := serve(h, tt.userAgent)
+			head := serve(h, tt.userAgent, withMethod(http.MethodHead))
+			if get.Code != tt.wantStatus || head.Code != tt.wantStatus {
+				t.Fatalf("status = %d (GET), %d (HEAD), want %d", get.Code, head.Code, tt.wantStatus)
+			}
+			if get.Body.Len() == 0 {
+				t.Fatal("GET response has no body")
+			}
+			if head.Body.Len() != 0 {
+				t.Errorf("HEAD response body = %q, want empty", head.Body)
+			}
+			if got, want := head.Header().Get("Content-Length"), get.Header().Get("Content-Length"); got != want {
+				t.Errorf("HEAD Content-Length = %q, want %q as for GET", got, want)
+			}
+		})
+	}
+}
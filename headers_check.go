@@ -0,0 +1,63 @@
+package traefik_plugin_block_useragents
+
+import (
+	"net/http"
+	"strings"
+)
+
+// validateHeaderLists checks the required and forbidden header names.
+func validateHeaderLists(config *Config) error {
+	var errs ConfigErrors
+	for _, header := range config.RequiredHeaders {
+		if !headerNamePattern.MatchString(header) {
+			errs.add(configErrorf(ErrInvalidValue, "invalid required header name %q", header))
+		}
+	}
+	for _, header := range config.ForbiddenHeaders {
+		if !headerNamePattern.MatchString(header) {
+			errs.add(configErrorf(ErrInvalidValue, "invalid forbidden header name %q", header))
+		}
+	}
+	return errs.err()
+}
+
+// canonicalHeaders returns the canonical form of the header names.
+func canonicalHeaders(headers []string) []string {
+	canonical := make([]string, 0, len(headers))
+	for _, header := range headers {
+		canonical = append(canonical, http.CanonicalHeaderKey(header))
+	}
+	return canonical
+}
+
+// checkHeaders blocks requests missing a required header or carrying a
+// forbidden one. A header is present when it has a value, even an empty one.
+func (b *BlockUserAgents) checkHeaders(e *evaluation) *decision {
+	for _, header := range b.requiredHeaders {
+		if _, ok := e.req.Header[header]; !ok {
+			return blockDecision("Missing Required Header")
+		}
+	}
+	for _, header := range b.forbiddenHeaders {
+		if _, ok := e.req.Header[header]; ok {
+			return blockDecision("Forbidden Header")
+		}
+	}
+	return nil
+}
+
+// headerPresence returns the presence of the checked headers as a string of
+// 0s and 1s, for the decision cache key.
+func (b *BlockUserAgents) headerPresence(req *http.Request) string {
+	var sb strings.Builder
+	for _, headers := range [][]string{b.requiredHeaders, b.forbiddenHeaders} {
+		for _, header := range headers {
+			if _, ok := req.Header[header]; ok {
+				sb.WriteByte('1')
+			} else {
+				sb.WriteByte('0')
+			}
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,46 @@
+package traefik_plugin_block_useragents
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequiredAndForbiddenHeaders(t *testing.T) {
+	tests := []struct {
+		name       string
+		headers    map[string]string
+		wantReason string // Empty when allowed
+	}{
+		{"required header present", map[string]string{"Accept-Language": "en"}, ""},
+		{"required header missing", nil, "Missing Required Header"},
+		{"required header present but empty", map[string]string{"Accept-Language": ""}, ""},
+		{"forbidden header present", map[string]string{"Accept-Language": "en", "X-Scanner": "1"}, "Forbidden Header"},
+		{"forbidden header present but empty", map[string]string{"Accept-Language": "en", "X-Scanner": ""}, "Forbidden Header"},
+		{"forbidden header in another case", map[string]string{"accept-language": "en", "x-scanner": "1"}, "Forbidden Header"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.RequiredHeaders = []string{"accept-language"}
+			config.ForbiddenHeaders = []string{"X-Scanner"}
+			config.CacheSize = 8 // Header presence is part of the cache key
+			h := newTestHandler(t, config, nil)
+
+			// A cached decision for the same User-Agent must not leak
+			warm := httptest.NewRequest("GET", "http://example.com/", nil)
+			warm.Header.Set("User-Agent", chromeUA)
+			warm.Header.Set("Accept-Language", "en")
+			h.cachedEvaluate(warm)
+
+			req := httptest.NewRequest("GET", "http://example.com/", nil)
+			req.Header.Set("User-Agent", chromeUA)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			d := h.cachedEvaluate(req)
+			if d.allowed != (tt.wantReason == "") || d.reason != tt.wantReason {
+				t.Errorf("evaluate = (allowed %v, reason %q), want reason %q", d.allowed, d.reason, tt.wantReason)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package traefik_plugin_block_useragents
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Real User-Agents used across the tests.
+const (
+	chromeUA  = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.0.0 Safari/537.36"
+	firefoxUA = "Mozilla/5.0 (X11; Linux x86_64; rv:128.0) Gecko/20100101 Firefox/128.0"
+	safariUA  = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Safari/605.1.15"
+	curlUA    = "curl/8.0"
+)
+
+// testConfig returns the default configuration allowing Chrome.
+func testConfig() *Config {
+	config := CreateConfig()
+	config.AllowedBrowsers = []BrowserConfig{{Name: "Chrome", Regex: `Chrome/\d+`}}
+	return config
+}
+
+// okHandler answers 200, standing for the backend.
+var okHandler = http.HandlerFunc(func(res http.ResponseWriter, _ *http.Request) {
+	res.WriteHeader(http.StatusOK)
+})
+
+// newTestHandler builds the middleware in front of next (okHandler when nil),
+// closed at the end of the test.
+func newTestHandler(t testing.TB, config *Config, next http.Handler) *BlockUserAgents {
+	t.Helper()
+	if next == nil {
+		next = okHandler
+	}
+	handler, err := New(context.Background(), next, config, "test")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	b := handler.(*BlockUserAgents)
+	t.Cleanup(func() { _ = b.Close() })
+	return b
+}
+
+// serve sends a GET request with the given User-Agent, after applying the
+// optional edits, and returns the recorded response.
+func serve(h http.Handler, userAgent string, edits ...func(*http.Request)) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+	if userAgent != "" {
+		req.Header.Set("User-Agent", userAgent)
+	}
+	for _, edit := range edits {
+		edit(req)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+// withHeader returns an edit setting a request header.
+func withHeader(name, value string) func(*http.Request) {
+	return func(req *http.Request) { req.Header.Set(name, value) }
+}
+
+// withHost returns an edit setting the request host.
+func withHost(host string) func(*http.Request) {
+	return func(req *http.Request) { req.Host = host }
+}
+
+// withTLS marks the request as received over TLS 1.3.
+func withTLS(req *http.Request) {
+	req.TLS = &tls.ConnectionState{Version: tls.VersionTLS13, ServerName: req.Host}
+}
+
+// withMethod returns an edit setting the request method.
+func withMethod(method string) func(*http.Request) {
+	return func(req *http.Request) { req.Method = method }
+}
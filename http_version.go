@@ -0,0 +1,13 @@
+package traefik_plugin_block_useragents
+
+import "net/http"
+
+// checkHTTPVersion blocks requests made over HTTP/1.0 or older, which
+// current browsers no longer use. HTTP/1.1, HTTP/2 and HTTP/3 pass. It
+// returns nil when the request passes.
+func (b *BlockUserAgents) checkHTTPVersion(req *http.Request) *decision {
+	if !b.blockHTTP10 || req.ProtoAtLeast(1, 1) {
+		return nil
+	}
+	return blockDecision("Obsolete HTTP Version")
+}
@@ -0,0 +1,42 @@
+package traefik_plugin_block_useragents
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestBlockHTTP10(t *testing.T) {
+	withProto := func(major, minor int) func(*http.Request) {
+		return func(req *http.Request) { req.ProtoMajor, req.ProtoMinor = major, minor }
+	}
+	tests := []struct {
+		name        string
+		blockHTTP10 bool
+		major       int
+		minor       int
+		want        int
+	}{
+		{"HTTP/1.0 blocked", true, 1, 0, http.StatusForbidden},
+		{"HTTP/0.9 blocked", true, 0, 9, http.StatusForbidden},
+		{"HTTP/1.1 passes", true, 1, 1, http.StatusOK},
+		{"HTTP/2 passes", true, 2, 0, http.StatusOK},
+		{"HTTP/3 passes", true, 3, 0, http.StatusOK},
+		{"HTTP/1.0 without blockHttp10", false, 1, 0, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.BlockHTTP10 = tt.blockHTTP10
+			config.ExposeReasonHeader = true
+			h := newTestHandler(t, config, nil)
+
+			rec := serve(h, chromeUA, withProto(tt.major, tt.minor))
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if tt.want == http.StatusForbidden && rec.Header().Get("X-Block-Reason") != "Obsolete HTTP Version" {
+				t.Errorf("reason = %q", rec.Header().Get("X-Block-Reason"))
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package traefik_plugin_block_useragents
+
+// Policy modes reported by Mode.
+const (
+	ModeAllowlist = "allowlist"
+	ModeDenylist  = "denylist"
+	ModeRules     = "rules"
+)
+
+// Rules returns the patterns of the allowed browser rules, in evaluation order.
+// Glob patterns are returned in their translated regex form.
+func (b *BlockUserAgents) Rules() []string {
+	patterns := make([]string, 0, len(b.allowedRules))
+	for _, rule := range b.allowedRules {
+		patterns = append(patterns, rule.re.String())
+	}
+	return patterns
+}
+
+// OSRules returns the patterns of the allowed OS rules.
+func (b *BlockUserAgents) OSRules() []string {
+	patterns := make([]string, 0, len(b.osRegexpsAllow))
+	for _, re := range b.osRegexpsAllow {
+		patterns = append(patterns, re.String())
+	}
+	return patterns
+}
+
+// Mode reports the effective policy: "rules" when the ordered rules decide
+// every request through a default action, "denylist" when only deny rules
+// restrict browsers or unmatched browsers are allowed by the default
+// decision, and "allowlist" otherwise.
+func (b *BlockUserAgents) Mode() string {
+	switch {
+	case b.defaultAction != "":
+		return ModeRules
+	case len(b.allowedRules) == 0 || b.defaultAllow:
+		return ModeDenylist
+	default:
+		return ModeAllowlist
+	}
+}
+
+// DryRun reports whether User-Agent decisions are currently only logged
+// instead of enforced, which is the case in learn mode and while the
+// enforcement delay runs.
+func (b *BlockUserAgents) DryRun() bool {
+	return b.learner != nil || b.warmingUp()
+}
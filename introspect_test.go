@@ -0,0 +1,45 @@
+package traefik_plugin_block_useragents
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIntrospection(t *testing.T) {
+	config := testConfig()
+	config.AllowedBrowsers = append(config.AllowedBrowsers, BrowserConfig{Name: "Firefox", Regex: "Firefox/"})
+	config.AllowedOSTypes = []string{"Windows"}
+	h := newTestHandler(t, config, nil)
+
+	if got, want := h.Rules(), []string{`Chrome/\d+`, "Firefox/"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Rules() = %q, want %q", got, want)
+	}
+	if got := h.OSRules(); len(got) != 1 {
+		t.Errorf("OSRules() = %q, want one pattern", got)
+	}
+	if got := h.Mode(); got != ModeAllowlist {
+		t.Errorf("Mode() = %q, want %q", got, ModeAllowlist)
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	tests := []struct {
+		name   string
+		config func(*Config)
+		want   bool
+	}{
+		{"enforcing", func(*Config) {}, false},
+		{"learn mode", func(c *Config) { c.LearnMode = true }, true},
+		{"enforcement delay", func(c *Config) { c.EnforcementDelay = "1h" }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			tt.config(config)
+			h := newTestHandler(t, config, nil)
+			if got := h.DryRun(); got != tt.want {
+				t.Errorf("DryRun() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
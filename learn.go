@@ -0,0 +1,248 @@
+package traefik_plugin_block_useragents
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultLearnInterval is the time between suggestions when LearnInterval is
+// not set.
+const defaultLearnInterval = time.Hour
+
+// maxLearnedBrowsers bounds the browser families tracked in learn mode;
+// families seen once the bound is reached are not suggested.
+const maxLearnedBrowsers = 64
+
+// learnSpecNames maps the browser families of the User-Agent parser that are
+// suggested in learn mode to their browser spec names. Other families, such
+// as bots and HTTP libraries, are not suggested.
+var learnSpecNames = map[string]string{
+	"Edge":             "Edge",
+	"Opera":            "Opera",
+	"Samsung Internet": "SamsungBrowser",
+	"Brave":            "Brave",
+	"Chrome for iOS":   "CriOS",
+	"Firefox for iOS":  "FxiOS",
+	"Firefox":          "Firefox",
+	"Chrome":           "Chrome",
+	"Safari":           "Safari",
+}
+
+// learner records the browser and OS families of the requests seen in learn
+// mode and periodically reports the rules allowing them.
+type learner struct {
+	name     string
+	file     string // Optional: Rules file the suggestion is written to
+	interval time.Duration
+
+	mu       sync.Mutex
+	browsers map[string]int      // Lowest major version seen per browser family
+	oses     map[string]struct{} // OS families seen
+	observed int
+	skipped  int // User-Agents whose browser is not suggested
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// validateLearnMode checks the learn mode settings.
+func validateLearnMode(config *Config) error {
+	if !config.LearnMode {
+		if config.LearnInterval != "" || config.LearnFile != "" {
+			return configErrorf(ErrMissingSetting, "learnInterval and learnFile require learnMode")
+		}
+		return nil
+	}
+	if config.LearnInterval != "" {
+		interval, err := time.ParseDuration(config.LearnInterval)
+		switch {
+		case err != nil:
+			return configErrorf(ErrInvalidValue, "invalid learnInterval %q: %w", config.LearnInterval, err)
+		case interval < time.Second:
+			return configErrorf(ErrInvalidValue, "learnInterval must be at least 1s, got %s", interval)
+		}
+	}
+	return nil
+}
+
+// newLearner starts the learner of a validated config, or returns nil when
+// learn mode is off. It reports until close is called or ctx, the
+// construction context, is cancelled.
+func newLearner(ctx context.Context, config *Config, name string) *learner {
+	if !config.LearnMode {
+		return nil
+	}
+	l := &learner{
+		name:     name,
+		interval: defaultLearnInterval,
+		browsers: make(map[string]int),
+		oses:     make(map[string]struct{}),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if config.LearnFile != "" {
+		l.file = filepath.Clean(config.LearnFile)
+	}
+	if config.LearnInterval != "" {
+		l.interval, _ = time.ParseDuration(config.LearnInterval)
+	}
+	log.Printf("%s: learn mode, requests are not checked; suggested rules are reported every %s", name, l.interval)
+	go l.reportEvery(ctx)
+	return l
+}
+
+// observe records the families of a User-Agent.
+func (l *learner) observe(userAgent string) {
+	browser, version, _ := firstToken(browserTokens, userAgent)
+	osFamily, _, _ := firstToken(osTokens, userAgent)
+	major, err := strconv.Atoi(majorVersion(version))
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.observed++
+	if _, ok := learnSpecNames[browser]; !ok || err != nil {
+		l.skipped++
+		return
+	}
+	if lowest, ok := l.browsers[browser]; ok {
+		l.browsers[browser] = min(lowest, major)
+	} else if len(l.browsers) < maxLearnedBrowsers {
+		l.browsers[browser] = major
+	} else {
+		l.skipped++
+		return
+	}
+	if osFamily != "" {
+		l.oses[osFamily] = struct{}{}
+	}
+}
+
+// majorVersion returns the leading number of a version.
+func majorVersion(version string) string {
+	for i := 0; i < len(version); i++ {
+		if version[i] < '0' || version[i] > '9' {
+			return version[:i]
+		}
+	}
+	return version
+}
+
+// suggestion returns the rules allowing the browser families seen from their
+// lowest major version on, and the OS families they ran on, in the rulesFile
+// format.
+func (l *learner) suggestion() (*rulesFileContent, int, int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	rules := &rulesFileContent{AllowedBrowsers: []BrowserConfig{}, AllowedOSTypes: []string{}}
+	for browser, lowest := range l.browsers {
+		rules.AllowedBrowsers = append(rules.AllowedBrowsers, BrowserConfig{
+			Name:    browser,
+			Names:   []string{learnSpecNames[browser]},
+			Version: fmt.Sprintf(">= %d", lowest),
+		})
+	}
+	sort.Slice(rules.AllowedBrowsers, func(i, j int) bool { return rules.AllowedBrowsers[i].Name < rules.AllowedBrowsers[j].Name })
+	families := make([]string, 0, len(l.oses))
+	for family := range l.oses {
+		families = append(families, family)
+	}
+	sort.Strings(families)
+	for _, family := range families {
+		if pattern, err := osDetectorPattern(family); err == nil {
+			rules.AllowedOSTypes = append(rules.AllowedOSTypes, pattern)
+		}
+	}
+	return rules, l.observed, l.skipped
+}
+
+// reportEvery reports the suggestion every interval until close is called or
+// ctx is cancelled, then a last time.
+func (l *learner) reportEvery(ctx context.Context) {
+	defer close(l.done)
+	ticker := time.NewTicker(l.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			l.report()
+		case <-l.stop:
+			l.report()
+			return
+		case <-ctx.Done():
+			l.report()
+			return
+		}
+	}
+}
+
+// report logs the suggestion and writes it to the learn file, if any.
+func (l *learner) report() {
+	rules, observed, skipped := l.suggestion()
+	if observed == 0 {
+		return
+	}
+	encoded, err := encodeRulesJSON(rules, "")
+	if err != nil {
+		log.Printf("%s: error encoding the suggested rules: %v", l.name, err)
+		return
+	}
+	log.Printf("%s: learn mode observed %d User-Agents (%d not suggested), suggested rules: %s", l.name, observed, skipped, bytes.TrimSpace(encoded))
+	if l.file != "" {
+		if err := writeRulesFile(l.file, rules); err != nil {
+			log.Printf("%s: error writing the suggested rules: %v", l.name, err)
+		}
+	}
+}
+
+// writeRulesFile replaces a rules file with rules, in YAML or JSON depending
+// on its extension as loadRulesFile expects.
+func writeRulesFile(name string, rules *rulesFileContent) error {
+	var (
+		data []byte
+		err  error
+	)
+	if isYAMLPath(name) {
+		data, err = yaml.Marshal(rules)
+	} else {
+		data, err = encodeRulesJSON(rules, "  ")
+	}
+	if err != nil {
+		return err
+	}
+	tmp := name + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
+
+// encodeRulesJSON encodes rules as a JSON line, indented with indent when
+// set, keeping the version operators readable.
+func encodeRulesJSON(rules *rulesFileContent, indent string) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", indent)
+	if err := encoder.Encode(rules); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// close stops the periodic report after a last one. It is idempotent.
+func (l *learner) close() {
+	l.stopOnce.Do(func() { close(l.stop) })
+	<-l.done
+}
@@ -0,0 +1,203 @@
+package traefik_plugin_block_useragents
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLearnModeForwardsWithoutHeaders(t *testing.T) {
+	config := CreateConfig()
+	config.LearnMode = true
+	config.Annotate = true
+	config.BypassHeaderName = "X-Secret"
+	config.BypassHeaderValues = []string{"s3cret"}
+
+	var forwarded http.Header
+	next := http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		forwarded = req.Header.Clone()
+		res.WriteHeader(http.StatusOK)
+	})
+	h := newTestHandler(t, config, next)
+
+	tests := []struct {
+		name   string
+		header string
+		value  string
+	}{
+		{"valid bypass token", "X-Secret", "s3cret"},
+		{"invalid bypass token", "X-Secret", "wrong"},
+		{"browser annotation", annotationBrowserHeader, "Chrome"},
+		{"OS annotation", annotationOSHeader, "Windows"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forwarded = nil
+			rec := serve(h, curlUA, withHeader(tt.header, tt.value))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+			}
+			if forwarded == nil {
+				t.Fatal("request not forwarded")
+			}
+			if got := forwarded.Get(tt.header); got != "" {
+				t.Errorf("backend received %s: %q", tt.header, got)
+			}
+		})
+	}
+}
+
+func TestLearnModeMaintenance(t *testing.T) {
+	config := CreateConfig()
+	config.LearnMode = true
+	config.MaintenanceMode = true
+	h := newTestHandler(t, config, nil)
+
+	if rec := serve(h, chromeUA); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestLearnerSuggestion(t *testing.T) {
+	tests := []struct {
+		name       string
+		userAgents []string
+		browsers   []BrowserConfig
+		osTypes    []string
+		skipped    int
+	}{
+		{
+			name:       "lowest major version per family",
+			userAgents: []string{chromeUA, "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/125.0.0.0 Safari/537.36"},
+			browsers:   []BrowserConfig{{Name: "Chrome", Names: []string{"Chrome"}, Version: ">= 125"}},
+			osTypes:    []string{osDetectors["Windows"]},
+		},
+		{
+			name:       "families sorted",
+			userAgents: []string{safariUA, firefoxUA},
+			browsers: []BrowserConfig{
+				{Name: "Firefox", Names: []string{"Firefox"}, Version: ">= 128"},
+				{Name: "Safari", Names: []string{"Safari"}, Version: ">= 17"},
+			},
+			osTypes: []string{osDetectors["Linux"], osDetectors["macOS"]},
+		},
+		{
+			name:       "tools and bots not suggested",
+			userAgents: []string{curlUA, "Googlebot/2.1", "unknown"},
+			browsers:   []BrowserConfig{},
+			osTypes:    []string{},
+			skipped:    3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &learner{browsers: make(map[string]int), oses: make(map[string]struct{})}
+			for _, userAgent := range tt.userAgents {
+				l.observe(userAgent)
+			}
+			rules, observed, skipped := l.suggestion()
+			if observed != len(tt.userAgents) || skipped != tt.skipped {
+				t.Errorf("observed, skipped = %d, %d, want %d, %d", observed, skipped, len(tt.userAgents), tt.skipped)
+			}
+			if !reflect.DeepEqual(rules.AllowedBrowsers, tt.browsers) {
+				t.Errorf("allowedBrowsers = %+v, want %+v", rules.AllowedBrowsers, tt.browsers)
+			}
+			if !reflect.DeepEqual(rules.AllowedOSTypes, tt.osTypes) {
+				t.Errorf("allowedOSTypes = %q, want %q", rules.AllowedOSTypes, tt.osTypes)
+			}
+		})
+	}
+}
+
+func TestLearnFileIsARulesFile(t *testing.T) {
+	for _, name := range []string{"learned.yaml", "learned.json"} {
+		t.Run(name, func(t *testing.T) {
+			config := CreateConfig()
+			config.LearnMode = true
+			config.LearnFile = filepath.Join(t.TempDir(), name)
+			h := newTestHandler(t, config, nil)
+			serve(h, chromeUA)
+			serve(h, curlUA)
+			_ = h.Close() // Writes the last report
+
+			if _, err := os.Stat(config.LearnFile); err != nil {
+				t.Fatalf("learn file not written: %v", err)
+			}
+			rulesConfig := CreateConfig()
+			rulesConfig.RulesFile = config.LearnFile
+			learned := newTestHandler(t, rulesConfig, nil)
+			tests := []struct {
+				userAgent string
+				want      int
+			}{
+				{chromeUA, http.StatusOK},
+				{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36", http.StatusForbidden},
+				{curlUA, http.StatusForbidden},
+			}
+			for _, tt := range tests {
+				if rec := serve(learned, tt.userAgent); rec.Code != tt.want {
+					t.Errorf("%s: status = %d, want %d", tt.userAgent, rec.Code, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestLearnerStopsWithContext(t *testing.T) {
+	config := CreateConfig()
+	config.LearnMode = true
+	config.LearnFile = filepath.Join(t.TempDir(), "learned.json")
+	ctx, cancel := context.WithCancel(context.Background())
+	l := newLearner(ctx, config, "test")
+	l.observe(chromeUA)
+
+	cancel()
+	select {
+	case <-l.done:
+	case <-time.After(time.Second):
+		t.Fatal("learner still running after the context was cancelled")
+	}
+	if _, err := os.Stat(config.LearnFile); err != nil {
+		t.Errorf("last report not written: %v", err)
+	}
+	l.close() // Still safe once stopped
+}
+
+func TestLearnInterval(t *testing.T) {
+	tests := []struct {
+		name      string
+		learnMode bool
+		interval  string
+		want      time.Duration
+		wantErr   error
+	}{
+		{"default", true, "", defaultLearnInterval, nil},
+		{"set", true, "90s", 90 * time.Second, nil},
+		{"below one second", true, "500ms", 0, ErrInvalidValue},
+		{"invalid", true, "hourly", 0, ErrInvalidValue},
+		{"without learn mode", false, "1m", 0, ErrMissingSetting},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := CreateConfig()
+			config.LearnMode = tt.learnMode
+			config.LearnInterval = tt.interval
+			if err := ValidateConfig(config); tt.wantErr != nil || err != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("ValidateConfig = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			l := newLearner(context.Background(), config, "test")
+			defer l.close()
+			if l.interval != tt.want {
+				t.Errorf("interval = %s, want %s", l.interval, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package traefik_plugin_block_useragents
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// defaultLogDedupeWindow is the window of LogMaxPerReason when
+// LogDedupeWindow is not set.
+const defaultLogDedupeWindow = time.Minute
+
+// logDeduper caps the block events logged per reason: within a window
+// starting at the first event of a reason, only the first max events of that
+// reason are logged and the others are counted.
+type logDeduper struct {
+	window time.Duration
+	max    int
+
+	mu        sync.Mutex
+	reasons   map[string]*reasonWindow
+	nextSweep time.Time
+}
+
+// reasonWindow tracks the events of one reason in its current window.
+type reasonWindow struct {
+	start      time.Time
+	logged     int
+	suppressed int
+}
+
+// suppressedReason is the count of events of a reason suppressed during a
+// window that is over.
+type suppressedReason struct {
+	reason string
+	count  int
+}
+
+// validateLogDedupe checks the log deduplication settings.
+func validateLogDedupe(config *Config) error {
+	var errs ConfigErrors
+	if config.LogMaxPerReason < 0 {
+		errs.add(configErrorf(ErrInvalidValue, "logMaxPerReason must not be negative"))
+	}
+	if config.LogDedupeWindow == "" {
+		return errs.err()
+	}
+	if config.LogMaxPerReason == 0 {
+		errs.add(configErrorf(ErrMissingSetting, "logDedupeWindow requires logMaxPerReason"))
+	}
+	window, err := time.ParseDuration(config.LogDedupeWindow)
+	switch {
+	case err != nil:
+		errs.add(configErrorf(ErrInvalidValue, "invalid logDedupeWindow %q: %w", config.LogDedupeWindow, err))
+	case window <= 0:
+		errs.add(configErrorf(ErrInvalidValue, "logDedupeWindow must be positive"))
+	}
+	return errs.err()
+}
+
+// newLogDeduper returns the deduper of a validated config, or nil when
+// LogMaxPerReason is not set.
+func newLogDeduper(config *Config) *logDeduper {
+	if config.LogMaxPerReason == 0 {
+		return nil
+	}
+	window := defaultLogDedupeWindow
+	if config.LogDedupeWindow != "" {
+		window, _ = time.ParseDuration(config.LogDedupeWindow)
+	}
+	return &logDeduper{window: window, max: config.LogMaxPerReason, reasons: make(map[string]*reasonWindow)}
+}
+
+// allow reports whether an event with the given reason should be logged. It
+// also returns the suppressed counts of the windows over by now, sorted by
+// reason, so the caller can log a summary; windows are swept at most once
+// per window length.
+func (d *logDeduper) allow(reason string, now time.Time) (bool, []suppressedReason) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var summaries []suppressedReason
+	if !now.Before(d.nextSweep) {
+		for r, w := range d.reasons {
+			if now.Sub(w.start) >= d.window {
+				if w.suppressed > 0 {
+					summaries = append(summaries, suppressedReason{reason: r, count: w.suppressed})
+				}
+				delete(d.reasons, r)
+			}
+		}
+		sort.Slice(summaries, func(i, j int) bool { return summaries[i].reason < summaries[j].reason })
+		d.nextSweep = now.Add(d.window)
+	}
+
+	w, ok := d.reasons[reason]
+	if !ok || now.Sub(w.start) >= d.window {
+		if ok && w.suppressed > 0 {
+			summaries = append(summaries, suppressedReason{reason: reason, count: w.suppressed})
+		}
+		w = &reasonWindow{start: now}
+		d.reasons[reason] = w
+	}
+	if w.logged < d.max {
+		w.logged++
+		return true, summaries
+	}
+	w.suppressed++
+	return false, summaries
+}
@@ -0,0 +1,63 @@
+package traefik_plugin_block_useragents
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogDeduper(t *testing.T) {
+	clock := newFakeClock()
+	d := &logDeduper{window: time.Minute, max: 2, reasons: make(map[string]*reasonWindow)}
+
+	type event struct {
+		advance   time.Duration
+		reason    string
+		logged    bool
+		summaries []suppressedReason
+	}
+	events := []event{
+		{0, "A", true, nil},
+		{time.Second, "A", true, nil},
+		{time.Second, "A", false, nil},
+		{time.Second, "B", true, nil},
+		{time.Second, "A", false, nil},
+		{time.Minute, "B", true, []suppressedReason{{reason: "A", count: 2}}},
+		{time.Second, "A", true, nil},
+		{time.Second, "B", true, nil},
+		{time.Second, "B", false, nil},
+	}
+	for i, e := range events {
+		clock.advance(e.advance)
+		logged, summaries := d.allow(e.reason, clock.Now())
+		if logged != e.logged || !reflect.DeepEqual(summaries, e.summaries) {
+			t.Errorf("event %d (%s): allow() = %v, %+v, want %v, %+v", i, e.reason, logged, summaries, e.logged, e.summaries)
+		}
+	}
+}
+
+func TestLogMaxPerReason(t *testing.T) {
+	logs := captureLog(t)
+	config := testConfig()
+	config.LogMaxPerReason = 2
+	config.LogDedupeWindow = "1m"
+	clock := newFakeClock()
+	h := newTestHandler(t, config, nil)
+	h.clock = clock
+
+	for i := 0; i < 5; i++ {
+		serve(h, curlUA)
+	}
+	if got := strings.Count(logs.String(), "Blocked (Unsupported Browser)"); got != 2 {
+		t.Errorf("logged %d block events in the window, want 2", got)
+	}
+	clock.advance(time.Minute)
+	serve(h, curlUA)
+	if !strings.Contains(logs.String(), `suppressed 3 blocked request logs with reason "Unsupported Browser" in the last 1m0s`) {
+		t.Errorf("no suppression summary logged:\n%s", logs)
+	}
+	if got := strings.Count(logs.String(), "Blocked (Unsupported Browser)"); got != 3 {
+		t.Errorf("logged %d block events, want 3 after the window", got)
+	}
+}
@@ -0,0 +1,124 @@
+package traefik_plugin_block_useragents
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Formats accepted by Config.LogFormat.
+const (
+	LogFormatText   = "text"
+	LogFormatJSON   = "json"
+	LogFormatLogfmt = "logfmt"
+)
+
+// validateLogFormat checks the configured log format.
+func validateLogFormat(format string) error {
+	switch format {
+	case "", LogFormatText, LogFormatJSON, LogFormatLogfmt:
+		return nil
+	default:
+		return configErrorf(ErrInvalidValue, "invalid logFormat %q", format)
+	}
+}
+
+// logEvent logs a request event, such as "Blocked" or "Soft-Miss", in the
+// configured format. The text format keeps the historical layout; the
+// structured formats write one record per line without the log prefix.
+func (b *BlockUserAgents) logEvent(req *http.Request, event, reason string, elapsed time.Duration) {
+	message := b.eventMessage(req, elapsed)
+	switch b.logFormat {
+	case LogFormatJSON:
+		message.Event, message.Name, message.Reason = event, b.name, reason
+		jsonMessage, err := json.Marshal(message)
+		if err != nil {
+			log.Printf("%s: error encoding log record: %v", b.name, err)
+			return
+		}
+		fmt.Fprintln(log.Writer(), string(jsonMessage))
+	case LogFormatLogfmt:
+		message.Event, message.Name, message.Reason = event, b.name, reason
+		fmt.Fprintln(log.Writer(), message.logfmt())
+	default:
+		label := event
+		if reason != "" {
+			label += " (" + reason + ")"
+		}
+		jsonMessage, err := json.Marshal(message)
+		if err == nil {
+			log.Printf("%s: %s - %s", b.name, label, jsonMessage)
+		} else {
+			log.Printf("%s: %s - %s", b.name, label, req.UserAgent())
+		}
+	}
+}
+
+// eventMessage returns the log record of a request event, without the
+// fields only set by the structured formats.
+func (b *BlockUserAgents) eventMessage(req *http.Request, elapsed time.Duration) *BlockUserAgentsMessage {
+	message := newMessage(req)
+	if header, _ := b.matchedHeader(req); header != "" && header != "User-Agent" {
+		message.MatchHeader, message.UserAgent = header, req.Header.Get(header)
+		message.ParsedBrowser, message.ParsedOS = parseUserAgent(message.UserAgent)
+	}
+	message.RequestID = req.Header.Get(b.correlationHeader)
+	if span := spanFrom(req); span != nil {
+		message.TraceID, message.SpanID = span.TraceID, span.SpanID
+	}
+	if b.logTiming {
+		evalMicros := elapsed.Microseconds()
+		message.Timestamp = b.clock.Now().UTC().Format(time.RFC3339)
+		message.EvalMicros = &evalMicros
+	}
+	return message
+}
+
+// logfmt renders the message as logfmt key=value pairs.
+func (m *BlockUserAgentsMessage) logfmt() string {
+	fields := []struct{ key, value string }{
+		{"event", m.Event},
+		{"name", m.Name},
+		{"reason", m.Reason},
+		{"user-agent", m.UserAgent},
+		{"ip", m.RemoteAddr},
+		{"host", m.Host},
+		{"uri", m.RequestURI},
+		{"browser", m.ParsedBrowser},
+		{"os", m.ParsedOS},
+		{"matchHeader", m.MatchHeader},
+		{"requestId", m.RequestID},
+		{"traceId", m.TraceID},
+		{"spanId", m.SpanID},
+		{"timestamp", m.Timestamp},
+	}
+	if m.EvalMicros != nil {
+		fields = append(fields, struct{ key, value string }{"evalMicros", strconv.FormatInt(*m.EvalMicros, 10)})
+	}
+	var sb strings.Builder
+	for _, field := range fields {
+		if field.value == "" && field.key != "user-agent" {
+			continue
+		}
+		if sb.Len() > 0 {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(field.key)
+		sb.WriteByte('=')
+		sb.WriteString(logfmtValue(field.value))
+	}
+	return sb.String()
+}
+
+// logfmtValue quotes a value when it is empty or contains spaces, quotes,
+// equal signs or control characters.
+func logfmtValue(value string) string {
+	if value == "" || strings.ContainsAny(value, " \"=\\") || strconv.Quote(value) != `"`+value+`"` {
+		return strconv.Quote(value)
+	}
+	return value
+}
@@ -0,0 +1,95 @@
+package traefik_plugin_block_useragents
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLogFormat(t *testing.T) {
+	tests := []struct {
+		format string
+		want   []string
+	}{
+		{"", []string{`test: Blocked (Unsupported Browser) - {`, `"user-agent":"curl/8.0"`}},
+		{LogFormatText, []string{`test: Blocked (Unsupported Browser) - {`}},
+		{LogFormatJSON, []string{`{"user-agent":"curl/8.0",`, `"event":"Blocked","name":"test","reason":"Unsupported Browser"}`}},
+		{LogFormatLogfmt, []string{`event=Blocked name=test reason="Unsupported Browser" user-agent=curl/8.0 `}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			config := testConfig()
+			config.LogFormat = tt.format
+			h := newTestHandler(t, config, nil)
+			logs := captureLog(t)
+
+			serve(h, curlUA)
+			for _, want := range tt.want {
+				if !strings.Contains(logs.String(), want) {
+					t.Errorf("log = %q, want %q", logs, want)
+				}
+			}
+		})
+	}
+
+	config := testConfig()
+	config.LogFormat = "xml"
+	if err := ValidateConfig(config); !errors.Is(err, ErrInvalidValue) {
+		t.Errorf("ValidateConfig with logFormat xml = %v, want %v", err, ErrInvalidValue)
+	}
+}
+
+// OVERRIDE key=TestLogfmtValue file=log_format_test.go from=4d5121c until=end
+func TestLogfmtValue(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"curl/8.0", "curl/8.0"},
+		{"", `""`},
+		{"Mozilla/5.0 (X11)", `"Mozilla/5.0 (X11)"`},
+		{`a="b"`, `"a=\"b\""`},
+		{"tab\there", `"tab\there"`},
+	}
+	for _, tt := range tests {
+		if got := logfmtValue(tt.value); got != tt.want {
+			t.Errorf("logfmtValue(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestLogTiming(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		enabled bool
+		want    string // Empty when no timing is logged
+	}{
+		{"text", LogFormatText, true, `"timestamp":"2026-01-01T00:00:00Z","evalMicros":0`},
+		{"json", LogFormatJSON, true, `"timestamp":"2026-01-01T00:00:00Z","evalMicros":0`},
+		{"logfmt", LogFormatLogfmt, true, `timestamp=2026-01-01T00:00:00Z evalMicros=0`},
+		{"disabled", LogFormatJSON, false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.LogFormat = tt.format
+			config.LogTiming = tt.enabled
+			h := newTestHandler(t, config, nil)
+			h.clock = newFakeClock() // Stands still, so the evaluation takes no time
+			logs := captureLog(t)
+
+			serve(h, curlUA)
+			got := logs.String()
+			if tt.want == "" {
+				if strings.Contains(got, "timestamp") || strings.Contains(got, "evalMicros") {
+					t.Errorf("log = %q, want no timing", got)
+				}
+				return
+			}
+			if !strings.Contains(got, tt.want) {
+				t.Errorf("log = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package traefik_plugin_block_useragents
+
+import (
+	"math/rand"
+	"sync"
+)
+
+// logSampler decides which block events are logged. The first event of each
+// distinct reason is always logged; later ones are kept with probability rate.
+type logSampler struct {
+	rate float64
+
+	mu   sync.Mutex
+	seen map[string]struct{}
+}
+
+// newLogSampler returns a sampler keeping the given fraction of events.
+func newLogSampler(rate float64) *logSampler {
+	return &logSampler{rate: rate, seen: make(map[string]struct{})}
+}
+
+// sample reports whether an event with the given reason should be logged.
+func (s *logSampler) sample(reason string) bool {
+	if s.rate >= 1 {
+		return true
+	}
+
+	s.mu.Lock()
+	_, seen := s.seen[reason]
+	if !seen {
+		s.seen[reason] = struct{}{}
+	}
+	s.mu.Unlock()
+
+	return !seen || rand.Float64() < s.rate //nolint:gosec // Sampling does not need a secure source
+}
@@ -0,0 +1,31 @@
+package traefik_plugin_block_useragents
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestLogSampler(t *testing.T) {
+	none := newLogSampler(0)
+	if !none.sample("Unsupported Browser") || !none.sample("Unsupported OS") {
+		t.Error("first event of a reason not logged")
+	}
+	for i := 0; i < 10; i++ {
+		if none.sample("Unsupported Browser") {
+			t.Fatal("repeated event logged at rate 0")
+		}
+	}
+
+	all := newLogSampler(1)
+	for i := 0; i < 10; i++ {
+		if !all.sample("Unsupported Browser") {
+			t.Fatal("event dropped at rate 1")
+		}
+	}
+
+	config := testConfig()
+	config.LogSampleRate = 1.5
+	if err := ValidateConfig(config); !errors.Is(err, ErrInvalidValue) {
+		t.Errorf("ValidateConfig with logSampleRate 1.5 = %v, want %v", err, ErrInvalidValue)
+	}
+}
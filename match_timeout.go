@@ -0,0 +1,54 @@
+package traefik_plugin_block_useragents
+
+import (
+	"net/http"
+	"time"
+)
+
+// validateMatchTimeout checks the match timeout setting.
+func validateMatchTimeout(config *Config) error {
+	if config.MatchTimeout == "" {
+		return nil
+	}
+	timeout, err := time.ParseDuration(config.MatchTimeout)
+	if err != nil {
+		return configErrorf(ErrInvalidValue, "invalid matchTimeout %q: %w", config.MatchTimeout, err)
+	}
+	if timeout < 0 {
+		return configErrorf(ErrInvalidValue, "matchTimeout must not be negative")
+	}
+	return nil
+}
+
+// timedEvaluate evaluates the request, giving up after the match timeout.
+// Regex matching cannot be interrupted, so an abandoned evaluation finishes
+// in the background; its result is discarded. It reports false on timeout.
+// The evaluation reads a snapshot of the request, whose User-Agent values and
+// other headers ServeHTTP goes on to modify.
+func (b *BlockUserAgents) timedEvaluate(req *http.Request) (decision, bool) {
+	if b.matchTimeout == 0 {
+		return b.evaluate(req), true
+	}
+	snapshot := req.Clone(req.Context())
+	done := make(chan decision, 1)
+	go func() {
+		d := block("Eval Error")
+		b.guard.run("evaluation", func() { d = b.evaluate(snapshot) })
+		done <- d
+	}()
+	timer := time.NewTimer(b.matchTimeout)
+	defer timer.Stop()
+	select {
+	case d := <-done:
+		return d, true
+	case <-timer.C:
+		b.evalTimeouts.Add(1)
+		b.guard.run("expvar", func() { b.expvar.evalTimeout() })
+		return block("Eval Timeout"), false
+	}
+}
+
+// EvalTimeouts returns the number of evaluations abandoned after the match timeout.
+func (b *BlockUserAgents) EvalTimeouts() uint64 {
+	return b.evalTimeouts.Load()
+}
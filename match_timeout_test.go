@@ -0,0 +1,48 @@
+package traefik_plugin_block_useragents
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestMatchTimeout(t *testing.T) {
+	config := testConfig()
+	// Unanchored alternations force a scan of the whole User-Agent per rule,
+	// far slower than the timeout
+	config.AllowedBrowsers = []BrowserConfig{
+		{Name: "Slow", Regex: `(a|b|c|d)+e[0-9]{3}z`},
+		{Name: "Slower", Regex: `(x|y|a*)+b[0-9]{3}q`},
+	}
+	config.MatchTimeout = "1ns"
+	h := newTestHandler(t, config, nil)
+
+	userAgent := strings.Repeat("abcd", 16<<10)
+	for i := 1; i <= 3; i++ {
+		if rec := serve(h, userAgent); rec.Code != http.StatusForbidden {
+			t.Errorf("status = %d, want %d", rec.Code, http.StatusForbidden)
+		}
+		if got := h.EvalTimeouts(); got != uint64(i) {
+			t.Errorf("EvalTimeouts() after %d requests = %d, want %d", i, got, i)
+		}
+	}
+	if got := h.Stats().EvalTimeouts; got != 3 {
+		t.Errorf("Stats().EvalTimeouts = %d, want 3", got)
+	}
+}
+
+func TestMatchTimeoutCompleted(t *testing.T) {
+	config := testConfig()
+	config.MatchTimeout = "1m"
+	h := newTestHandler(t, config, nil)
+
+	if rec := serve(h, chromeUA); rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if rec := serve(h, curlUA); rec.Code != http.StatusForbidden {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+	if got := h.EvalTimeouts(); got != 0 {
+		t.Errorf("EvalTimeouts() = %d, want 0", got)
+	}
+}
@@ -0,0 +1,74 @@
+package traefik_plugin_block_useragents
+
+import (
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// validateMetricsEndpoint checks the metrics endpoint settings.
+func validateMetricsEndpoint(config *Config) error {
+	if config.MetricsPath == "" {
+		if len(config.MetricsAllowedIPs) > 0 {
+			return configErrorf(ErrMissingSetting, "metricsAllowedIps requires metricsPath")
+		}
+		return nil
+	}
+	var errs ConfigErrors
+	if !strings.HasPrefix(config.MetricsPath, "/") {
+		errs.add(configErrorf(ErrInvalidValue, "metricsPath must be an absolute path, got %q", config.MetricsPath))
+	}
+	if config.EnableValidateEndpoint && config.MetricsPath == config.ValidatePath {
+		errs.add(configErrorf(ErrConflictingSettings, "metricsPath and validatePath must differ"))
+	}
+	if _, err := parseIPNets(config.MetricsAllowedIPs); err != nil {
+		errs.add(configErrorf(ErrInvalidValue, "invalid metricsAllowedIps: %w", err))
+	}
+	return errs.err()
+}
+
+// newMetricsAllowedIPs parses the IPs allowed to call the metrics endpoint,
+// defaulting to loopback.
+func newMetricsAllowedIPs(config *Config) []*net.IPNet {
+	allowed := config.MetricsAllowedIPs
+	if len(allowed) == 0 {
+		allowed = defaultValidateAllowedIPs
+	}
+	nets, _ := parseIPNets(allowed)
+	return nets
+}
+
+// isMetricsRequest reports whether the request targets the metrics endpoint.
+func (b *BlockUserAgents) isMetricsRequest(req *http.Request) bool {
+	return b.metricsPath != "" && req.URL != nil && req.URL.Path == b.metricsPath
+}
+
+// serveMetrics writes the Stats of the middleware as JSON. Like the
+// validation endpoint, access is checked against the connection address.
+func (b *BlockUserAgents) serveMetrics(res http.ResponseWriter, req *http.Request) {
+	if !containsIP(b.metricsAllowedIPs, remoteIP(req.RemoteAddr)) {
+		res.WriteHeader(http.StatusForbidden)
+		return
+	}
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		res.Header().Set("Allow", "GET, HEAD")
+		res.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	encoded, err := json.Marshal(b.Stats())
+	if err != nil {
+		res.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	res.Header().Set("Content-Type", "application/json")
+	res.Header().Set("Cache-Control", "no-store")
+	res.WriteHeader(http.StatusOK)
+	if req.Method == http.MethodHead {
+		return
+	}
+	if _, err := res.Write(append(encoded, '\n')); err != nil {
+		log.Printf("%s: error writing metrics: %v", b.name, err)
+	}
+}
@@ -0,0 +1,80 @@
+package traefik_plugin_block_useragents
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMetricsEndpoint(t *testing.T) {
+	config := testConfig()
+	config.CacheSize = 8
+	config.MetricsPath = "/_metrics"
+	config.TrustForwardedHeader = true
+	h := newTestHandler(t, config, nil)
+	serve(h, chromeUA)
+	serve(h, chromeUA)
+	serve(h, curlUA)
+
+	tests := []struct {
+		name       string
+		method     string
+		remoteAddr string
+		forwarded  string
+		want       int
+	}{
+		{"loopback", http.MethodGet, "127.0.0.1:4321", "", http.StatusOK},
+		{"IPv6 loopback", http.MethodHead, "[::1]:4321", "", http.StatusOK},
+		{"remote", http.MethodGet, "192.0.2.1:4321", "", http.StatusForbidden},
+		{"spoofed X-Forwarded-For", http.MethodGet, "192.0.2.1:4321", "127.0.0.1", http.StatusForbidden},
+		{"POST", http.MethodPost, "127.0.0.1:4321", "", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "http://example.com/_metrics", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.forwarded != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if rec.Code != http.StatusOK || tt.method == http.MethodHead {
+				return
+			}
+			var stats Stats
+			if err := json.Unmarshal(rec.Body.Bytes(), &stats); err != nil {
+				t.Fatalf("invalid metrics %q: %v", rec.Body.String(), err)
+			}
+			if stats.Allowed != 2 || stats.CacheHits != 1 || stats.CacheMisses != 2 || stats.Blocked["Unsupported Browser"] != 1 {
+				t.Errorf("metrics = %+v, want 2 allowed, 1 blocked, 1 hit and 2 misses", stats)
+			}
+		})
+	}
+}
+
+func TestMetricsEndpointValidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		config func(*Config)
+	}{
+		{"relative path", func(c *Config) { c.MetricsPath = "metrics" }},
+		{"allowed IPs without path", func(c *Config) { c.MetricsAllowedIPs = []string{"10.0.0.0/8"} }},
+		{"invalid allowed IP", func(c *Config) { c.MetricsPath, c.MetricsAllowedIPs = "/m", []string{"nope"} }},
+		{"same path as validation", func(c *Config) {
+			c.MetricsPath, c.EnableValidateEndpoint, c.ValidatePath = "/v", true, "/v"
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			tt.config(config)
+			if err := ValidateConfig(config); err == nil {
+				t.Error("ValidateConfig() succeeded")
+			}
+		})
+	}
+}
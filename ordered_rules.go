@@ -0,0 +1,100 @@
+package traefik_plugin_block_useragents
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+)
+
+// Rule defines an entry of the ordered, first-match-wins rule list.
+type Rule struct {
+	Pattern string `json:"pattern,omitempty"` // Required: Regex pattern matched against the target
+	Action  string `json:"action,omitempty"`  // Required: "allow" or "deny"
+	Target  string `json:"target,omitempty"`  // Optional: "ua" (default), "os" or "path"
+}
+
+// Ordered rule actions.
+const (
+	RuleAllow = "allow"
+	RuleDeny  = "deny"
+)
+
+// Ordered rule targets.
+const (
+	TargetUserAgent = "ua"
+	TargetOS        = "os"
+	TargetPath      = "path"
+)
+
+// orderedRule is a compiled Rule.
+type orderedRule struct {
+	pattern string
+	re      *regexp.Regexp
+	action  string
+	target  string
+}
+
+// validateRule checks a single ordered rule.
+func validateRule(rule Rule) error {
+	var errs ConfigErrors
+	if rule.Pattern == "" {
+		errs.add(configErrorf(ErrMissingRegex, "pattern must be provided for every rule"))
+	}
+	switch rule.Action {
+	case RuleAllow, RuleDeny:
+	default:
+		errs.add(configErrorf(ErrInvalidValue, "invalid action %q for rule %q", rule.Action, rule.Pattern))
+	}
+	switch rule.Target {
+	case "", TargetUserAgent, TargetOS, TargetPath:
+	default:
+		errs.add(configErrorf(ErrInvalidValue, "invalid target %q for rule %q", rule.Target, rule.Pattern))
+	}
+	return errs.err()
+}
+
+// compileRules compiles validated ordered rules, keeping their order.
+func compileRules(rules []Rule) ([]orderedRule, error) {
+	compiled := make([]orderedRule, 0, len(rules))
+	for _, rule := range rules {
+		re, err := compileRegexp(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("error compiling rule regex %q: %w", rule.Pattern, err)
+		}
+		target := rule.Target
+		if target == "" {
+			target = TargetUserAgent
+		}
+		compiled = append(compiled, orderedRule{pattern: rule.Pattern, re: re, action: rule.Action, target: target})
+	}
+	return compiled, nil
+}
+
+// evaluateOrderedRules walks the ordered rules and returns the decision of the
+// first matching rule, falling back to the default action. The returned bool
+// is false when neither applies and the allowlist checks should decide.
+func (b *BlockUserAgents) evaluateOrderedRules(req *http.Request, userAgents []string) (decision, bool) {
+	for _, rule := range b.orderedRules {
+		var matched bool
+		if rule.target == TargetPath {
+			matched = req.URL != nil && rule.re.MatchString(req.URL.Path)
+		} else {
+			matched = matchesAny(rule.re, userAgents)
+		}
+		if !matched {
+			continue
+		}
+		if rule.action == RuleAllow {
+			return allow(), true
+		}
+		return block("Denied Rule: " + rule.pattern), true
+	}
+
+	switch b.defaultAction {
+	case RuleAllow:
+		return allow(), true
+	case RuleDeny:
+		return block("Default Deny"), true
+	}
+	return decision{}, false
+}
@@ -0,0 +1,49 @@
+package traefik_plugin_block_useragents
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOrderedRules(t *testing.T) {
+	rules := []Rule{
+		{Pattern: `^/health$`, Action: RuleAllow, Target: TargetPath},
+		{Pattern: `Chrome/131\.`, Action: RuleDeny},
+		{Pattern: `Chrome/`, Action: RuleAllow},
+		{Pattern: `Firefox/`, Action: RuleDeny},
+		{Pattern: `Firefox/128\.`, Action: RuleAllow}, // Never reached
+	}
+	tests := []struct {
+		name          string
+		defaultAction string
+		path          string
+		userAgent     string
+		wantReason    string // Empty when allowed
+	}{
+		{"deny before a broader allow", "", "/", chromeUA, `Denied Rule: Chrome/131\.`},
+		{"allow after a narrower deny", "", "/", "Mozilla/5.0 Chrome/130.0.0.0", ""},
+		{"deny before a narrower allow", "", "/", firefoxUA, `Denied Rule: Firefox/`},
+		{"path target allows", "", "/health", curlUA, ""},
+		{"path target does not match the User-Agent", "", "/", "Mozilla/5.0 /health", "Unsupported Browser"},
+		{"path target ahead of a deny", "", "/health", firefoxUA, ""},
+		{"no match falls back to the allowlist", "", "/", curlUA, "Unsupported Browser"},
+		{"no match with defaultAction allow", RuleAllow, "/", curlUA, ""},
+		{"no match with defaultAction deny", RuleDeny, "/", safariUA, "Default Deny"},
+		{"match ahead of defaultAction deny", RuleDeny, "/", "Mozilla/5.0 Chrome/130.0.0.0", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.Rules = rules
+			config.DefaultAction = tt.defaultAction
+			h := newTestHandler(t, config, nil)
+
+			req := httptest.NewRequest("GET", "http://example.com"+tt.path, nil)
+			req.Header.Set("User-Agent", tt.userAgent)
+			d := h.evaluate(req)
+			if d.allowed != (tt.wantReason == "") || d.reason != tt.wantReason {
+				t.Errorf("evaluate = (allowed %v, reason %q), want reason %q", d.allowed, d.reason, tt.wantReason)
+			}
+		})
+	}
+}
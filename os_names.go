@@ -0,0 +1,35 @@
+package traefik_plugin_block_useragents
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// osDetectors maps the OS names accepted by Config.AllowedOSNames to their
+// canonical detection patterns. iOS and Android UAs also mention macOS and
+// Linux, so the macOS and Linux patterns only match the desktop platforms.
+var osDetectors = map[string]string{
+	"Windows":  `Windows (?:NT|Phone)`,
+	"macOS":    `Macintosh;`,
+	"iOS":      `\((?:iPhone|iPad|iPod)[;)]`,
+	"Android":  `Android`,
+	"Linux":    `X11;[^)]*Linux`,
+	"ChromeOS": `CrOS`,
+}
+
+// osDetectorPattern returns the detection pattern of a named OS. Names are
+// matched case-insensitively.
+func osDetectorPattern(name string) (string, error) {
+	for known, pattern := range osDetectors {
+		if strings.EqualFold(known, strings.TrimSpace(name)) {
+			return pattern, nil
+		}
+	}
+	names := make([]string, 0, len(osDetectors))
+	for known := range osDetectors {
+		names = append(names, known)
+	}
+	sort.Strings(names)
+	return "", fmt.Errorf("unknown OS name %q, expected one of %s", name, strings.Join(names, ", "))
+}
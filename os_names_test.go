@@ -0,0 +1,83 @@
+package traefik_plugin_block_useragents
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"testing"
+)
+
+func TestOSDetectors(t *testing.T) {
+	tests := []struct {
+		name      string
+		userAgent string
+		want      []string
+	}{
+		{"Windows", chromeUA, []string{"Windows"}},
+		{"Windows Phone", "Mozilla/5.0 (Windows Phone 10.0; Android 6.0.1; Microsoft; Lumia 950) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/52.0.2743.116 Mobile Safari/537.36 Edge/15.15063", []string{"Android", "Windows"}},
+		{"macOS", safariUA, []string{"macOS"}},
+		{"iPhone", "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Mobile/15E148 Safari/604.1", []string{"iOS"}},
+		{"iPad", "Mozilla/5.0 (iPad; CPU OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Mobile/15E148 Safari/604.1", []string{"iOS"}},
+		{"Android", "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.0.0 Mobile Safari/537.36", []string{"Android"}},
+		{"Linux", firefoxUA, []string{"Linux"}},
+		{"ChromeOS", "Mozilla/5.0 (X11; CrOS x86_64 14541.0.0) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.0.0 Safari/537.36", []string{"ChromeOS"}},
+		{"no OS", curlUA, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for name, pattern := range osDetectors {
+				if regexp.MustCompile(pattern).MatchString(tt.userAgent) {
+					got = append(got, name)
+				}
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("detected %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOSDetectorPattern(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{"macOS", osDetectors["macOS"], false},
+		{" ios ", osDetectors["iOS"], false},
+		{"WINDOWS", osDetectors["Windows"], false},
+		{"BeOS", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := osDetectorPattern(tt.name)
+			if got != tt.want || (err != nil) != tt.wantErr {
+				t.Errorf("osDetectorPattern() = %q, %v, want %q, error %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAllowedOSNames(t *testing.T) {
+	config := testConfig()
+	config.AllowedOSNames = []string{"Windows", "Android"}
+	h := newTestHandler(t, config, nil)
+
+	tests := []struct {
+		userAgent string
+		want      int
+	}{
+		{chromeUA, http.StatusOK},
+		{"Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.0.0 Mobile Safari/537.36", http.StatusOK},
+		{"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.0.0 Safari/537.36", http.StatusForbidden},
+		{"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.0.0 Safari/537.36", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		if rec := serve(h, tt.userAgent); rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.userAgent, rec.Code, tt.want)
+		}
+	}
+}
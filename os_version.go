@@ -0,0 +1,138 @@
+package traefik_plugin_block_useragents
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// osVersionRulePattern splits an OS version rule such as "win>=10" into the
+// OS name, the operator and the version.
+var osVersionRulePattern = regexp.MustCompile(`^\s*([A-Za-z]+)\s*(>=|<=|==|!=|>|<|=)\s*(\d+(?:\.\d+)*)\s*$`)
+
+// osVersionDetector extracts the version of an OS from a User-Agent.
+type osVersionDetector struct {
+	re       *regexp.Regexp    // First group captures the version
+	versions map[string]string // Optional: Maps the captured version to the marketed one
+}
+
+// osVersionDetectors maps the OS names accepted by Config.OSVersionRules to
+// their version detectors. Windows reports its NT kernel version, mapped to
+// the marketed version; Windows 11 still reports NT 10.0, so it cannot be
+// told apart from Windows 10. Safari and Chrome freeze macOS at 10.15.7.
+var osVersionDetectors = map[string]osVersionDetector{
+	"win": {
+		re: regexp.MustCompile(`Windows NT (\d+\.\d+)`),
+		versions: map[string]string{
+			"5.1":  "5.1", // XP
+			"5.2":  "5.2", // XP x64
+			"6.0":  "6",   // Vista
+			"6.1":  "7",
+			"6.2":  "8",
+			"6.3":  "8.1",
+			"10.0": "10",
+		},
+	},
+	"macos":    {re: regexp.MustCompile(`Mac OS X (\d+(?:[._]\d+)*)`)},
+	"ios":      {re: regexp.MustCompile(`(?:iPhone|iPad|iPod).*? OS (\d+(?:_\d+)*)`)},
+	"android":  {re: regexp.MustCompile(`Android (\d+(?:\.\d+)*)`)},
+	"chromeos": {re: regexp.MustCompile(`CrOS \S+ (\d+(?:\.\d+)*)`)},
+}
+
+// osVersionRule requires the version of an OS to compare to a version with op.
+type osVersionRule struct {
+	source   string
+	detector osVersionDetector
+	op       string
+	version  []int
+}
+
+// parseOSVersionRule parses an OS version rule: an OS name (win, macos, ios,
+// android or chromeos), an operator (>=, >, <=, <, ==, =, !=) and a version.
+func parseOSVersionRule(rule string) (osVersionRule, error) {
+	m := osVersionRulePattern.FindStringSubmatch(rule)
+	if m == nil {
+		return osVersionRule{}, fmt.Errorf("invalid OS version rule %q: expected <os><op><version>, e.g. win>=10", rule)
+	}
+	detector, ok := osVersionDetectors[strings.ToLower(m[1])]
+	if !ok {
+		names := make([]string, 0, len(osVersionDetectors))
+		for name := range osVersionDetectors {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return osVersionRule{}, fmt.Errorf("unknown OS %q in OS version rule %q, expected one of %s", m[1], rule, strings.Join(names, ", "))
+	}
+	version, err := parseVersion(m[3])
+	if err != nil {
+		return osVersionRule{}, fmt.Errorf("invalid OS version rule %q: %w", rule, err)
+	}
+	return osVersionRule{source: strings.TrimSpace(rule), detector: detector, op: m[2], version: version}, nil
+}
+
+// detectVersion returns the OS version reported by a User-Agent, or false
+// when the User-Agent is not of that OS or its version is unknown.
+func (d osVersionDetector) detectVersion(userAgent string) ([]int, bool) {
+	m := d.re.FindStringSubmatch(userAgent)
+	if m == nil {
+		return nil, false
+	}
+	raw := m[1]
+	if d.versions != nil {
+		marketed, ok := d.versions[raw]
+		if !ok {
+			return nil, false
+		}
+		raw = marketed
+	}
+	version, err := parseVersion(raw)
+	if err != nil {
+		return nil, false
+	}
+	return version, true
+}
+
+// allows reports whether the rule lets a User-Agent through. User-Agents of
+// other OSes, or whose version cannot be detected, are not restricted.
+func (r osVersionRule) allows(userAgent string) bool {
+	version, ok := r.detector.detectVersion(userAgent)
+	if !ok {
+		return true
+	}
+	return satisfiesVersion(compareVersions(version, r.version), r.op)
+}
+
+// validateOSVersionRules checks the OS version rules.
+func validateOSVersionRules(config *Config) error {
+	var errs ConfigErrors
+	for _, rule := range config.OSVersionRules {
+		if _, err := parseOSVersionRule(rule); err != nil {
+			errs.add(configErrorf(ErrInvalidValue, "%w", err))
+		}
+	}
+	return errs.err()
+}
+
+// compileOSVersionRules parses validated OS version rules.
+func compileOSVersionRules(rules []string) []osVersionRule {
+	compiled := make([]osVersionRule, 0, len(rules))
+	for _, rule := range rules {
+		r, _ := parseOSVersionRule(rule)
+		compiled = append(compiled, r)
+	}
+	return compiled
+}
+
+// checkOSVersion blocks User-Agents whose OS version fails one of the OS
+// version rules. With several matched values, each of them must pass.
+func (b *BlockUserAgents) checkOSVersion(e *evaluation) *decision {
+	for _, rule := range b.osVersionRules {
+		for _, userAgent := range e.userAgents {
+			if !rule.allows(userAgent) {
+				return blockDecision("Unsupported OS Version: " + rule.source)
+			}
+		}
+	}
+	return nil
+}
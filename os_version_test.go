@@ -0,0 +1,88 @@
+package traefik_plugin_block_useragents
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const (
+	windows7UA = "Mozilla/5.0 (Windows NT 6.1; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/109.0.0.0 Safari/537.36"
+	iOS15UA    = "Mozilla/5.0 (iPhone; CPU iPhone OS 15_8 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/15.6 Mobile/15E148 Safari/604.1"
+	iOS17UA    = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Mobile/15E148 Safari/604.1"
+	android9UA = "Mozilla/5.0 (Linux; Android 9; SM-G960F) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.0.0 Mobile Safari/537.36"
+)
+
+func TestOSVersionRuleAllows(t *testing.T) {
+	tests := []struct {
+		rule      string
+		userAgent string
+		want      bool
+	}{
+		{"win>=10", chromeUA, true},
+		{"win>=10", windows7UA, false},
+		{"win=7", windows7UA, true},
+		{"win>=10", "Mozilla/5.0 (Windows NT 4.0)", true}, // Unknown NT version
+		{"ios>=16", iOS17UA, true},
+		{"ios>=16", iOS15UA, false},
+		{"ios>17.4", iOS17UA, true},
+		{"android >= 10", android9UA, false},
+		{"macos<11", safariUA, true},    // Frozen at 10.15.7
+		{"android>=10", chromeUA, true}, // Other OS
+	}
+	for _, tt := range tests {
+		t.Run(tt.rule+" "+tt.userAgent, func(t *testing.T) {
+			rule, err := parseOSVersionRule(tt.rule)
+			if err != nil {
+				t.Fatalf("parseOSVersionRule() error: %v", err)
+			}
+			if got := rule.allows(tt.userAgent); got != tt.want {
+				t.Errorf("allows() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseOSVersionRuleErrors(t *testing.T) {
+	tests := []struct {
+		rule string
+		want string
+	}{
+		{"win", "expected <os><op><version>"},
+		{"win>=ten", "expected <os><op><version>"},
+		{"win=>10", "expected <os><op><version>"},
+		{"beos>=5", "unknown OS"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			if _, err := parseOSVersionRule(tt.rule); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("parseOSVersionRule() = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestOSVersionRules(t *testing.T) {
+	config := testConfig()
+	config.OSVersionRules = []string{"win>=10", "android>=10"}
+	config.ExposeReasonHeader = true
+	h := newTestHandler(t, config, nil)
+
+	tests := []struct {
+		userAgent string
+		want      int
+	}{
+		{chromeUA, http.StatusOK},
+		{windows7UA, http.StatusForbidden},
+		{android9UA, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		rec := serve(h, tt.userAgent)
+		if rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.userAgent, rec.Code, tt.want)
+		}
+		if tt.want == http.StatusForbidden && !strings.HasPrefix(rec.Header().Get("X-Block-Reason"), "Unsupported OS Version") {
+			t.Errorf("%s: reason = %q", tt.userAgent, rec.Header().Get("X-Block-Reason"))
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package traefik_plugin_block_useragents
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// defaultMinPlausibility is the plausibility below which User-Agents are
+// blocked when MinPlausibility is not set.
+const defaultMinPlausibility = 50
+
+// productTokenPattern matches product tokens such as "Chrome/131.0".
+var productTokenPattern = regexp.MustCompile(`[A-Za-z][A-Za-z0-9_.-]*/[0-9A-Za-z][0-9A-Za-z._+-]*`)
+
+// wordPattern matches runs of letters, checked for missing vowels.
+var wordPattern = regexp.MustCompile(`[A-Za-z]+`)
+
+// tokenOrders lists product tokens that real browsers send in a fixed order.
+var tokenOrders = [][2]string{
+	{"AppleWebKit/", "Chrome/"},
+	{"AppleWebKit/", "Safari/"},
+	{"Chrome/", "Safari/"},
+	{"Gecko/", "Firefox/"},
+}
+
+// plausibilityScore rates how much a User-Agent looks like one sent by a
+// real client, from 0 (implausible) to 100. It penalizes a missing
+// "Mozilla/5.0 (" prefix, unbalanced parentheses, the absence of product
+// tokens (besides the Mozilla one), well-known tokens out of their usual
+// order, vowel-less words typical of random strings, unusual characters and
+// odd lengths.
+func plausibilityScore(userAgent string) int {
+	score := 100
+	tokens := len(productTokenPattern.FindAllString(userAgent, -1))
+	if strings.HasPrefix(userAgent, "Mozilla/5.0 (") {
+		tokens-- // Browsers follow the Mozilla token with their own
+	} else {
+		score -= 30
+	}
+	if !balancedParentheses(userAgent) {
+		score -= 30
+	}
+	if tokens < 1 {
+		score -= 20
+	}
+	for _, order := range tokenOrders {
+		first, second := strings.Index(userAgent, order[0]), strings.Index(userAgent, order[1])
+		if first >= 0 && second >= 0 && second < first {
+			score -= 20
+		}
+	}
+	vowelless := 0
+	for _, word := range wordPattern.FindAllString(userAgent, -1) {
+		if len(word) >= 6 && !strings.ContainsAny(word, "aeiouyAEIOUY") {
+			vowelless++
+		}
+	}
+	score -= 10 * min(vowelless, 3)
+	if strings.IndexFunc(userAgent, unusualUserAgentRune) >= 0 {
+		score -= 20
+	}
+	if len(userAgent) < 10 || len(userAgent) > 512 {
+		score -= 20
+	}
+	return max(score, 0)
+}
+
+// balancedParentheses reports whether every parenthesis is closed, in order.
+func balancedParentheses(s string) bool {
+	depth := 0
+	for _, r := range s {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth < 0 {
+				return false
+			}
+		}
+	}
+	return depth == 0
+}
+
+// unusualUserAgentRune reports whether r is a character rarely found in
+// User-Agents: anything outside printable ASCII, and a few symbols.
+func unusualUserAgentRune(r rune) bool {
+	return r < 0x20 || r > 0x7e || strings.ContainsRune(`<>{}[]\^|~"`+"`", r)
+}
+
+// userAgentSegments splits a User-Agent on slashes, spaces and parentheses,
+// dropping empty segments: "Mozilla/5.0 (X11)" has 3 segments, "python" 1.
+func userAgentSegments(userAgent string) []string {
+	return strings.FieldsFunc(userAgent, func(r rune) bool {
+		return r == '/' || r == ' ' || r == '(' || r == ')'
+	})
+}
+
+// validatePlausibility checks the plausibility threshold and the minimum
+// segment count.
+func validatePlausibility(config *Config) error {
+	var errs ConfigErrors
+	if config.MinUASegments < 0 {
+		errs.add(configErrorf(ErrInvalidValue, "minUASegments must not be negative"))
+	}
+	if config.MinPlausibility < 0 || config.MinPlausibility > 100 {
+		errs.add(configErrorf(ErrInvalidValue, "minPlausibility must be between 0 and 100, got %d", config.MinPlausibility))
+	}
+	if config.MinPlausibility != 0 && !config.CheckPlausibility {
+		errs.add(configErrorf(ErrMissingSetting, "minPlausibility requires checkPlausibility"))
+	}
+	return errs.err()
+}
+
+// checkPlausibility blocks User-Agents scoring below the plausibility
+// threshold. With several matched values, the most plausible one counts.
+func (b *BlockUserAgents) checkPlausibility(e *evaluation) *decision {
+	if !b.checkPlausibilityEnabled || len(e.userAgents) == 0 {
+		return nil
+	}
+	best := 0
+	for _, userAgent := range e.userAgents {
+		best = max(best, plausibilityScore(userAgent))
+	}
+	if best >= b.minPlausibility {
+		return nil
+	}
+	return blockDecision(fmt.Sprintf("Implausible UA: %d", best))
+}
+
+// checkSegments blocks User-Agents with fewer segments than the minimum.
+// With several matched values, the one with the most segments counts.
+func (b *BlockUserAgents) checkSegments(e *evaluation) *decision {
+	if b.minUASegments == 0 || len(e.userAgents) == 0 {
+		return nil
+	}
+	most := 0
+	for _, userAgent := range e.userAgents {
+		most = max(most, len(userAgentSegments(userAgent)))
+	}
+	if most >= b.minUASegments {
+		return nil
+	}
+	return blockDecision("Too Few UA Segments")
+}
@@ -0,0 +1,106 @@
+package traefik_plugin_block_useragents
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+// reorderedChromeUA carries the Chrome tokens in an order no browser sends.
+const reorderedChromeUA = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) Safari/537.36 Chrome/131.0.0.0 AppleWebKit/537.36"
+
+func TestPlausibilityScore(t *testing.T) {
+	tests := []struct {
+		name      string
+		userAgent string
+		want      int
+	}{
+		{"Chrome", chromeUA, 100},
+		{"Firefox", firefoxUA, 100},
+		{"Safari", safariUA, 100},
+		{"Googlebot", "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", 100},
+		{"library", "python-requests/2.31.0", 70},
+		{"short tool", curlUA, 50},
+		{"unbalanced parentheses", "Mozilla/5.0 (Windows NT 10.0; Win64; x64 AppleWebKit/537.36", 70},
+		{"tokens out of order", reorderedChromeUA, 40},
+		{"unusual characters", "Mozilla/5.0 (X11) <script>", 60},
+		{"random letters", "xkcdqwrtzpl bcdfghjkl mnbvcxz", 20},
+		{"empty", "", 30},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := plausibilityScore(tt.userAgent); got != tt.want {
+				t.Errorf("plausibilityScore(%q) = %d, want %d", tt.userAgent, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckPlausibility(t *testing.T) {
+	tests := []struct {
+		name       string
+		min        int
+		userAgent  string
+		wantStatus int
+		wantReason string
+	}{
+		{"plausible", 0, chromeUA, http.StatusOK, ""},
+		{"below default threshold", 0, reorderedChromeUA, http.StatusForbidden, "Implausible UA: 40"},
+		{"above lowered threshold", 40, reorderedChromeUA, http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.CheckPlausibility = true
+			config.MinPlausibility = tt.min
+			config.ExposeReasonHeader = true
+			h := newTestHandler(t, config, nil)
+
+			rec := serve(h, tt.userAgent)
+			if rec.Code != tt.wantStatus || rec.Header().Get("X-Block-Reason") != tt.wantReason {
+				t.Errorf("response = %d %q, want %d %q", rec.Code, rec.Header().Get("X-Block-Reason"), tt.wantStatus, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestUserAgentSegments(t *testing.T) {
+	tests := []struct {
+		userAgent string
+		want      []string
+	}{
+		{"Mozilla/5.0 (X11)", []string{"Mozilla", "5.0", "X11"}},
+		{"python", []string{"python"}},
+		{"curl/8.0", []string{"curl", "8.0"}},
+		{" //(  ) ", []string{}},
+		{"a;b c/d", []string{"a;b", "c", "d"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.userAgent, func(t *testing.T) {
+			if got := userAgentSegments(tt.userAgent); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("userAgentSegments() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMinUASegments(t *testing.T) {
+	config := testConfig()
+	config.AllowedBrowsers = append(config.AllowedBrowsers, BrowserConfig{Name: "curl", Regex: "^curl/"})
+	config.MinUASegments = 3
+	h := newTestHandler(t, config, nil)
+
+	tests := []struct {
+		userAgent string
+		want      int
+	}{
+		{chromeUA, http.StatusOK},
+		{"curl/8.0", http.StatusForbidden},
+		{"curl/8.0 (x86_64)", http.StatusOK},
+	}
+	for _, tt := range tests {
+		if rec := serve(h, tt.userAgent); rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.userAgent, rec.Code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package traefik_plugin_block_useragents
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"sort"
+)
+
+// PolicyConfig is a named browser and OS ruleset, see Config.Policies.
+type PolicyConfig struct {
+	AllowedBrowsers []BrowserConfig `json:"allowedBrowsers,omitempty"` // Replaces the top-level allowedBrowsers
+	AllowedOSTypes  []string        `json:"allowedOSTypes,omitempty"`  // Replaces the top-level allowedOSTypes
+	AllowedOSNames  []string        `json:"allowedOSNames,omitempty"`  // Replaces the top-level allowedOSNames
+	BlockedBrowsers []BrowserConfig `json:"blockedBrowsers,omitempty"` // Replaces the top-level blockedBrowsers
+}
+
+// hostPolicy selects a policy for the hosts matching a regex.
+type hostPolicy struct {
+	re     *regexp.Regexp
+	policy *BlockUserAgents
+}
+
+// policyConfig returns the configuration of a policy: the top-level settings
+// with the policy rulesets in place of the top-level ones.
+func policyConfig(config *Config, policy PolicyConfig) *Config {
+	derived := *config
+	derived.AllowedBrowsers = policy.AllowedBrowsers
+	derived.AllowedOSTypes = policy.AllowedOSTypes
+	derived.AllowedOSNames = policy.AllowedOSNames
+	derived.BlockedBrowsers = policy.BlockedBrowsers
+	derived.GlobBrowsers = nil // Other top-level browser rules do not apply to the policies
+	derived.DenyBrowsers = nil
+	derived.AllowOverrides = nil
+	derived.SoftAllowedBrowsers = nil
+	derived.ChallengeBrowsers = nil
+	derived.Rules = nil // The ordered rules and their default would decide before the policy allowlist
+	derived.DefaultAction = ""
+	derived.DefaultDecision = ""
+	derived.UseEmbeddedBaseline = false
+	derived.OSVersionRules = nil
+	derived.BrandVersionRules = nil
+	derived.ScoreRules = nil
+	derived.ScoreThreshold = 0
+	derived.ShadowBrowsers = nil // The shadow ruleset applies to the top-level rules only
+	derived.ShadowOSTypes = nil
+	derived.Policies = nil
+	derived.HostPolicyMap = nil
+	derived.DefaultPolicy = ""
+	derived.RulesFile = "" // Already merged into the top-level config
+	derived.RulesURL = ""
+	derived.BrowserSpecs = nil // Already merged into allowedBrowsers
+	derived.ThreatFeedURL = "" // The top-level feed is shared with the policies
+	derived.ReloadInterval = ""
+	derived.ReloadOnSignal = false // Reloading the top-level config rebuilds the policies
+	derived.BlockLogFile = ""
+	derived.BlockLogMaxBytes = 0
+	derived.WebhookURL = "" // The top-level webhook is shared with the policies
+	derived.WebhookAuthHeader = ""
+	derived.LearnMode = false // Learn mode applies to the whole middleware
+	derived.LearnInterval = ""
+	derived.LearnFile = ""
+	derived.SelfTestUserAgents = nil
+	return &derived
+}
+
+// sortedHostPatterns returns the host patterns in the order they are tried.
+func sortedHostPatterns(hostPolicyMap map[string]string) []string {
+	patterns := make([]string, 0, len(hostPolicyMap))
+	for pattern := range hostPolicyMap {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+	return patterns
+}
+
+// sortedPolicyNames returns the policy names in sorted order.
+func sortedPolicyNames(policies map[string]PolicyConfig) []string {
+	names := make([]string, 0, len(policies))
+	for name := range policies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// validatePolicies checks the policies and the references to them.
+func validatePolicies(config *Config) error {
+	var errs ConfigErrors
+	for _, policyName := range sortedPolicyNames(config.Policies) {
+		errs.add(prefixErrors("policy "+policyName, ValidateConfig(policyConfig(config, config.Policies[policyName]))))
+	}
+	for _, pattern := range sortedHostPatterns(config.HostPolicyMap) {
+		policyName := config.HostPolicyMap[pattern]
+		if _, ok := config.Policies[policyName]; !ok {
+			errs.add(configErrorf(ErrInvalidValue, "hostPolicyMap %q references unknown policy %q", pattern, policyName))
+		}
+		if _, err := compileRegexp(pattern); err != nil {
+			errs.add(configErrorf(ErrInvalidValue, "invalid hostPolicyMap regex %q: %w", pattern, err))
+		}
+	}
+	if _, ok := config.Policies[config.DefaultPolicy]; config.DefaultPolicy != "" && !ok {
+		errs.add(configErrorf(ErrInvalidValue, "defaultPolicy references unknown policy %q", config.DefaultPolicy))
+	}
+	return errs.err()
+}
+
+// newPolicies creates a plugin instance per policy and the host selectors.
+func (b *BlockUserAgents) newPolicies(ctx context.Context, next http.Handler, config *Config) error {
+	if len(config.Policies) == 0 {
+		return nil
+	}
+	policies := make(map[string]*BlockUserAgents, len(config.Policies))
+	for policyName, policy := range config.Policies {
+		handler, err := New(ctx, next, policyConfig(config, policy), b.name+"."+policyName)
+		if err != nil {
+			return fmt.Errorf("policy %s: %w", policyName, err)
+		}
+		policies[policyName] = handler.(*BlockUserAgents)
+	}
+	for _, pattern := range sortedHostPatterns(config.HostPolicyMap) {
+		re, err := compileRegexp(pattern)
+		if err != nil {
+			return fmt.Errorf("error compiling host regex %q: %w", pattern, err)
+		}
+		b.hostPolicies = append(b.hostPolicies, hostPolicy{re: re, policy: policies[config.HostPolicyMap[pattern]]})
+	}
+	b.defaultPolicy = policies[config.DefaultPolicy]
+	b.policies = policies
+	return nil
+}
+
+// policyFor returns the policy selected by the request host, or nil when
+// the top-level rules apply.
+func (b *BlockUserAgents) policyFor(req *http.Request) *BlockUserAgents {
+	if len(b.policies) == 0 {
+		return nil
+	}
+	host := req.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	for _, hp := range b.hostPolicies {
+		if hp.re.MatchString(host) {
+			return hp.policy
+		}
+	}
+	return b.defaultPolicy
+}
@@ -0,0 +1,149 @@
+package traefik_plugin_block_useragents
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPolicies(t *testing.T) {
+	tests := []struct {
+		name          string
+		defaultPolicy string
+		host          string
+		userAgent     string
+		want          int
+	}{
+		{"mapped host", "", "shop.example.com", chromeUA, http.StatusOK},
+		{"mapped host with port", "", "shop.example.com:8443", chromeUA, http.StatusOK},
+		{"policy replaces the top-level rules", "", "shop.example.com", firefoxUA, http.StatusForbidden},
+		{"other policy", "", "api.example.com", "MyApp/2.1", http.StatusOK},
+		{"unmapped host uses the top-level rules", "", "www.example.com", firefoxUA, http.StatusOK},
+		{"unmapped host uses the default policy", "api", "www.example.com", firefoxUA, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := CreateConfig()
+			config.AllowedBrowsers = []BrowserConfig{{Name: "Firefox", Regex: `Firefox/`}}
+			config.Policies = map[string]PolicyConfig{
+				"shop": {AllowedBrowsers: []BrowserConfig{{Name: "Chrome", Regex: `Chrome/13[0-3]`}}},
+				"api":  {AllowedBrowsers: []BrowserConfig{{Name: "App", Regex: `^MyApp/`}}},
+			}
+			config.HostPolicyMap = map[string]string{`^shop\.example\.com$`: "shop", `^api\.example\.com$`: "api"}
+			config.DefaultPolicy = tt.defaultPolicy
+			h := newTestHandler(t, config, nil)
+
+			if rec := serve(h, tt.userAgent, withHost(tt.host)); rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+
+	config := testConfig()
+	config.HostPolicyMap = map[string]string{`^shop\.`: "missing"}
+	if err := ValidateConfig(config); !errors.Is(err, ErrInvalidValue) {
+		t.Errorf("ValidateConfig with an unknown policy = %v, want %v", err, ErrInvalidValue)
+	}
+}
+
+func TestPoliciesWithReloadOnSignal(t *testing.T) {
+	rulesFile := filepath.Join(t.TempDir(), "rules.yaml")
+	if err := os.WriteFile(rulesFile, []byte("allowedBrowsers:\n  - name: Chrome\n    regex: \"Chrome/\"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	config := CreateConfig()
+	config.RulesFile = rulesFile
+	config.ReloadOnSignal = true
+	config.Policies = map[string]PolicyConfig{
+		"a": {AllowedBrowsers: []BrowserConfig{{Name: "Firefox", Regex: `Firefox/`}}},
+	}
+	config.HostPolicyMap = map[string]string{`^a\.example\.com$`: "a"}
+	h := newTestHandler(t, config, nil)
+
+	tests := []struct {
+		name      string
+		host      string
+		userAgent string
+		want      int
+	}{
+		{"top-level allows Chrome", "example.com", chromeUA, http.StatusOK},
+		{"top-level blocks Firefox", "example.com", firefoxUA, http.StatusForbidden},
+		{"policy allows Firefox", "a.example.com", firefoxUA, http.StatusOK},
+		{"policy blocks Chrome", "a.example.com", chromeUA, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(h, tt.userAgent, withHost(tt.host))
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestPoliciesIgnoreTopLevelRules(t *testing.T) {
+	config := testConfig()
+	config.GlobBrowsers = []string{"curl/*"}
+	config.DenyBrowsers = []BrowserConfig{{Name: "Firefox 128", Regex: `Firefox/128`}}
+	config.SoftAllowedBrowsers = []BrowserConfig{{Name: "Safari", Regex: `Safari/`}}
+	config.ChallengeBrowsers = []string{`Firefox/`}
+	config.ChallengeSecret = "secret"
+	config.Policies = map[string]PolicyConfig{
+		"firefox": {AllowedBrowsers: []BrowserConfig{{Name: "Firefox", Regex: `Firefox/`}}},
+	}
+	config.DefaultPolicy = "firefox"
+	h := newTestHandler(t, config, nil)
+
+	tests := []struct {
+		name      string
+		userAgent string
+		want      int
+	}{
+		{"policy allows Firefox", firefoxUA, http.StatusOK},
+		{"top-level glob", curlUA, http.StatusForbidden},
+		{"top-level allowlist", chromeUA, http.StatusForbidden},
+		{"top-level soft allowlist", safariUA, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := serve(h, tt.userAgent); rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestPoliciesIgnoreTopLevelDecisions(t *testing.T) {
+	config := CreateConfig()
+	config.Rules = []Rule{{Pattern: `Chrome/`, Action: RuleAllow}}
+	config.DefaultAction = RuleDeny
+	config.UseEmbeddedBaseline = true
+	config.ScoreRules = []ScoreRule{{Name: "firefox", Regex: `Firefox/`, Weight: -10}}
+	config.ScoreThreshold = 0
+	config.Policies = map[string]PolicyConfig{
+		"firefox": {AllowedBrowsers: []BrowserConfig{{Name: "Firefox", Regex: `Firefox/`}}},
+	}
+	config.HostPolicyMap = map[string]string{`^a\.example\.com$`: "firefox"}
+	h := newTestHandler(t, config, nil)
+
+	tests := []struct {
+		name      string
+		host      string
+		userAgent string
+		want      int
+	}{
+		{"top-level allow rule", "example.com", chromeUA, http.StatusOK},
+		{"top-level default action", "example.com", firefoxUA, http.StatusForbidden},
+		{"policy allows Firefox", "a.example.com", firefoxUA, http.StatusOK},
+		{"policy blocks Chrome", "a.example.com", chromeUA, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := serve(h, tt.userAgent, withHost(tt.host)); rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,8 @@
+package traefik_plugin_block_useragents
+
+import "net/http"
+
+// isPreflight reports whether the request is a CORS preflight request.
+func isPreflight(req *http.Request) bool {
+	return req.Method == http.MethodOptions && req.Header.Get("Access-Control-Request-Method") != ""
+}
@@ -0,0 +1,36 @@
+package traefik_plugin_block_useragents
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestAllowPreflight(t *testing.T) {
+	preflight := func(req *http.Request) {
+		req.Method = http.MethodOptions
+		req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	}
+	tests := []struct {
+		name           string
+		allowPreflight bool
+		deniedIPs      []string
+		edits          []func(*http.Request)
+		want           int
+	}{
+		{"preflight forwarded", true, nil, []func(*http.Request){preflight}, http.StatusOK},
+		{"preflight checked when disabled", false, nil, []func(*http.Request){preflight}, http.StatusForbidden},
+		{"plain OPTIONS checked", true, nil, []func(*http.Request){withMethod(http.MethodOptions)}, http.StatusForbidden},
+		{"preflight from denied IP", true, []string{"192.0.2.1"}, []func(*http.Request){preflight}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.AllowPreflight = tt.allowPreflight
+			config.DeniedIPs = tt.deniedIPs
+			h := newTestHandler(t, config, nil)
+			if rec := serve(h, curlUA, tt.edits...); rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package traefik_plugin_block_useragents
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// maxBucketsPerRule bounds the number of per-client buckets kept for a single rate limit rule.
+const maxBucketsPerRule = 10000
+
+// RateLimitRule defines a request budget for User-Agents matching a regex.
+type RateLimitRule struct {
+	Name     string `json:"name,omitempty"`     // Rule name used in logs
+	Regex    string `json:"regex,omitempty"`    // Required: Regex pattern matching the throttled User-Agents
+	Requests int    `json:"requests,omitempty"` // Required: Number of requests allowed per interval
+	Interval string `json:"interval,omitempty"` // Optional: Interval as a Go duration (default "1m")
+	PerIP    bool   `json:"perIp,omitempty"`    // Optional: Keep a separate budget per client IP
+}
+
+// rateLimiter enforces a single RateLimitRule with token buckets.
+type rateLimiter struct {
+	name     string
+	re       *regexp.Regexp
+	capacity float64
+	rate     float64 // tokens per second
+	perIP    bool
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// validateRateLimit checks a single rate limit rule.
+func validateRateLimit(rule RateLimitRule) error {
+	var errs ConfigErrors
+	if rule.Regex == "" {
+		errs.add(configErrorf(ErrMissingRegex, "regex must be provided for rate limit: %s", rule.Name))
+	}
+	if rule.Requests <= 0 {
+		errs.add(configErrorf(ErrInvalidValue, "requests must be positive for rate limit: %s", rule.Name))
+	}
+	if rule.Interval != "" {
+		interval, err := time.ParseDuration(rule.Interval)
+		switch {
+		case err != nil:
+			errs.add(configErrorf(ErrInvalidValue, "invalid interval %q for rate limit %s: %w", rule.Interval, rule.Name, err))
+		case interval <= 0:
+			errs.add(configErrorf(ErrInvalidValue, "interval must be positive for rate limit: %s", rule.Name))
+		}
+	}
+	return errs.err()
+}
+
+// newRateLimiter compiles a validated rate limit rule.
+func newRateLimiter(rule RateLimitRule) (*rateLimiter, error) {
+	re, err := compileRegexp(rule.Regex)
+	if err != nil {
+		return nil, fmt.Errorf("error compiling rate limit regex for %s: %w", rule.Name, err)
+	}
+	interval := time.Minute
+	if rule.Interval != "" {
+		interval, _ = time.ParseDuration(rule.Interval)
+	}
+	return &rateLimiter{
+		name:     rule.Name,
+		re:       re,
+		capacity: float64(rule.Requests),
+		rate:     float64(rule.Requests) / interval.Seconds(),
+		perIP:    rule.PerIP,
+		buckets:  make(map[string]*tokenBucket),
+	}, nil
+}
+
+// allow takes a token for the given client and reports whether the request fits the budget.
+// When it does not, the returned duration is the time until the next token is available.
+func (rl *rateLimiter) allow(clientIP string, now time.Time) (bool, time.Duration) {
+	key := ""
+	if rl.perIP {
+		key = clientIP
+	}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	bucket, ok := rl.buckets[key]
+	if !ok {
+		if len(rl.buckets) >= maxBucketsPerRule {
+			rl.evict(now)
+		}
+		bucket = &tokenBucket{tokens: rl.capacity, last: now}
+		rl.buckets[key] = bucket
+	}
+
+	bucket.tokens = math.Min(rl.capacity, bucket.tokens+now.Sub(bucket.last).Seconds()*rl.rate)
+	bucket.last = now
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - bucket.tokens) / rl.rate * float64(time.Second))
+	return false, wait
+}
+
+// evict drops buckets that have refilled completely, falling back to an arbitrary
+// bucket when none have, so memory stays bounded. Must be called with mu held.
+func (rl *rateLimiter) evict(now time.Time) {
+	for key, bucket := range rl.buckets {
+		if bucket.tokens+now.Sub(bucket.last).Seconds()*rl.rate >= rl.capacity {
+			delete(rl.buckets, key)
+		}
+	}
+	if len(rl.buckets) < maxBucketsPerRule {
+		return
+	}
+	for key := range rl.buckets {
+		delete(rl.buckets, key)
+		return
+	}
+}
@@ -0,0 +1,48 @@
+package traefik_plugin_block_useragents
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestRateLimits(t *testing.T) {
+	fromIP := func(ip string) func(*http.Request) {
+		return func(req *http.Request) { req.RemoteAddr = ip + ":1234" }
+	}
+	tests := []struct {
+		name  string
+		perIP bool
+		want  []int // Statuses of requests from 192.0.2.1, 192.0.2.1, 192.0.2.1 and 192.0.2.2
+	}{
+		{"shared budget", false, []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests, http.StatusTooManyRequests}},
+		{"budget per IP", true, []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests, http.StatusOK}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.AllowedBrowsers = append(config.AllowedBrowsers, BrowserConfig{Name: "curl", Regex: `^curl/`})
+			config.RateLimits = []RateLimitRule{{Name: "tools", Regex: `^curl/`, Requests: 2, Interval: "1h", PerIP: tt.perIP}}
+			h := newTestHandler(t, config, nil)
+
+			for i, ip := range []string{"192.0.2.1", "192.0.2.1", "192.0.2.1", "192.0.2.2"} {
+				rec := serve(h, curlUA, fromIP(ip))
+				if rec.Code != tt.want[i] {
+					t.Errorf("request %d from %s: status = %d, want %d", i+1, ip, rec.Code, tt.want[i])
+				}
+				if rec.Code == http.StatusTooManyRequests && rec.Header().Get("Retry-After") != "1800" {
+					t.Errorf("request %d: Retry-After = %q, want 1800", i+1, rec.Header().Get("Retry-After"))
+				}
+			}
+			if rec := serve(h, chromeUA, fromIP("192.0.2.1")); rec.Code != http.StatusOK {
+				t.Errorf("status of a non-matching User-Agent = %d, want %d", rec.Code, http.StatusOK)
+			}
+		})
+	}
+
+	config := testConfig()
+	config.RateLimits = []RateLimitRule{{Name: "tools", Regex: `^curl/`}}
+	if err := ValidateConfig(config); !errors.Is(err, ErrInvalidValue) {
+		t.Errorf("ValidateConfig without requests = %v, want %v", err, ErrInvalidValue)
+	}
+}
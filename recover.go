@@ -0,0 +1,75 @@
+package traefik_plugin_block_useragents
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"sync"
+)
+
+// subsystemGuard recovers panics raised by optional subsystems so a bug in,
+// say, the decision cache never turns into a 500 for a legitimate request.
+type subsystemGuard struct {
+	name   string
+	logged sync.Map // Subsystems whose panic has already been logged
+}
+
+// run calls fn and reports whether it completed. A panic in fn is recovered
+// and logged once per subsystem; the caller then falls back to the core logic.
+func (g *subsystemGuard) run(subsystem string, fn func()) (ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			ok = false
+			if _, loaded := g.logged.LoadOrStore(subsystem, struct{}{}); !loaded {
+				log.Printf("%s: recovered from panic in %s, continuing without it: %v", g.name, subsystem, r)
+			}
+		}
+	}()
+	fn()
+	return true
+}
+
+// validateRecoverDownstream checks the downstream panic settings.
+func validateRecoverDownstream(config *Config) error {
+	var errs ConfigErrors
+	if !config.RecoverDownstream && (config.RecoverStatusCode != 0 || config.RepanicDownstream) {
+		errs.add(configErrorf(ErrMissingSetting, "recoverStatusCode and repanicDownstream require recoverDownstream"))
+	}
+	if config.RecoverStatusCode != 0 && !isErrorStatus(config.RecoverStatusCode) {
+		errs.add(configErrorf(ErrInvalidStatusCode, "recoverStatusCode must be a 4xx or 5xx status, got %d", config.RecoverStatusCode))
+	}
+	if config.RecoverStatusCode != 0 && config.RepanicDownstream {
+		errs.add(configErrorf(ErrConflictingSettings, "recoverStatusCode has no effect when repanicDownstream is set"))
+	}
+	return errs.err()
+}
+
+// newRecoverStatusCode returns the status answered after a recovered panic.
+func newRecoverStatusCode(config *Config) int {
+	if config.RecoverStatusCode == 0 {
+		return http.StatusInternalServerError
+	}
+	return config.RecoverStatusCode
+}
+
+// recoverNext, deferred around the next handler, logs a panic with the
+// request context and answers with the recover status, or re-raises it with
+// RepanicDownstream. http.ErrAbortHandler, which aborts a response on
+// purpose, is always re-raised. If the response was already started, its
+// status cannot change and only the log entry remains.
+func (b *BlockUserAgents) recoverNext(res http.ResponseWriter, req *http.Request) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	if r == http.ErrAbortHandler { //nolint:errorlint // Compared as net/http does
+		panic(r)
+	}
+	b.logEvent(req, "Downstream-Panic", fmt.Sprint(r), 0)
+	log.Printf("%s: panic in next handler: %v\n%s", b.name, r, debug.Stack())
+	if b.repanicDownstream {
+		panic(r)
+	}
+	res.WriteHeader(b.recoverStatusCode)
+}
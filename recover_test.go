@@ -0,0 +1,92 @@
+package traefik_plugin_block_useragents
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// captureLog redirects the standard logger to a buffer until the end of the test.
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	out := log.Writer()
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(out) })
+	return &buf
+}
+
+func TestSubsystemGuard(t *testing.T) {
+	logs := captureLog(t)
+	g := &subsystemGuard{name: "test"}
+
+	if !g.run("cache", func() {}) {
+		t.Error("run() = false for a function that returned")
+	}
+	for i := 0; i < 3; i++ {
+		if g.run("cache", func() { panic("boom") }) {
+			t.Error("run() = true for a function that panicked")
+		}
+	}
+	if got := strings.Count(logs.String(), "recovered from panic in cache"); got != 1 {
+		t.Errorf("panic logged %d times, want once:\n%s", got, logs)
+	}
+}
+
+func TestSubsystemPanicKeepsDecision(t *testing.T) {
+	captureLog(t)
+	config := testConfig()
+	config.Tracing = true
+	h := newTestHandler(t, config, nil)
+	h.SpanHook = func(DecisionSpan) { panic("broken hook") }
+
+	tests := []struct {
+		userAgent string
+		want      int
+	}{
+		{chromeUA, http.StatusOK},
+		{curlUA, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		if rec := serve(h, tt.userAgent); rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.userAgent, rec.Code, tt.want)
+		}
+	}
+}
+
+func TestRecoverDownstream(t *testing.T) {
+	captureLog(t)
+	tests := []struct {
+		name    string
+		status  int
+		repanic bool
+		value   any
+		want    int
+		raised  bool
+	}{
+		{name: "default status", value: "boom", want: http.StatusInternalServerError},
+		{name: "custom status", status: http.StatusBadGateway, value: "boom", want: http.StatusBadGateway},
+		{name: "repanic", repanic: true, value: "boom", raised: true},
+		{name: "aborted response", value: http.ErrAbortHandler, raised: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.RecoverDownstream = true
+			config.RecoverStatusCode = tt.status
+			config.RepanicDownstream = tt.repanic
+			h := newTestHandler(t, config, http.HandlerFunc(func(http.ResponseWriter, *http.Request) { panic(tt.value) }))
+
+			defer func() {
+				if r := recover(); (r != nil) != tt.raised {
+					t.Errorf("panic raised = %v, want %v", r != nil, tt.raised)
+				}
+			}()
+			if rec := serve(h, chromeUA); rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package traefik_plugin_block_useragents
+
+import (
+	"container/list"
+	"regexp"
+	"sync"
+)
+
+// maxRegexpCacheEntries bounds the patterns kept by regexpCache. Evicted
+// patterns stay valid for the instances using them; they are only compiled
+// again by the next instance needing them.
+const maxRegexpCacheEntries = 4096
+
+// regexpCache shares compiled patterns between plugin instances. Traefik calls
+// New once per router using the middleware, so identical rulesets would
+// otherwise be compiled again for every router. *regexp.Regexp is safe for
+// concurrent use, so sharing the compiled objects is fine. The cache is an
+// LRU, so patterns dropped by reloads or only compiled by the validation
+// endpoint are eventually evicted.
+var regexpCache = struct {
+	sync.Mutex
+	order   *list.List // Front is most recently used
+	entries map[string]*list.Element
+}{order: list.New(), entries: make(map[string]*list.Element)}
+
+type regexpCacheEntry struct {
+	pattern string
+	re      *regexp.Regexp
+}
+
+// compileRegexp compiles pattern, reusing a previously compiled copy when available.
+func compileRegexp(pattern string) (*regexp.Regexp, error) {
+	regexpCache.Lock()
+	defer regexpCache.Unlock()
+
+	if elem, ok := regexpCache.entries[pattern]; ok {
+		regexpCache.order.MoveToFront(elem)
+		return elem.Value.(*regexpCacheEntry).re, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if regexpCache.order.Len() >= maxRegexpCacheEntries {
+		oldest := regexpCache.order.Back()
+		regexpCache.order.Remove(oldest)
+		delete(regexpCache.entries, oldest.Value.(*regexpCacheEntry).pattern)
+	}
+	regexpCache.entries[pattern] = regexpCache.order.PushFront(&regexpCacheEntry{pattern: pattern, re: re})
+	return re, nil
+}
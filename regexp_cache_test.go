@@ -0,0 +1,80 @@
+package traefik_plugin_block_useragents
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"testing"
+)
+
+func TestRegexpCacheShared(t *testing.T) {
+	first := newTestHandler(t, testConfig(), nil)
+	second := newTestHandler(t, testConfig(), nil)
+	if first.allowedRules[0].re != second.allowedRules[0].re {
+		t.Error("instances compiled the same pattern separately")
+	}
+}
+
+func TestRegexpCacheBounded(t *testing.T) {
+	first, err := compileRegexp(`^first-pattern$`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := compileRegexp(`^first-pattern$`); again != first {
+		t.Error("pattern compiled again while cached")
+	}
+	for i := 0; i < maxRegexpCacheEntries+10; i++ {
+		if _, err := compileRegexp(fmt.Sprintf("^filler-%d$", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := len(regexpCache.entries); n > maxRegexpCacheEntries {
+		t.Errorf("cache holds %d patterns, want at most %d", n, maxRegexpCacheEntries)
+	}
+	if again, _ := compileRegexp(`^first-pattern$`); again == first {
+		t.Error("least recently used pattern not evicted")
+	}
+	if _, err := compileRegexp(`(`); err == nil {
+		t.Error("invalid pattern compiled")
+	}
+}
+
+// largeRulesetConfig returns a configuration with many allowed browsers.
+func largeRulesetConfig() *Config {
+	config := CreateConfig()
+	for i := 0; i < 200; i++ {
+		config.AllowedBrowsers = append(config.AllowedBrowsers, BrowserConfig{
+			Name:  fmt.Sprintf("Browser %d", i),
+			Regex: fmt.Sprintf(`Browser%d/(?:1[0-9]{2}|[2-9][0-9]{2})\.[0-9]+`, i),
+		})
+	}
+	return config
+}
+
+// BenchmarkNewSharedRegexps builds many instances of one ruleset, sharing
+// the compiled patterns.
+func BenchmarkNewSharedRegexps(b *testing.B) {
+	config := largeRulesetConfig()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		handler, err := New(context.Background(), okHandler, config, "bench")
+		if err != nil {
+			b.Fatal(err)
+		}
+		_ = handler.(*BlockUserAgents).Close()
+	}
+}
+
+// BenchmarkCompileRegexps compiles the same patterns without the cache, as
+// every instance did before they were shared.
+func BenchmarkCompileRegexps(b *testing.B) {
+	config := largeRulesetConfig()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for _, bc := range config.AllowedBrowsers {
+			if _, err := regexp.Compile(bc.Regex); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
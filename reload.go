@@ -0,0 +1,192 @@
+package traefik_plugin_block_useragents
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// reloadRegistry dispatches SIGHUP to the instances that reload on signal.
+// A single signal handler is shared by all instances; it is installed with
+// the first registration and removed with the last.
+var reloadRegistry = struct {
+	sync.Mutex
+	instances map[*BlockUserAgents]struct{}
+	signals   chan os.Signal
+}{instances: make(map[*BlockUserAgents]struct{})}
+
+// rulesWatch refreshes the rules of an instance from RulesURL every
+// ReloadInterval, reloading them when they changed.
+type rulesWatch struct {
+	url      string
+	maxBytes int
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// validateReload checks that there is a rules source to reload.
+func validateReload(config *Config) error {
+	if config.ReloadOnSignal && config.RulesFile == "" && config.RulesURL == "" {
+		return configErrorf(ErrMissingSetting, "rulesFile or rulesUrl must be provided when reloadOnSignal is enabled")
+	}
+	return nil
+}
+
+// registerReload makes the instance reload its rules on SIGHUP.
+func registerReload(b *BlockUserAgents) {
+	reloadRegistry.Lock()
+	defer reloadRegistry.Unlock()
+
+	reloadRegistry.instances[b] = struct{}{}
+	if reloadRegistry.signals != nil {
+		return
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	reloadRegistry.signals = signals
+	go func() {
+		for range signals {
+			reloadRegistry.Lock()
+			instances := make([]*BlockUserAgents, 0, len(reloadRegistry.instances))
+			for instance := range reloadRegistry.instances {
+				instances = append(instances, instance)
+			}
+			reloadRegistry.Unlock()
+			for _, instance := range instances {
+				instance.reload()
+			}
+		}
+	}()
+}
+
+// unregisterReload stops reloading the instance, removing the signal
+// handler when no instance is left.
+func unregisterReload(b *BlockUserAgents) {
+	reloadRegistry.Lock()
+	defer reloadRegistry.Unlock()
+
+	delete(reloadRegistry.instances, b)
+	if len(reloadRegistry.instances) == 0 && reloadRegistry.signals != nil {
+		signal.Stop(reloadRegistry.signals)
+		close(reloadRegistry.signals)
+		reloadRegistry.signals = nil
+	}
+}
+
+// unregisterReloadOnDone unregisters the instance from SIGHUP once ctx, the
+// construction context, is done, since Traefik never calls Close.
+func (b *BlockUserAgents) unregisterReloadOnDone(ctx context.Context) {
+	b.reloadStop = make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			unregisterReload(b)
+		case <-b.reloadStop:
+		}
+	}()
+}
+
+// reload rebuilds the instance from its configuration, reading the rules
+// file again, and swaps the new ruleset in for the following requests. On
+// failure, the current ruleset is kept.
+func (b *BlockUserAgents) reload() {
+	b.reloadWith(nil)
+}
+
+// reloadWith reloads the instance like reload, using the given rules fetched
+// from the RulesURL, if any, instead of fetching them again.
+func (b *BlockUserAgents) reloadWith(fetched *fetchedRules) {
+	reloaded, err := newBlockUserAgents(b.ctx, b.next, b.reloadConfig, b.name, false, fetched)
+	if err != nil {
+		log.Printf("%s: rules reload failed, keeping the current rules: %v", b.name, err)
+		return
+	}
+	reloaded.inheritWarmup(b)
+	reloaded.stats = b.stats // Keep counting where the previous rulesets left off
+	for _, policy := range reloaded.policies {
+		policy.stats = b.stats
+	}
+	b.stats.reloadedAt(b.clock.Now())
+	if previous := b.reloaded.Swap(reloaded); previous != nil {
+		_ = previous.Close()
+	} else {
+		b.clearCaches() // The original ruleset no longer serves requests
+	}
+	log.Printf("%s: rules reloaded", b.name)
+}
+
+// clearCaches drops the cached decisions of the instance and its policies.
+func (b *BlockUserAgents) clearCaches() {
+	if b.cache != nil {
+		b.cache.clear()
+	}
+	for _, policy := range b.policies {
+		policy.clearCaches()
+	}
+}
+
+// watchRulesURL starts refreshing the rules of a validated config from its
+// RulesURL every ReloadInterval, until Close is called or ctx, the
+// construction context, is cancelled.
+func (b *BlockUserAgents) watchRulesURL(ctx context.Context, config *Config) *rulesWatch {
+	w := &rulesWatch{url: config.RulesURL, maxBytes: config.MaxRulesBytes, stop: make(chan struct{})}
+	if w.maxBytes == 0 {
+		w.maxBytes = defaultMaxRulesBytes
+	}
+	interval, _ := time.ParseDuration(config.ReloadInterval)
+	go b.refreshRulesEvery(ctx, w, interval)
+	return w
+}
+
+// refreshRulesEvery refreshes the rules every interval until the watch is
+// closed or ctx is cancelled.
+func (b *BlockUserAgents) refreshRulesEvery(ctx context.Context, w *rulesWatch, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			b.refreshRules(ctx, w)
+		case <-ctx.Done():
+			return
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+// refreshRules sends a conditional request for the rules, with the cache
+// validators of the ruleset serving requests, and reloads the rules from the
+// response unless the server answers 304 Not Modified. A failed request or
+// invalid rules keep the current rules.
+func (b *BlockUserAgents) refreshRules(ctx context.Context, w *rulesWatch) {
+	validators := b.rulesValidators
+	if reloaded := b.reloaded.Load(); reloaded != nil {
+		validators = reloaded.rulesValidators
+	}
+	data, header, err := download(ctx, w.url, w.maxBytes, validators)
+	if errors.Is(err, errNotModified) {
+		return
+	}
+	if err != nil {
+		log.Printf("%s: keeping the current rules: %v", b.name, err)
+		return
+	}
+	fetched, err := parseFetchedRules(w.url, data, header)
+	if err != nil {
+		log.Printf("%s: keeping the current rules: %v", b.name, err)
+		return
+	}
+	b.reloadWith(fetched)
+}
+
+// close stops the refresh. It is idempotent.
+func (w *rulesWatch) close() {
+	w.stopOnce.Do(func() { close(w.stop) })
+}
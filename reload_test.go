@@ -0,0 +1,96 @@
+package traefik_plugin_block_useragents
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// isRegisteredForReload reports whether the instance reloads on SIGHUP.
+func isRegisteredForReload(b *BlockUserAgents) bool {
+	reloadRegistry.Lock()
+	defer reloadRegistry.Unlock()
+	_, ok := reloadRegistry.instances[b]
+	return ok
+}
+
+// newReloadableHandler builds a middleware reloading the given rules file on
+// SIGHUP, under ctx.
+func newReloadableHandler(t *testing.T, ctx context.Context, rulesFile string, edit func(*Config)) *BlockUserAgents {
+	t.Helper()
+	config := CreateConfig()
+	config.RulesFile = rulesFile
+	config.ReloadOnSignal = true
+	if edit != nil {
+		edit(config)
+	}
+	handler, err := New(ctx, okHandler, config, "test")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	b := handler.(*BlockUserAgents)
+	t.Cleanup(func() { _ = b.Close() })
+	return b
+}
+
+func TestReload(t *testing.T) {
+	rulesFile := writeRules(t, "rules.json", `{"allowedBrowsers": [{"name": "Chrome", "regex": "Chrome/"}]}`)
+	h := newReloadableHandler(t, context.Background(), rulesFile, nil)
+	if rec := serve(h, firefoxUA); rec.Code != http.StatusForbidden {
+		t.Fatalf("status before reload = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+
+	if err := os.WriteFile(rulesFile, []byte(`{"allowedBrowsers": [{"name": "Firefox", "regex": "Firefox/"}]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	h.reload()
+	if rec := serve(h, firefoxUA); rec.Code != http.StatusOK {
+		t.Errorf("status after reload = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	// An invalid file keeps the current rules
+	if err := os.WriteFile(rulesFile, []byte(`{"allowedBrowsers": [{"name": "Bad", "regex": "("}]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	h.reload()
+	if rec := serve(h, firefoxUA); rec.Code != http.StatusOK {
+		t.Errorf("status after a failed reload = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if got := h.Stats().Reloads; got != 1 {
+		t.Errorf("Stats().Reloads = %d, want 1", got)
+	}
+}
+
+func TestReloadStopsWithContext(t *testing.T) {
+	rulesFile := writeRules(t, "rules.json", `{"allowedBrowsers": [{"name": "Chrome", "regex": "Chrome/"}]}`)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	h := newReloadableHandler(t, ctx, rulesFile, func(c *Config) {
+		c.BlockLogFile = filepath.Join(t.TempDir(), "blocked.log")
+	})
+	if !isRegisteredForReload(h) {
+		t.Fatal("instance not registered for SIGHUP")
+	}
+	h.reload()
+	reloaded := h.reloaded.Load()
+	if reloaded == nil {
+		t.Fatal("rules not reloaded")
+	}
+
+	cancel()
+	select {
+	case <-reloaded.blockLog.stop:
+	case <-time.After(time.Second):
+		t.Fatal("reloaded ruleset still writing the block log after the context was cancelled")
+	}
+	deadline := time.Now().Add(time.Second)
+	for isRegisteredForReload(h) {
+		if time.Now().After(deadline) {
+			t.Fatal("instance still registered for SIGHUP after the context was cancelled")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,95 @@
+package traefik_plugin_block_useragents
+
+import (
+	"fmt"
+	"log"
+	"regexp/syntax"
+	"strings"
+)
+
+// ruleRef identifies a browser rule in a named list for analysis reports.
+type ruleRef struct {
+	list string
+	name string
+}
+
+// normalizePattern returns a canonical form of a regex so that patterns
+// written differently but parsing to the same expression compare equal,
+// e.g. "(?:Chrom[e])" and "Chrome". Patterns that do not parse are
+// returned unchanged; compiling them reports the error.
+func normalizePattern(pattern string) string {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return pattern
+	}
+	return re.Simplify().String()
+}
+
+// analyzeRules reports duplicate patterns within a browser list and patterns
+// both allowed and blocked or denied, naming the browsers involved.
+func analyzeRules(config *Config) []string {
+	lists := []struct {
+		name    string
+		entries []BrowserConfig
+	}{
+		{"allowedBrowsers", config.AllowedBrowsers},
+		{"blockedBrowsers", config.BlockedBrowsers},
+		{"denyBrowsers", config.DenyBrowsers},
+		{"softAllowedBrowsers", config.SoftAllowedBrowsers},
+	}
+
+	// Group the rules by normalized pattern, keeping the first-seen order
+	patterns := make([]string, 0)
+	refs := make(map[string][]ruleRef)
+	for _, list := range lists {
+		for _, bc := range list.entries {
+			pattern := normalizePattern(bc.pattern())
+			if _, ok := refs[pattern]; !ok {
+				patterns = append(patterns, pattern)
+			}
+			refs[pattern] = append(refs[pattern], ruleRef{list: list.name, name: bc.Name})
+		}
+	}
+
+	issues := make([]string, 0)
+	for _, pattern := range patterns {
+		byList := make(map[string][]string)
+		for _, ref := range refs[pattern] {
+			byList[ref.list] = append(byList[ref.list], ref.name)
+		}
+		for _, list := range lists {
+			if names := byList[list.name]; len(names) > 1 {
+				issues = append(issues, fmt.Sprintf("duplicate pattern %q in %s: %s", pattern, list.name, strings.Join(names, ", ")))
+			}
+		}
+		allowed := byList["allowedBrowsers"]
+		blocked := make([]string, 0, len(byList["blockedBrowsers"])+len(byList["denyBrowsers"]))
+		blocked = append(blocked, byList["blockedBrowsers"]...)
+		blocked = append(blocked, byList["denyBrowsers"]...)
+		if len(allowed) > 0 && len(blocked) > 0 {
+			issues = append(issues, fmt.Sprintf("conflicting pattern %q: allowed by %s, blocked by %s",
+				pattern, strings.Join(allowed, ", "), strings.Join(blocked, ", ")))
+		}
+	}
+	return issues
+}
+
+// validateRuleConflicts fails on duplicate or conflicting rules with strict
+// validation, and only logs them otherwise.
+func validateRuleConflicts(config *Config) error {
+	issues := analyzeRules(config)
+	if len(issues) == 0 {
+		return nil
+	}
+	if config.StrictValidation {
+		var errs ConfigErrors
+		for _, issue := range issues {
+			errs.add(configErrorf(ErrConflictingSettings, "rule analysis failed: %s", issue))
+		}
+		return errs.err()
+	}
+	for _, issue := range issues {
+		log.Printf("rule analysis: %s", issue)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package traefik_plugin_block_useragents
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAnalyzeRules(t *testing.T) {
+	tests := []struct {
+		name   string
+		config func(*Config)
+		want   []string
+	}{
+		{
+			name: "no issues",
+			config: func(c *Config) {
+				c.AllowedBrowsers = []BrowserConfig{{Name: "Chrome", Regex: "Chrome"}, {Name: "Firefox", Regex: "Firefox"}}
+				c.BlockedBrowsers = []BrowserConfig{{Name: "curl", Regex: "curl"}}
+			},
+			want: []string{},
+		},
+		{
+			name: "duplicate written differently",
+			config: func(c *Config) {
+				c.AllowedBrowsers = []BrowserConfig{{Name: "Chrome", Regex: "Chrome"}, {Name: "Chromium", Regex: "(?:Chrom[e])"}}
+			},
+			want: []string{`duplicate pattern "Chrome" in allowedBrowsers: Chrome, Chromium`},
+		},
+		{
+			name: "allowed and denied",
+			config: func(c *Config) {
+				c.AllowedBrowsers = []BrowserConfig{{Name: "Bot", Regex: "Bot"}}
+				c.BlockedBrowsers = []BrowserConfig{{Name: "Bad bot", Regex: "Bot"}}
+				c.DenyBrowsers = []BrowserConfig{{Name: "Worse bot", Regex: "Bot"}}
+			},
+			want: []string{`conflicting pattern "Bot": allowed by Bot, blocked by Bad bot, Worse bot`},
+		},
+		{
+			name: "soft allowed is no conflict",
+			config: func(c *Config) {
+				c.AllowedBrowsers = []BrowserConfig{{Name: "Chrome", Regex: "Chrome"}}
+				c.SoftAllowedBrowsers = []BrowserConfig{{Name: "Chrome", Regex: "Chrome"}}
+			},
+			want: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := CreateConfig()
+			tt.config(config)
+			if got := analyzeRules(config); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("analyzeRules() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRuleConflictsStrictValidation(t *testing.T) {
+	for _, strict := range []bool{false, true} {
+		config := testConfig()
+		config.BlockedBrowsers = []BrowserConfig{{Name: "Chrome", Regex: `Chrome/\d+`}}
+		config.StrictValidation = strict
+		if err := ValidateConfig(config); (err != nil) != strict {
+			t.Errorf("strictValidation %v: ValidateConfig() = %v", strict, err)
+		}
+	}
+}
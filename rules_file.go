@@ -0,0 +1,227 @@
+package traefik_plugin_block_useragents
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultMaxRulesBytes caps the size of rules fetched from RulesURL.
+const defaultMaxRulesBytes = 1 << 20
+
+// rulesFetchTimeout bounds the time spent fetching rules from RulesURL.
+const rulesFetchTimeout = 10 * time.Second
+
+// rulesFileContent holds the rules that can be loaded from a RulesFile.
+type rulesFileContent struct {
+	AllowedBrowsers []BrowserConfig `json:"allowedBrowsers,omitempty" yaml:"allowedBrowsers,omitempty"`
+	AllowedOSTypes  []string        `json:"allowedOSTypes,omitempty" yaml:"allowedOSTypes,omitempty"`
+}
+
+// parseRules parses rules as YAML when yamlFormat is set, as JSON otherwise.
+func parseRules(data []byte, yamlFormat bool, source string) (*rulesFileContent, error) {
+	rules := &rulesFileContent{}
+	if yamlFormat {
+		if err := yaml.Unmarshal(data, rules); err != nil {
+			return nil, fmt.Errorf("error parsing YAML rules from %q: %w", source, err)
+		}
+		return rules, nil
+	}
+	if err := json.Unmarshal(data, rules); err != nil {
+		return nil, fmt.Errorf("error parsing JSON rules from %q: %w", source, err)
+	}
+	return rules, nil
+}
+
+// isYAMLPath reports whether a file name has a YAML extension.
+func isYAMLPath(name string) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".yaml", ".yml":
+		return true
+	}
+	return false
+}
+
+// loadRulesFile reads a rules file, picking the format from its extension.
+// Files ending in .yaml or .yml are parsed as YAML, everything else as JSON.
+func loadRulesFile(name string) (*rulesFileContent, error) {
+	data, err := os.ReadFile(filepath.Clean(name))
+	if err != nil {
+		return nil, fmt.Errorf("error reading rules file %q: %w", name, err)
+	}
+	return parseRules(data, isYAMLPath(filepath.ToSlash(name)), name)
+}
+
+// errNotModified is returned by download when the server answers a
+// conditional request with 304 Not Modified.
+var errNotModified = errors.New("not modified")
+
+// httpValidators are the cache validators of a downloaded resource, sent
+// back to fetch it again only when it changed.
+type httpValidators struct {
+	etag         string
+	lastModified string
+}
+
+// validatorsOf returns the cache validators of a response.
+func validatorsOf(header http.Header) httpValidators {
+	return httpValidators{etag: header.Get("ETag"), lastModified: header.Get("Last-Modified")}
+}
+
+// fetchedRules are rules downloaded from a RulesURL, with the cache
+// validators of the response.
+type fetchedRules struct {
+	content    *rulesFileContent
+	validators httpValidators
+}
+
+// fetchRules downloads rules from an http(s) URL, reading at most maxBytes.
+func fetchRules(ctx context.Context, rawURL string, maxBytes int) (*fetchedRules, error) {
+	data, header, err := download(ctx, rawURL, maxBytes, httpValidators{})
+	if err != nil {
+		return nil, fmt.Errorf("error fetching rules: %w", err)
+	}
+	return parseFetchedRules(rawURL, data, header)
+}
+
+// parseFetchedRules parses rules downloaded from rawURL. YAML is detected
+// from the Content-Type or the URL path extension.
+func parseFetchedRules(rawURL string, data []byte, header http.Header) (*fetchedRules, error) {
+	yamlFormat := strings.Contains(header.Get("Content-Type"), "yaml") || isYAMLPath(rawURLPath(rawURL))
+	rules, err := parseRules(data, yamlFormat, rawURL)
+	if err != nil {
+		return nil, err
+	}
+	return &fetchedRules{content: rules, validators: validatorsOf(header)}, nil
+}
+
+// download fetches an http(s) URL, reading at most maxBytes of its body.
+// With validators, the request is conditional and errNotModified is returned
+// when the resource did not change.
+func download(ctx context.Context, rawURL string, maxBytes int, validators httpValidators) ([]byte, http.Header, error) {
+	ctx, cancel := context.WithTimeout(ctx, rulesFetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid URL %q: %w", rawURL, err)
+	}
+	if validators.etag != "" {
+		req.Header.Set("If-None-Match", validators.etag)
+	}
+	if validators.lastModified != "" {
+		req.Header.Set("If-Modified-Since", validators.lastModified)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error fetching %q: %w", rawURL, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, resp.Header, errNotModified
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("error fetching %q: unexpected status %s", rawURL, resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, int64(maxBytes)+1))
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading %q: %w", rawURL, err)
+	}
+	if len(data) > maxBytes {
+		return nil, nil, fmt.Errorf("%q exceeds %d bytes", rawURL, maxBytes)
+	}
+	return data, resp.Header, nil
+}
+
+// rawURLPath returns the path of a URL, or "" when it does not parse.
+func rawURLPath(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Path
+}
+
+// validateRulesURL checks the RulesURL settings.
+func validateRulesURL(config *Config) error {
+	var errs ConfigErrors
+	if config.MaxRulesBytes < 0 {
+		errs.add(configErrorf(ErrInvalidValue, "maxRulesBytes must not be negative"))
+	}
+	if config.RulesURL == "" {
+		return errs.err()
+	}
+	u, err := url.Parse(config.RulesURL)
+	switch {
+	case err != nil:
+		errs.add(configErrorf(ErrInvalidValue, "invalid rulesUrl %q: %w", config.RulesURL, err))
+	case u.Scheme != "http" && u.Scheme != "https":
+		errs.add(configErrorf(ErrInvalidValue, "rulesUrl %q must use http or https", config.RulesURL))
+	}
+	return errs.err()
+}
+
+// withRulesFile returns a copy of config with the rules from its RulesFile and
+// RulesURL appended, and the cache validators of the RulesURL response.
+// The RulesURL rules are fetched unless already given as fetched. Loading is
+// skipped with an error once ctx is done. A failed download is only logged
+// when SkipInvalidPatterns is set.
+func withRulesFile(ctx context.Context, config *Config, fetched *fetchedRules) (*Config, httpValidators, error) {
+	var validators httpValidators
+	if config.RulesFile == "" && config.RulesURL == "" {
+		return config, validators, nil
+	}
+	if err := validateRulesURL(config); err != nil {
+		return nil, validators, err
+	}
+	merged := *config
+	merged.AllowedBrowsers = append([]BrowserConfig{}, config.AllowedBrowsers...)
+	merged.AllowedOSTypes = append([]string{}, config.AllowedOSTypes...)
+
+	if config.RulesFile != "" {
+		if err := ctx.Err(); err != nil {
+			return nil, validators, fmt.Errorf("loading rules file %q aborted: %w", config.RulesFile, err)
+		}
+		rules, err := loadRulesFile(config.RulesFile)
+		if err != nil {
+			return nil, validators, err
+		}
+		merged.AllowedBrowsers = append(merged.AllowedBrowsers, rules.AllowedBrowsers...)
+		merged.AllowedOSTypes = append(merged.AllowedOSTypes, rules.AllowedOSTypes...)
+	}
+
+	if config.RulesURL != "" {
+		maxBytes := config.MaxRulesBytes
+		if maxBytes == 0 {
+			maxBytes = defaultMaxRulesBytes
+		}
+		var err error
+		if fetched == nil {
+			fetched, err = fetchRules(ctx, config.RulesURL, maxBytes)
+		}
+		switch {
+		case err == nil:
+			merged.AllowedBrowsers = append(merged.AllowedBrowsers, fetched.content.AllowedBrowsers...)
+			merged.AllowedOSTypes = append(merged.AllowedOSTypes, fetched.content.AllowedOSTypes...)
+			validators = fetched.validators
+		case config.SkipInvalidPatterns:
+			log.Printf("skipping rules from %q: %v", config.RulesURL, err)
+		default:
+			return nil, validators, err
+		}
+	}
+	return &merged, validators, nil
+}
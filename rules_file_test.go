@@ -0,0 +1,295 @@
+package traefik_plugin_block_useragents
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Equivalent rules in both formats.
+const (
+	yamlRules = `
+allowedBrowsers:
+  - name: "Chrome"
+    regex: "Chrome/1[2-9][0-9]"
+  - name: "Firefox"
+    names: ["Firefox"]
+    version: ">= 115"
+allowedOSTypes:
+  - "Windows NT"
+  - "Linux"
+`
+	jsonRules = `{
+  "allowedBrowsers": [
+    {"name": "Chrome", "regex": "Chrome/1[2-9][0-9]"},
+    {"name": "Firefox", "names": ["Firefox"], "version": ">= 115"}
+  ],
+  "allowedOSTypes": ["Windows NT", "Linux"]
+}`
+)
+
+// writeRules writes a rules file in a temporary directory.
+func writeRules(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRulesFileFormats(t *testing.T) {
+	var want *BlockUserAgents
+	for _, tt := range []struct {
+		name    string
+		content string
+	}{
+		{"rules.json", jsonRules},
+		{"rules.yaml", yamlRules},
+		{"rules.YML", yamlRules},
+		{"rules", jsonRules},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			config := CreateConfig()
+			config.RulesFile = writeRules(t, tt.name, tt.content)
+			h := newTestHandler(t, config, nil)
+			if want == nil {
+				want = h
+			}
+			if !reflect.DeepEqual(h.Rules(), want.Rules()) || !reflect.DeepEqual(h.OSRules(), want.OSRules()) {
+				t.Errorf("rules = %q, %q, want %q, %q", h.Rules(), h.OSRules(), want.Rules(), want.OSRules())
+			}
+			if len(h.Rules()) != 2 || len(h.OSRules()) != 2 {
+				t.Errorf("rules = %q, %q, want 2 browsers and 2 OS types", h.Rules(), h.OSRules())
+			}
+			for userAgent, status := range map[string]int{chromeUA: http.StatusOK, firefoxUA: http.StatusOK, safariUA: http.StatusForbidden} {
+				if rec := serve(h, userAgent); rec.Code != status {
+					t.Errorf("%s: status = %d, want %d", userAgent, rec.Code, status)
+				}
+			}
+		})
+	}
+}
+
+func TestRulesFileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"rules.json", `{"allowedBrowsers": [`},
+		{"rules.yaml", "allowedBrowsers:\n  - name: [\n"},
+		{"rules.json", yamlRules},
+		{"rules.yaml", `{"allowedBrowsers": "Chrome"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := CreateConfig()
+			config.RulesFile = writeRules(t, tt.name, tt.content)
+			if _, err := New(context.Background(), okHandler, config, "test"); err == nil {
+				t.Error("New succeeded with an unparsable rules file")
+			}
+		})
+	}
+
+	config := CreateConfig()
+	config.RulesFile = filepath.Join(t.TempDir(), "missing.json")
+	if _, err := New(context.Background(), okHandler, config, "test"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("New() error = %v, want a missing file error", err)
+	}
+}
+
+// versionedServer serves content with an ETag, answering 304 to requests
+// carrying it, and counts both kinds of responses.
+type versionedServer struct {
+	*httptest.Server
+	mu          sync.Mutex
+	content     string
+	version     int
+	full        atomic.Int32
+	notModified atomic.Int32
+}
+
+func newVersionedServer(t *testing.T, content string) *versionedServer {
+	t.Helper()
+	s := &versionedServer{content: content}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		s.mu.Lock()
+		content, etag := s.content, fmt.Sprintf(`"v%d"`, s.version)
+		s.mu.Unlock()
+		if req.Header.Get("If-None-Match") == etag {
+			s.notModified.Add(1)
+			res.WriteHeader(http.StatusNotModified)
+			return
+		}
+		s.full.Add(1)
+		res.Header().Set("ETag", etag)
+		fmt.Fprint(res, content)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// update replaces the content, changing its ETag.
+func (s *versionedServer) update(content string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.content = content
+	s.version++
+}
+
+func TestRulesURLRefresh(t *testing.T) {
+	server := newVersionedServer(t, `{"allowedBrowsers": [{"name": "Firefox", "regex": "Firefox/"}]}`)
+	config := CreateConfig()
+	config.RulesURL = server.URL
+	config.ReloadInterval = "1h"
+	h := newTestHandler(t, config, nil)
+	ctx := context.Background()
+
+	h.refreshRules(ctx, h.rulesWatch)
+	if h.reloaded.Load() != nil || server.notModified.Load() != 1 {
+		t.Fatalf("unchanged rules reloaded (%d not modified responses)", server.notModified.Load())
+	}
+
+	server.update(`{"allowedBrowsers": [{"name": "curl", "regex": "^curl/"}]}`)
+	h.refreshRules(ctx, h.rulesWatch)
+	if h.reloaded.Load() == nil {
+		t.Fatal("changed rules not reloaded")
+	}
+	if got := server.full.Load(); got != 2 {
+		t.Errorf("rules downloaded %d times, want 2 (startup and change)", got)
+	}
+	tests := []struct {
+		userAgent string
+		want      int
+	}{
+		{curlUA, http.StatusOK},
+		{firefoxUA, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		if rec := serve(h, tt.userAgent); rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.userAgent, rec.Code, tt.want)
+		}
+	}
+
+	reloaded := h.reloaded.Load()
+	h.refreshRules(ctx, h.rulesWatch)
+	if h.reloaded.Load() != reloaded || server.notModified.Load() != 2 {
+		t.Error("reloaded rules not checked against their own validators")
+	}
+}
+
+func TestRulesURLRefreshKeepsRulesOnInvalidContent(t *testing.T) {
+	server := newVersionedServer(t, `{"allowedBrowsers": [{"name": "Firefox", "regex": "Firefox/"}]}`)
+	config := CreateConfig()
+	config.RulesURL = server.URL
+	config.ReloadInterval = "1h"
+	config.SkipInvalidPatterns = true
+	h := newTestHandler(t, config, nil)
+
+	server.update(`{"allowedBrowsers": [`)
+	h.refreshRules(context.Background(), h.rulesWatch)
+	if h.reloaded.Load() != nil {
+		t.Fatal("invalid rules reloaded")
+	}
+	if rec := serve(h, firefoxUA); rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestRulesURLRefreshStopsWithContext(t *testing.T) {
+	server := newVersionedServer(t, `{"allowedBrowsers": [{"name": "Firefox", "regex": "Firefox/"}]}`)
+	config := CreateConfig()
+	config.RulesURL = server.URL
+	config.ReloadInterval = "1h"
+	h := newTestHandler(t, config, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		h.refreshRulesEvery(ctx, h.rulesWatch, 10*time.Millisecond)
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("refresh still running after the context was cancelled")
+	}
+	if server.notModified.Load() == 0 || server.full.Load() != 1 {
+		t.Errorf("got %d full and %d not modified responses, want conditional refreshes", server.full.Load(), server.notModified.Load())
+	}
+}
+
+func TestValidateReloadInterval(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  func(*Config)
+		wantErr bool
+	}{
+		{"rules URL", func(c *Config) { c.RulesURL = "https://rules.example.com/rules.json" }, false},
+		{"threat feed", func(c *Config) { c.ThreatFeedURL = "https://feed.example.com/feed.txt" }, false},
+		{"no source", func(*Config) {}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.ReloadInterval = "15m"
+			tt.config(config)
+			if err := ValidateConfig(config); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateConfig() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMaxRulesBytes(t *testing.T) {
+	const content = `{"allowedBrowsers": [{"name": "Firefox", "regex": "Firefox/"}]}`
+	server := newVersionedServer(t, content)
+	tests := []struct {
+		name     string
+		maxBytes int
+		wantErr  bool
+	}{
+		{"default cap", 0, false},
+		{"at the cap", len(content), false},
+		{"over the cap", len(content) - 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := CreateConfig()
+			config.RulesURL = server.URL
+			config.MaxRulesBytes = tt.maxBytes
+			handler, err := New(context.Background(), okHandler, config, "test")
+			if err == nil {
+				defer handler.(*BlockUserAgents).Close()
+			}
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), fmt.Sprintf("exceeds %d bytes", tt.maxBytes)) {
+					t.Errorf("New() error = %v, want the rules to exceed %d bytes", err, tt.maxBytes)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("New() error = %v", err)
+			}
+		})
+	}
+
+	config := CreateConfig()
+	config.RulesURL = server.URL
+	config.MaxRulesBytes = -1
+	if _, err := New(context.Background(), okHandler, config, "test"); !errors.Is(err, ErrInvalidValue) {
+		t.Errorf("New() with a negative maxRulesBytes = %v, want %v", err, ErrInvalidValue)
+	}
+}
@@ -0,0 +1,55 @@
+package traefik_plugin_block_useragents
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Schemes accepted by Config.RequireScheme.
+const (
+	SchemeHTTP  = "http"
+	SchemeHTTPS = "https"
+)
+
+// validateScheme checks the scheme requirement settings.
+func validateScheme(config *Config) error {
+	var errs ConfigErrors
+	switch config.RequireScheme {
+	case "", SchemeHTTP, SchemeHTTPS:
+	default:
+		errs.add(configErrorf(ErrInvalidValue, "invalid requireScheme %q", config.RequireScheme))
+	}
+	switch config.SchemeAction {
+	case "", ActionBlock, ActionRedirect:
+	default:
+		errs.add(configErrorf(ErrInvalidValue, "invalid schemeAction %q", config.SchemeAction))
+	}
+	return errs.err()
+}
+
+// requestScheme returns the scheme the client used. X-Forwarded-Proto takes
+// precedence when forwarded headers are trusted, so requests behind a
+// TLS-terminating proxy are seen as HTTPS.
+func (b *BlockUserAgents) requestScheme(req *http.Request) string {
+	if proto := req.Header.Get("X-Forwarded-Proto"); proto != "" && b.trustsForwardedHeaders(req) {
+		first, _, _ := strings.Cut(proto, ",")
+		return strings.ToLower(strings.TrimSpace(first))
+	}
+	if req.TLS != nil {
+		return SchemeHTTPS
+	}
+	return SchemeHTTP
+}
+
+// checkScheme blocks, or redirects to the required scheme, requests made
+// with another scheme. It returns nil when the scheme is accepted.
+func (b *BlockUserAgents) checkScheme(req *http.Request) *decision {
+	if b.requireScheme == "" || b.requestScheme(req) == b.requireScheme {
+		return nil
+	}
+	if b.schemeAction == ActionRedirect {
+		target := b.requireScheme + "://" + req.Host + req.URL.RequestURI()
+		return &decision{reason: "Scheme Redirect", status: http.StatusPermanentRedirect, redirectURL: target}
+	}
+	return blockDecision("Scheme Mismatch")
+}
@@ -0,0 +1,74 @@
+package traefik_plugin_block_useragents
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestRequireHTTPS(t *testing.T) {
+	tests := []struct {
+		name           string
+		trustForwarded bool
+		trustedProxies []string
+		edits          []func(*http.Request)
+		want           int
+	}{
+		{"plain HTTP", false, nil, nil, http.StatusForbidden},
+		{"TLS connection", false, nil, []func(*http.Request){withTLS}, http.StatusOK},
+		{"untrusted X-Forwarded-Proto", false, nil, []func(*http.Request){withHeader("X-Forwarded-Proto", "https")}, http.StatusForbidden},
+		{"trusted X-Forwarded-Proto", true, nil, []func(*http.Request){withHeader("X-Forwarded-Proto", "https")}, http.StatusOK},
+		{"trusted X-Forwarded-Proto http", true, nil, []func(*http.Request){withTLS, withHeader("X-Forwarded-Proto", "http")}, http.StatusForbidden},
+		{"X-Forwarded-Proto from untrusted proxy", true, []string{"10.0.0.0/8"}, []func(*http.Request){withHeader("X-Forwarded-Proto", "https")}, http.StatusForbidden},
+		{"X-Forwarded-Proto from trusted proxy", true, []string{"192.0.2.0/24"}, []func(*http.Request){withHeader("X-Forwarded-Proto", "https")}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.RequireScheme = SchemeHTTPS
+			config.TrustForwardedHeader = tt.trustForwarded
+			config.TrustedProxies = tt.trustedProxies
+			h := newTestHandler(t, config, nil)
+			if rec := serve(h, chromeUA, tt.edits...); rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchemeAction(t *testing.T) {
+	tests := []struct {
+		name         string
+		action       string
+		want         int
+		wantLocation string
+	}{
+		{"block by default", "", http.StatusForbidden, ""},
+		{"block", ActionBlock, http.StatusForbidden, ""},
+		{"redirect", ActionRedirect, http.StatusPermanentRedirect, "https://example.com/path?q=1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.RequireScheme = SchemeHTTPS
+			config.SchemeAction = tt.action
+			h := newTestHandler(t, config, nil)
+			rec := serve(h, chromeUA, func(req *http.Request) {
+				req.URL.Path, req.URL.RawQuery = "/path", "q=1"
+			})
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if got := rec.Header().Get("Location"); got != tt.wantLocation {
+				t.Errorf("Location = %q, want %q", got, tt.wantLocation)
+			}
+		})
+	}
+
+	config := testConfig()
+	config.RequireScheme = SchemeHTTPS
+	config.SchemeAction = "upgrade"
+	if err := ValidateConfig(config); !errors.Is(err, ErrInvalidValue) {
+		t.Errorf("ValidateConfig with schemeAction upgrade = %v, want %v", err, ErrInvalidValue)
+	}
+}
@@ -0,0 +1,77 @@
+package traefik_plugin_block_useragents
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ScoreRule adds Weight to the score of requests whose User-Agent matches Regex.
+type ScoreRule struct {
+	Name   string `json:"name,omitempty"`   // Rule name used in logs
+	Regex  string `json:"regex,omitempty"`  // Required: Regex pattern matching the User-Agent
+	Weight int    `json:"weight,omitempty"` // Required: Score added on a match, may be negative
+}
+
+// scoreRule is a compiled ScoreRule.
+type scoreRule struct {
+	name   string
+	re     *regexp.Regexp
+	weight int
+}
+
+// validateScoreRules checks the score rules.
+func validateScoreRules(rules []ScoreRule) error {
+	var errs ConfigErrors
+	for _, rule := range rules {
+		if rule.Regex == "" {
+			errs.add(configErrorf(ErrMissingRegex, "regex must be provided for score rule: %s", rule.Name))
+		}
+		if rule.Weight == 0 {
+			errs.add(configErrorf(ErrInvalidValue, "weight must not be zero for score rule: %s", rule.Name))
+		}
+	}
+	return errs.err()
+}
+
+// compileScoreRules compiles the score rules.
+func compileScoreRules(rules []ScoreRule) ([]scoreRule, error) {
+	compiled := make([]scoreRule, 0, len(rules))
+	for _, rule := range rules {
+		re, err := compileRegexp(rule.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("error compiling score regex for %s: %w", rule.Name, err)
+		}
+		name := rule.Name
+		if name == "" {
+			name = rule.Regex
+		}
+		compiled = append(compiled, scoreRule{name: name, re: re, weight: rule.Weight})
+	}
+	return compiled, nil
+}
+
+// checkScore sums the weights of the matching score rules and blocks the
+// request when the total falls below the threshold. The reason lists the
+// score and the rules that contributed to it.
+func (b *BlockUserAgents) checkScore(e *evaluation) *decision {
+	if len(b.scoreRules) == 0 {
+		return nil
+	}
+	score := 0
+	contributors := make([]string, 0)
+	for _, rule := range b.scoreRules {
+		if matchesAny(rule.re, e.userAgents) {
+			score += rule.weight
+			contributors = append(contributors, fmt.Sprintf("%s %+d", rule.name, rule.weight))
+		}
+	}
+	if score >= b.scoreThreshold {
+		return nil
+	}
+	reason := fmt.Sprintf("Low Score: %d", score)
+	if len(contributors) > 0 {
+		reason += " (" + strings.Join(contributors, ", ") + ")"
+	}
+	return blockDecision(reason)
+}
@@ -0,0 +1,45 @@
+package traefik_plugin_block_useragents
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestScoreRules(t *testing.T) {
+	const (
+		headlessUA = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) HeadlessChrome/131.0.0.0 Safari/537.36"
+		bareUA     = "Mozilla/5.0 (X11; Linux x86_64) Chrome/131.0.0.0"
+	)
+	tests := []struct {
+		name       string
+		threshold  int
+		userAgent  string
+		wantReason string // Empty when allowed
+	}{
+		{"positive weights summed", 6, chromeUA, ""},
+		{"negative weight subtracted", 0, headlessUA, "Low Score: -3 (Windows +5, Headless -10, Safari/ +2)"},
+		{"at the threshold", 7, chromeUA, ""},
+		{"below the threshold", 8, chromeUA, "Low Score: 7 (Windows +5, Safari/ +2)"},
+		{"no matching rule", 1, bareUA, "Low Score: 0"},
+		{"no matching rule at a zero threshold", 0, bareUA, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.ScoreRules = []ScoreRule{
+				{Name: "Windows", Regex: `Windows`, Weight: 5},
+				{Name: "Headless", Regex: `Headless`, Weight: -10},
+				{Regex: `Safari/`, Weight: 2}, // Named after its regex
+			}
+			config.ScoreThreshold = tt.threshold
+			h := newTestHandler(t, config, nil)
+
+			req := httptest.NewRequest("GET", "http://example.com/", nil)
+			req.Header.Set("User-Agent", tt.userAgent)
+			d := h.evaluate(req)
+			if d.allowed != (tt.wantReason == "") || d.reason != tt.wantReason {
+				t.Errorf("evaluate = (allowed %v, reason %q), want reason %q", d.allowed, d.reason, tt.wantReason)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package traefik_plugin_block_useragents
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// shadowConfig returns the configuration of the shadow ruleset: the top-level
+// settings with the shadow browsers and OS types in place of the allowed
+// ones. An empty shadow list keeps the corresponding top-level list.
+func shadowConfig(config *Config) *Config {
+	derived := *config
+	if len(config.ShadowBrowsers) > 0 {
+		derived.AllowedBrowsers = config.ShadowBrowsers
+	}
+	if len(config.ShadowOSTypes) > 0 {
+		derived.AllowedOSTypes = config.ShadowOSTypes
+	}
+	derived.ShadowBrowsers = nil
+	derived.ShadowOSTypes = nil
+	derived.Policies = nil
+	derived.HostPolicyMap = nil
+	derived.DefaultPolicy = ""
+	derived.RulesFile = "" // Already merged into the top-level config
+	derived.RulesURL = ""
+	derived.BrowserSpecs = nil // Already merged into allowedBrowsers
+	derived.ThreatFeedURL = ""
+	derived.ReloadInterval = ""
+	derived.BlockLogFile = ""
+	derived.BlockLogMaxBytes = 0
+	derived.WebhookURL = ""
+	derived.WebhookAuthHeader = ""
+	derived.LearnMode = false
+	derived.LearnInterval = ""
+	derived.LearnFile = ""
+	derived.ReloadOnSignal = false
+	derived.SelfTestUserAgents = nil
+	derived.Expvar = false // The shadow ruleset never responds
+	derived.DistinctBlockAlertThreshold = 0
+	derived.DistinctBlockAlertWindow = ""
+	derived.EnforcementDelay = ""
+	return &derived
+}
+
+// hasShadow reports whether a shadow ruleset is configured.
+func hasShadow(config *Config) bool {
+	return len(config.ShadowBrowsers) > 0 || len(config.ShadowOSTypes) > 0
+}
+
+// validateShadow checks the shadow ruleset.
+func validateShadow(config *Config) error {
+	if !hasShadow(config) {
+		return nil
+	}
+	return prefixErrors("shadow ruleset", ValidateConfig(shadowConfig(config)))
+}
+
+// newShadow creates the plugin instance evaluating the shadow ruleset.
+func (b *BlockUserAgents) newShadow(ctx context.Context, next http.Handler, config *Config) error {
+	if !hasShadow(config) {
+		return nil
+	}
+	handler, err := New(ctx, next, shadowConfig(config), b.name+".shadow")
+	if err != nil {
+		return fmt.Errorf("shadow ruleset: %w", err)
+	}
+	b.shadow = handler.(*BlockUserAgents)
+	return nil
+}
+
+// compareShadow evaluates the request against the shadow ruleset and logs a
+// Shadow-Diff event when its decision differs from the active one.
+func (b *BlockUserAgents) compareShadow(req *http.Request, active decision) {
+	if b.shadow == nil {
+		return
+	}
+	shadow := b.shadow.cachedEvaluate(req)
+	if shadow.allowed == active.allowed && shadow.reason == active.reason {
+		return
+	}
+	b.logEvent(req, "Shadow-Diff", "active="+describeDecision(active)+", shadow="+describeDecision(shadow), 0)
+}
+
+// describeDecision renders a decision for the Shadow-Diff log.
+func describeDecision(d decision) string {
+	if d.allowed {
+		return "allow"
+	}
+	if d.challenge {
+		return "challenge"
+	}
+	return "block (" + d.reason + ")"
+}
@@ -0,0 +1,65 @@
+package traefik_plugin_block_useragents
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestShadowRuleset(t *testing.T) {
+	tests := []struct {
+		name      string
+		userAgent string
+		want      int
+		wantDiff  string // Empty when the rulesets agree
+	}{
+		{"shadow blocks an allowed request", chromeUA, http.StatusOK, "Shadow-Diff (active=allow, shadow=block (Unsupported Browser))"},
+		{"shadow allows a blocked request", firefoxUA, http.StatusForbidden, "Shadow-Diff (active=block (Unsupported Browser), shadow=allow)"},
+		{"agreement", curlUA, http.StatusForbidden, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.ShadowBrowsers = []BrowserConfig{{Name: "Firefox", Regex: `Firefox/\d+`}}
+			h := newTestHandler(t, config, nil)
+			logs := captureLog(t)
+
+			if rec := serve(h, tt.userAgent); rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+			got := logs.String()
+			if tt.wantDiff == "" {
+				if strings.Contains(got, "Shadow-Diff") {
+					t.Errorf("log = %q, want no Shadow-Diff", got)
+				}
+				return
+			}
+			if !strings.Contains(got, tt.wantDiff) {
+				t.Errorf("log = %q, want %q", got, tt.wantDiff)
+			}
+		})
+	}
+}
+
+func TestShadowOSTypes(t *testing.T) {
+	config := testConfig()
+	config.AllowedOSTypes = []string{`Windows NT`, `Linux`}
+	config.ShadowOSTypes = []string{`Windows NT`}
+	h := newTestHandler(t, config, nil)
+	logs := captureLog(t)
+
+	const linuxChromeUA = "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.0.0 Safari/537.36"
+	if rec := serve(h, linuxChromeUA); rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	// The shadow ruleset keeps the top-level browsers
+	if rec := serve(h, chromeUA); rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if got := strings.Count(logs.String(), "Shadow-Diff"); got != 1 {
+		t.Errorf("log = %q, want one Shadow-Diff", logs.String())
+	}
+	if want := "Shadow-Diff (active=allow, shadow=block (Unsupported OS))"; !strings.Contains(logs.String(), want) {
+		t.Errorf("log = %q, want %q", logs.String(), want)
+	}
+}
@@ -0,0 +1,47 @@
+package traefik_plugin_block_useragents
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// defaultSNIHeader is the header consulted for the SNI sent by the client
+// to an upstream TLS terminator.
+const defaultSNIHeader = "X-Forwarded-SNI"
+
+// normalizeServerName lowercases a host name and strips its port and
+// trailing dot, so "Example.com.:443" compares equal to "example.com".
+func normalizeServerName(name string) string {
+	if host, _, err := net.SplitHostPort(name); err == nil {
+		name = host
+	}
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), ".")
+}
+
+// checkSNIMatch blocks requests whose Host differs from the SNI of the TLS
+// connection, a sign of domain fronting. The SNI of the connection is used
+// when TLS terminates here; otherwise the SNI header is read when forwarded
+// headers are trusted. Requests without an SNI pass unless blockMissingSNI
+// is set. It returns nil when the request passes.
+func (b *BlockUserAgents) checkSNIMatch(req *http.Request) *decision {
+	if !b.checkSNIMatchEnabled {
+		return nil
+	}
+	sni := ""
+	if req.TLS != nil {
+		sni = req.TLS.ServerName
+	} else if b.trustsForwardedHeaders(req) {
+		sni = req.Header.Get(b.sniHeader)
+	}
+	if sni == "" {
+		if b.blockMissingSNI {
+			return blockDecision("SNI Mismatch")
+		}
+		return nil
+	}
+	if normalizeServerName(sni) != normalizeServerName(req.Host) {
+		return blockDecision("SNI Mismatch")
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package traefik_plugin_block_useragents
+
+import (
+	"crypto/tls"
+	"net/http"
+	"testing"
+)
+
+// withSNI returns an edit marking the request as received over TLS for the
+// given server name.
+func withSNI(serverName string) func(*http.Request) {
+	return func(req *http.Request) {
+		req.TLS = &tls.ConnectionState{Version: tls.VersionTLS13, ServerName: serverName}
+	}
+}
+
+func TestCheckSNIMatch(t *testing.T) {
+	tests := []struct {
+		name         string
+		blockMissing bool
+		trustHeader  bool
+		sniHeader    string
+		edits        []func(*http.Request)
+		want         int
+	}{
+		{"matching SNI", false, false, "", []func(*http.Request){withSNI("example.com")}, http.StatusOK},
+		{"mismatching SNI", false, false, "", []func(*http.Request){withSNI("other.example")}, http.StatusForbidden},
+		{"host with a port", false, false, "", []func(*http.Request){withHost("Example.com:8443"), withSNI("example.com.")}, http.StatusOK},
+		{"mismatching host with a port", false, false, "", []func(*http.Request){withHost("other.example:8443"), withSNI("example.com")}, http.StatusForbidden},
+		{"missing SNI allowed", false, false, "", nil, http.StatusOK},
+		{"missing SNI blocked", true, false, "", nil, http.StatusForbidden},
+		{"empty TLS SNI blocked", true, false, "", []func(*http.Request){withSNI("")}, http.StatusForbidden},
+		{"forwarded SNI matching", false, true, "", []func(*http.Request){withHeader("X-Forwarded-SNI", "example.com")}, http.StatusOK},
+		{"forwarded SNI mismatching", false, true, "", []func(*http.Request){withHeader("X-Forwarded-SNI", "other.example")}, http.StatusForbidden},
+		{"forwarded SNI ignored when untrusted", false, false, "", []func(*http.Request){withHeader("X-Forwarded-SNI", "other.example")}, http.StatusOK},
+		{"custom SNI header", false, true, "X-SNI", []func(*http.Request){withHeader("X-SNI", "other.example"), withHeader("X-Forwarded-SNI", "example.com")}, http.StatusForbidden},
+		{"custom SNI header missing", true, true, "X-SNI", []func(*http.Request){withHeader("X-Forwarded-SNI", "example.com")}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.CheckSNIMatch = true
+			config.BlockMissingSNI = tt.blockMissing
+			config.TrustForwardedHeader = tt.trustHeader
+			config.SNIHeader = tt.sniHeader
+			h := newTestHandler(t, config, nil)
+
+			rec := serve(h, chromeUA, tt.edits...)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
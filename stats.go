@@ -0,0 +1,128 @@
+package traefik_plugin_block_useragents
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Stats is a snapshot of the request counters of a middleware, see Stats.
+type Stats struct {
+	Allowed      uint64            `json:"allowed"`      // Requests forwarded after the User-Agent checks
+	Blocked      map[string]uint64 `json:"blocked"`      // Blocked requests by reason
+	CacheHits    uint64            `json:"cacheHits"`    // Decision cache hits
+	CacheMisses  uint64            `json:"cacheMisses"`  // Decision cache misses
+	EvalTimeouts uint64            `json:"evalTimeouts"` // Evaluations abandoned after matchTimeout
+	Reloads      uint64            `json:"reloads"`      // Successful reloads on SIGHUP
+	LastReload   time.Time         `json:"lastReload"`   // Time of the last successful reload, zero before any
+
+	Labels map[string]LabelStats `json:"labels,omitempty"` // Requests decided by the labeled browser rules, by label
+}
+
+// LabelStats counts the requests decided by the browser rules sharing a label.
+type LabelStats struct {
+	Allowed uint64 `json:"allowed"`
+	Blocked uint64 `json:"blocked"`
+}
+
+// requestStats holds the request counters behind Stats. It is shared with
+// the policies and the rulesets reloaded on SIGHUP, so the counts cover
+// every request served through the middleware.
+type requestStats struct {
+	mu         sync.Mutex
+	allowed    uint64
+	blocked    map[string]uint64
+	reloads    uint64
+	lastReload time.Time
+	labels     map[string]LabelStats
+}
+
+// newRequestStats returns zeroed request counters.
+func newRequestStats() *requestStats {
+	return &requestStats{blocked: make(map[string]uint64), labels: make(map[string]LabelStats)}
+}
+
+// allowedRequest counts an allowed request.
+func (s *requestStats) allowedRequest() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.allowed++
+}
+
+// blockedRequest counts a blocked request by reason.
+func (s *requestStats) blockedRequest(reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.blocked[reason]++
+}
+
+// labeled counts a request allowed or blocked by a labeled rule. Labels
+// only come from the configured rules, which bounds the map.
+func (s *requestStats) labeled(label string, allowed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	counts := s.labels[label]
+	if allowed {
+		counts.Allowed++
+	} else {
+		counts.Blocked++
+	}
+	s.labels[label] = counts
+}
+
+// reloadedAt counts a successful reload.
+func (s *requestStats) reloadedAt(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reloads++
+	s.lastReload = now
+}
+
+// Stats returns a copy of the request counters, for Go callers embedding the
+// plugin. The request and reload counters are copied together under a lock;
+// the cache and timeout counters come from the ruleset currently serving
+// requests and are read right after.
+func (b *BlockUserAgents) Stats() Stats {
+	b.stats.mu.Lock()
+	stats := Stats{
+		Allowed:    b.stats.allowed,
+		Blocked:    make(map[string]uint64, len(b.stats.blocked)),
+		Reloads:    b.stats.reloads,
+		LastReload: b.stats.lastReload,
+	}
+	for reason, count := range b.stats.blocked {
+		stats.Blocked[reason] = count
+	}
+	if len(b.stats.labels) > 0 {
+		stats.Labels = make(map[string]LabelStats, len(b.stats.labels))
+		for label, counts := range b.stats.labels {
+			stats.Labels[label] = counts
+		}
+	}
+	b.stats.mu.Unlock()
+
+	active := b
+	if reloaded := b.reloaded.Load(); reloaded != nil {
+		active = reloaded
+	}
+	stats.CacheHits, stats.CacheMisses = active.CacheStats()
+	stats.EvalTimeouts = active.EvalTimeouts()
+	return stats
+}
+
+// ruleLabels returns the distinct labels of the rules, sorted.
+func ruleLabels(lists ...[]browserRule) []string {
+	seen := make(map[string]struct{})
+	labels := make([]string, 0)
+	for _, rules := range lists {
+		for _, rule := range rules {
+			if _, ok := seen[rule.label]; ok || rule.label == "" {
+				continue
+			}
+			seen[rule.label] = struct{}{}
+			labels = append(labels, rule.label)
+		}
+	}
+	sort.Strings(labels)
+	return labels
+}
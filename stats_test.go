@@ -0,0 +1,33 @@
+package traefik_plugin_block_useragents
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	config := testConfig()
+	config.CacheSize = 8
+	h := newTestHandler(t, config, nil)
+
+	serve(h, chromeUA)
+	serve(h, chromeUA)
+	serve(h, curlUA)
+
+	stats := h.Stats()
+	if stats.Allowed != 2 || stats.CacheHits != 1 || stats.CacheMisses != 2 {
+		t.Errorf("Stats() = %d allowed, %d cache hits, %d misses, want 2, 1, 2", stats.Allowed, stats.CacheHits, stats.CacheMisses)
+	}
+	if want := map[string]uint64{"Unsupported Browser": 1}; !reflect.DeepEqual(stats.Blocked, want) {
+		t.Errorf("Stats().Blocked = %v, want %v", stats.Blocked, want)
+	}
+	if stats.Reloads != 0 || !stats.LastReload.IsZero() {
+		t.Errorf("Stats() = %d reloads at %v, want none", stats.Reloads, stats.LastReload)
+	}
+
+	// The snapshot is a copy.
+	stats.Blocked["Unsupported Browser"] = 10
+	if got := h.Stats().Blocked["Unsupported Browser"]; got != 1 {
+		t.Errorf("Stats().Blocked after editing a snapshot = %d, want 1", got)
+	}
+}
@@ -0,0 +1,126 @@
+package traefik_plugin_block_useragents
+
+import (
+	"net/http"
+	"strings"
+)
+
+// blockReasons lists the block reasons accepted as Config.StatusByReason and
+// Config.MessagesByReason keys.
+// Reasons naming a rule, such as "Blocked Browser: <name>", are keyed by the
+// part before the colon.
+var blockReasons = map[string]struct{}{
+	"No User-Agent":             {},
+	"Blocked Browser":           {},
+	"Denied Browser":            {},
+	"Unsupported Browser":       {},
+	"Blocked Exception":         {},
+	"Banned OS":                 {},
+	"Unsupported OS":            {},
+	"Unsupported OS Version":    {},
+	"Unsupported Brand Version": {},
+	"Unsupported Fingerprint":   {},
+	"Disallowed Origin":         {},
+	"No Accept-Language":        {},
+	"Unsupported Language":      {},
+	"Denied IP":                 {},
+	"Denied Rule":               {},
+	"Default Deny":              {},
+	"Scheme Mismatch":           {},
+	"Low Score":                 {},
+	"Insufficient TLS":          {},
+	"SNI Mismatch":              {},
+	"Obsolete HTTP Version":     {},
+	"Eval Timeout":              {},
+	"Eval Error":                {},
+	"Missing Required Header":   {},
+	"Forbidden Header":          {},
+	"Threat Feed":               {},
+	"Empty Config":              {},
+	"Implausible UA":            {},
+	"Too Few UA Segments":       {},
+}
+
+// countedReasons lists the reasons, besides blockReasons, that requests
+// turned away for other causes are counted under.
+var countedReasons = map[string]struct{}{
+	"Maintenance":        {},
+	"Rate Limited":       {},
+	"Redirected Browser": {},
+	"Scheme Redirect":    {},
+	"Challenged":         {},
+	"Decision Hook":      {},
+}
+
+// otherReason counts the blocked requests whose reason is not a known one,
+// such as the free-form reasons of a DecisionHook.
+const otherReason = "Other"
+
+// reasonCategory returns the reason a blocked request is counted under: the
+// reason without its rule name, or otherReason for unknown reasons, so the
+// number of counters stays bounded.
+func reasonCategory(reason string) string {
+	category, _, _ := strings.Cut(reason, ":")
+	if _, ok := blockReasons[category]; ok {
+		return category
+	}
+	if _, ok := countedReasons[category]; ok {
+		return category
+	}
+	return otherReason
+}
+
+// validateStatusCodes checks the block status code, the per-reason codes and
+// the per-reason messages.
+func validateStatusCodes(config *Config) error {
+	var errs ConfigErrors
+	if config.BlockStatusCode != 0 && !isErrorStatus(config.BlockStatusCode) {
+		errs.add(configErrorf(ErrInvalidStatusCode, "blockStatusCode must be a 4xx or 5xx status, got %d", config.BlockStatusCode))
+	}
+	for reason, status := range config.StatusByReason {
+		if _, ok := blockReasons[reason]; !ok {
+			errs.add(configErrorf(ErrUnknownReason, "unknown block reason %q in statusByReason", reason))
+		}
+		if !isErrorStatus(status) {
+			errs.add(configErrorf(ErrInvalidStatusCode, "statusByReason %q must be a 4xx or 5xx status, got %d", reason, status))
+		}
+	}
+	for reason := range config.MessagesByReason {
+		if _, ok := blockReasons[reason]; !ok {
+			errs.add(configErrorf(ErrUnknownReason, "unknown block reason %q in messagesByReason", reason))
+		}
+	}
+	return errs.err()
+}
+
+// isErrorStatus reports whether status is a 4xx or 5xx status code.
+func isErrorStatus(status int) bool {
+	return status >= 400 && status <= 599
+}
+
+// blockStatus returns the response status for a block reason.
+func (b *BlockUserAgents) blockStatus(reason string) int {
+	if status, ok := b.statusByReason[reason]; ok {
+		return status
+	}
+	if prefix, _, found := strings.Cut(reason, ":"); found {
+		if status, ok := b.statusByReason[prefix]; ok {
+			return status
+		}
+	}
+	if b.blockStatusCode != 0 {
+		return b.blockStatusCode
+	}
+	return http.StatusForbidden
+}
+
+// blockMessage returns the configured message for a block reason, or "".
+func (b *BlockUserAgents) blockMessage(reason string) string {
+	if message, ok := b.messagesByReason[reason]; ok {
+		return message
+	}
+	if prefix, _, found := strings.Cut(reason, ":"); found {
+		return b.messagesByReason[prefix]
+	}
+	return ""
+}
@@ -0,0 +1,142 @@
+package traefik_plugin_block_useragents
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestBlockStatus(t *testing.T) {
+	tests := []struct {
+		name      string
+		userAgent string
+		want      int
+	}{
+		{"per-reason status", "", http.StatusBadRequest},
+		{"per-reason status of a named rule", "BadBot/1.0 " + chromeUA, http.StatusTeapot},
+		{"block status", curlUA, http.StatusTooManyRequests},
+		{"allowed", chromeUA, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.BlockedBrowsers = []BrowserConfig{{Name: "BadBot", Regex: "BadBot/"}}
+			config.BlockStatusCode = http.StatusTooManyRequests
+			config.StatusByReason = map[string]int{"No User-Agent": http.StatusBadRequest, "Blocked Browser": http.StatusTeapot}
+			h := newTestHandler(t, config, nil)
+			if rec := serve(h, tt.userAgent); rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+// OVERRIDE key=TestValidateStatusCodes file=status_test.go from=e4e3d1e until=end
+func TestValidateStatusCodes(t *testing.T) {
+	config := testConfig()
+	config.BlockStatusCode = http.StatusOK
+	if err := ValidateConfig(config); !errors.Is(err, ErrInvalidStatusCode) {
+		t.Errorf("ValidateConfig with blockStatusCode 200 = %v, want %v", err, ErrInvalidStatusCode)
+	}
+
+	config = testConfig()
+	config.StatusByReason = map[string]int{"No User-Agent": http.StatusFound}
+	if err := ValidateConfig(config); !errors.Is(err, ErrInvalidStatusCode) {
+		t.Errorf("ValidateConfig with a 302 reason status = %v, want %v", err, ErrInvalidStatusCode)
+	}
+
+	config = testConfig()
+	config.StatusByReason = map[string]int{"Bad Vibes": http.StatusForbidden}
+	if err := ValidateConfig(config); !errors.Is(err, ErrUnknownReason) {
+		t.Errorf("ValidateConfig with an unknown reason = %v, want %v", err, ErrUnknownReason)
+	}
+}
+
+func TestMessagesByReason(t *testing.T) {
+	config := testConfig()
+	config.DenyBrowsers = []BrowserConfig{{Name: "BadBot", Regex: "BadBot/"}}
+	config.MessagesByReason = map[string]string{
+		"Unsupported Browser": "Please update your browser.",
+		"Denied Browser":      "Go away.",
+	}
+	config.StatusByReason = map[string]int{"Denied Browser": http.StatusNotFound}
+	h := newTestHandler(t, config, nil)
+
+	tests := []struct {
+		name       string
+		userAgent  string
+		wantStatus int
+		wantBody   string
+	}{
+		{"exact reason", curlUA, http.StatusForbidden, "Please update your browser."},
+		{"reason naming a rule", chromeUA + " BadBot/1.0", http.StatusNotFound, "Go away."},
+		{"reason without message", "", http.StatusForbidden, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(h, tt.userAgent)
+			if rec.Code != tt.wantStatus || rec.Body.String() != tt.wantBody {
+				t.Errorf("response = %d %q, want %d %q", rec.Code, rec.Body, tt.wantStatus, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestValidateMessagesByReason(t *testing.T) {
+	config := testConfig()
+	config.MessagesByReason = map[string]string{"Unsupported Browsers": "typo"}
+	if err := ValidateConfig(config); !errors.Is(err, ErrUnknownReason) {
+		t.Errorf("ValidateConfig() = %v, want ErrUnknownReason", err)
+	}
+}
+
+func TestReasonCategory(t *testing.T) {
+	tests := []struct {
+		reason string
+		want   string
+	}{
+		{"Unsupported Browser", "Unsupported Browser"},
+		{"Blocked Browser: BadBot", "Blocked Browser"},
+		{"Rate Limited: crawlers", "Rate Limited"},
+		{"Maintenance", "Maintenance"},
+		{"Decision Hook", "Decision Hook"},
+		{"tenant 42 over quota", otherReason},
+		{"Custom: reason", otherReason},
+	}
+	for _, tt := range tests {
+		if got := reasonCategory(tt.reason); got != tt.want {
+			t.Errorf("reasonCategory(%q) = %q, want %q", tt.reason, got, tt.want)
+		}
+	}
+}
+
+func TestBlockedCountsByCategory(t *testing.T) {
+	config := testConfig()
+	config.BlockedBrowsers = []BrowserConfig{{Name: "BadBot", Regex: "BadBot/"}}
+	config.Expvar = true
+	h := newTestHandler(t, config, nil)
+	h.DecisionHook = func(req *http.Request, allow bool) (bool, string) {
+		if req.Header.Get("X-Tenant") != "" {
+			return false, "tenant " + req.Header.Get("X-Tenant")
+		}
+		return allow, ""
+	}
+
+	serve(h, "BadBot/1.0 "+chromeUA)
+	serve(h, chromeUA, withHeader("X-Tenant", "1"))
+	serve(h, chromeUA, withHeader("X-Tenant", "2"))
+
+	want := map[string]uint64{"Blocked Browser": 1, otherReason: 2}
+	if got := h.Stats().Blocked; !reflect.DeepEqual(got, want) {
+		t.Errorf("Stats().Blocked = %v, want %v", got, want)
+	}
+	for _, key := range []string{"Blocked Browser: BadBot", "tenant 1"} {
+		if h.expvar.blocked.Get(key) != nil {
+			t.Errorf("expvar blocked counter published for %q", key)
+		}
+	}
+	if h.expvar.blocked.Get(otherReason) == nil {
+		t.Errorf("expvar blocked counter missing for %q", otherReason)
+	}
+}
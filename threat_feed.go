@@ -0,0 +1,183 @@
+package traefik_plugin_block_useragents
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxThreatFeedPatterns bounds the number of patterns compiled from a threat feed.
+const maxThreatFeedPatterns = 1000
+
+// threatFeed holds the User-Agent patterns fetched from ThreatFeedURL and
+// refreshes them every interval with conditional requests. A failed fetch, or
+// one answered with 304 Not Modified, keeps the current patterns.
+type threatFeed struct {
+	name       string
+	url        string
+	maxBytes   int
+	validators httpValidators // Of the last download, only used by refresh
+
+	patterns atomic.Pointer[[]*regexp.Regexp]
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// validateThreatFeed checks the threat feed settings.
+func validateThreatFeed(config *Config) error {
+	var errs ConfigErrors
+	if config.ReloadInterval != "" {
+		if config.ThreatFeedURL == "" && config.RulesURL == "" {
+			errs.add(configErrorf(ErrMissingSetting, "reloadInterval requires threatFeedUrl or rulesUrl"))
+		}
+		interval, err := time.ParseDuration(config.ReloadInterval)
+		switch {
+		case err != nil:
+			errs.add(configErrorf(ErrInvalidValue, "invalid reloadInterval %q: %w", config.ReloadInterval, err))
+		case interval < time.Second:
+			errs.add(configErrorf(ErrInvalidValue, "reloadInterval must be at least 1s, got %s", interval))
+		}
+	}
+	if config.ThreatFeedURL == "" {
+		return errs.err()
+	}
+	u, err := url.Parse(config.ThreatFeedURL)
+	switch {
+	case err != nil:
+		errs.add(configErrorf(ErrInvalidValue, "invalid threatFeedUrl %q: %w", config.ThreatFeedURL, err))
+	case u.Scheme != "http" && u.Scheme != "https":
+		errs.add(configErrorf(ErrInvalidValue, "threatFeedUrl %q must use http or https", config.ThreatFeedURL))
+	}
+	return errs.err()
+}
+
+// parseThreatFeed compiles a feed of one regex per line. Blank lines and
+// lines starting with # are ignored, invalid patterns are skipped and
+// patterns beyond maxThreatFeedPatterns are dropped, all with a log line.
+func parseThreatFeed(data []byte, source string) []*regexp.Regexp {
+	patterns := []*regexp.Regexp{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 4096), len(data)+1)
+	for line := 1; scanner.Scan(); line++ {
+		pattern := strings.TrimSpace(scanner.Text())
+		if pattern == "" || strings.HasPrefix(pattern, "#") {
+			continue
+		}
+		if len(patterns) == maxThreatFeedPatterns {
+			log.Printf("threat feed %q: ignoring patterns after the first %d", source, maxThreatFeedPatterns)
+			break
+		}
+		re, err := regexp.Compile(pattern) // Not cached: feeds change over time
+		if err != nil {
+			log.Printf("threat feed %q: skipping invalid pattern on line %d: %v", source, line, err)
+			continue
+		}
+		patterns = append(patterns, re)
+	}
+	return patterns
+}
+
+// newThreatFeed fetches the threat feed of a validated config and starts its
+// refresh, or returns nil when no feed is configured. The middleware fails
+// open: an unreachable feed is logged and leaves the denylist empty.
+func newThreatFeed(ctx context.Context, config *Config, name string) *threatFeed {
+	if config.ThreatFeedURL == "" {
+		return nil
+	}
+	f := &threatFeed{
+		name:     name,
+		url:      config.ThreatFeedURL,
+		maxBytes: config.MaxRulesBytes,
+		stop:     make(chan struct{}),
+	}
+	if f.maxBytes == 0 {
+		f.maxBytes = defaultMaxRulesBytes
+	}
+	f.patterns.Store(&[]*regexp.Regexp{})
+	f.refresh(ctx)
+	if config.ReloadInterval != "" {
+		interval, _ := time.ParseDuration(config.ReloadInterval)
+		go f.refreshEvery(ctx, interval)
+	}
+	return f
+}
+
+// refresh fetches the feed and replaces the patterns, keeping the current
+// ones when the fetch fails or the feed did not change.
+func (f *threatFeed) refresh(ctx context.Context) {
+	data, header, err := download(ctx, f.url, f.maxBytes, f.validators)
+	if errors.Is(err, errNotModified) {
+		return
+	}
+	if err != nil {
+		log.Printf("%s: keeping the current threat feed: %v", f.name, err)
+		return
+	}
+	patterns := parseThreatFeed(data, f.url)
+	f.patterns.Store(&patterns)
+	f.validators = validatorsOf(header)
+	log.Printf("%s: loaded %d threat feed patterns", f.name, len(patterns))
+}
+
+// refreshEvery refreshes the feed every interval until close is called or
+// ctx, the construction context, is cancelled.
+func (f *threatFeed) refreshEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			f.refresh(ctx)
+		case <-ctx.Done():
+			return
+		case <-f.stop:
+			return
+		}
+	}
+}
+
+// match reports whether the User-Agent matches a feed pattern.
+func (f *threatFeed) match(userAgent string) bool {
+	for _, re := range *f.patterns.Load() {
+		if re.MatchString(userAgent) {
+			return true
+		}
+	}
+	return false
+}
+
+// close stops the refresh. It is idempotent.
+func (f *threatFeed) close() {
+	f.stopOnce.Do(func() { close(f.stop) })
+}
+
+// checkThreatFeed blocks requests any of whose User-Agent values, as matched
+// by the User-Agent rules, matches the threat feed, or returns nil.
+func (b *BlockUserAgents) checkThreatFeed(req *http.Request) *decision {
+	if b.threatFeed == nil {
+		return nil
+	}
+	matched := false
+	b.guard.run("threat feed", func() {
+		for _, userAgent := range b.userAgentValues(req) {
+			if matched = b.threatFeed.match(userAgent); matched {
+				return
+			}
+		}
+	})
+	if !matched {
+		return nil
+	}
+	d := block("Threat Feed")
+	return &d
+}
@@ -0,0 +1,107 @@
+package traefik_plugin_block_useragents
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newFeedServer serves feed and counts the requests it receives.
+func newFeedServer(t *testing.T, feed string) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, _ *http.Request) {
+		hits.Add(1)
+		fmt.Fprint(res, feed)
+	}))
+	t.Cleanup(server.Close)
+	return server, &hits
+}
+
+func TestThreatFeedStopsWithContext(t *testing.T) {
+	server, hits := newFeedServer(t, "BadBot\n")
+	ctx, cancel := context.WithCancel(context.Background())
+	config := testConfig()
+	config.ThreatFeedURL = server.URL
+	f := newThreatFeed(ctx, config, "test")
+	defer f.close()
+
+	done := make(chan struct{})
+	go func() {
+		f.refreshEvery(ctx, 10*time.Millisecond)
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("refresh still running after the context was cancelled")
+	}
+	if hits.Load() < 2 {
+		t.Errorf("feed fetched %d times, want a refresh", hits.Load())
+	}
+}
+
+func TestThreatFeedMatchesUserAgentValues(t *testing.T) {
+	server, _ := newFeedServer(t, "# Known bad\nBadBot\n")
+	tests := []struct {
+		name      string
+		matchAll  bool
+		decode    bool
+		headers   []string
+		userAgent []string
+		want      int
+	}{
+		{"listed", false, false, nil, []string{"BadBot/1.0 " + chromeUA}, http.StatusForbidden},
+		{"not listed", false, false, nil, []string{chromeUA}, http.StatusOK},
+		{"second value", true, false, nil, []string{chromeUA, "BadBot/1.0"}, http.StatusForbidden},
+		{"encoded", false, true, nil, []string{"Bad%42ot/1.0 " + chromeUA}, http.StatusForbidden},
+		{"match header", false, false, []string{"X-Original-User-Agent", "User-Agent"}, []string{chromeUA}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.ThreatFeedURL = server.URL
+			config.MatchAllHeaderValues = tt.matchAll
+			config.DecodeUserAgent = tt.decode
+			if tt.headers != nil {
+				config.MatchHeaders = tt.headers
+			}
+			h := newTestHandler(t, config, nil)
+			rec := serve(h, "", func(req *http.Request) {
+				for _, userAgent := range tt.userAgent {
+					req.Header.Add("User-Agent", userAgent)
+				}
+				if tt.headers != nil {
+					req.Header.Set(tt.headers[0], "BadBot/1.0")
+				}
+			})
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestThreatFeedConditionalRefresh(t *testing.T) {
+	server := newVersionedServer(t, "BadBot\n")
+	config := testConfig()
+	config.ThreatFeedURL = server.URL
+	f := newThreatFeed(context.Background(), config, "test")
+	defer f.close()
+
+	f.refresh(context.Background())
+	if server.notModified.Load() != 1 || !f.match("BadBot/1.0") {
+		t.Fatalf("unchanged feed: %d not modified responses, match %v", server.notModified.Load(), f.match("BadBot/1.0"))
+	}
+	server.update("WorseBot\n")
+	f.refresh(context.Background())
+	if f.match("BadBot/1.0") || !f.match("WorseBot/1.0") {
+		t.Error("changed feed not loaded")
+	}
+}
@@ -0,0 +1,77 @@
+package traefik_plugin_block_useragents
+
+import (
+	"crypto/tls"
+	"net/http"
+	"strings"
+)
+
+// defaultTLSVersionHeader is the header consulted for the TLS version
+// negotiated by an upstream TLS terminator.
+const defaultTLSVersionHeader = "X-Forwarded-TLS-Version"
+
+// tlsVersions lists the supported TLS versions and their crypto/tls values.
+var tlsVersions = []struct {
+	version []int
+	value   uint16
+}{
+	{[]int{1, 0}, tls.VersionTLS10},
+	{[]int{1, 1}, tls.VersionTLS11},
+	{[]int{1, 2}, tls.VersionTLS12},
+	{[]int{1, 3}, tls.VersionTLS13},
+}
+
+// parseTLSVersion parses versions written as "1.2", "TLS1.2", "TLSv1.2" or "TLS 1.2".
+func parseTLSVersion(value string) (uint16, bool) {
+	value = strings.TrimSpace(strings.ToUpper(value))
+	value = strings.TrimPrefix(value, "TLS")
+	value = strings.TrimPrefix(strings.TrimSpace(value), "V")
+	version, err := parseVersion(value)
+	if err != nil {
+		return 0, false
+	}
+	for _, known := range tlsVersions {
+		if compareVersions(version, known.version) == 0 {
+			return known.value, true
+		}
+	}
+	return 0, false
+}
+
+// validateTLSVersion checks the minimum TLS version setting.
+func validateTLSVersion(config *Config) error {
+	if config.MinTLSVersion == "" {
+		return nil
+	}
+	if _, ok := parseTLSVersion(config.MinTLSVersion); !ok {
+		return configErrorf(ErrInvalidValue, "invalid minTlsVersion %q, expected 1.0, 1.1, 1.2 or 1.3", config.MinTLSVersion)
+	}
+	return nil
+}
+
+// checkTLSVersion blocks clients that negotiated a TLS version below the
+// minimum. The version of the connection is used when TLS terminates here,
+// the forwarded header otherwise when forwarded headers are trusted. Requests whose version is unknown pass
+// unless blockUnknownTLS is set. It returns nil when the request passes.
+func (b *BlockUserAgents) checkTLSVersion(req *http.Request) *decision {
+	if b.minTLSVersion == 0 {
+		return nil
+	}
+	var version uint16
+	known := false
+	if req.TLS != nil {
+		version, known = req.TLS.Version, true
+	} else if value := req.Header.Get(b.tlsVersionHeader); value != "" && b.trustsForwardedHeaders(req) {
+		version, known = parseTLSVersion(value)
+	}
+	if !known {
+		if b.blockUnknownTLS {
+			return blockDecision("Insufficient TLS")
+		}
+		return nil
+	}
+	if version < b.minTLSVersion {
+		return blockDecision("Insufficient TLS")
+	}
+	return nil
+}
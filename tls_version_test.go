@@ -0,0 +1,64 @@
+package traefik_plugin_block_useragents
+
+import (
+	"crypto/tls"
+	"net/http"
+	"testing"
+)
+
+func TestMinTLSVersion(t *testing.T) {
+	tls12 := func(req *http.Request) { req.TLS = &tls.ConnectionState{Version: tls.VersionTLS12} }
+	tests := []struct {
+		name           string
+		trustForwarded bool
+		blockUnknown   bool
+		edits          []func(*http.Request)
+		want           int
+	}{
+		{"TLS 1.3 connection", false, true, []func(*http.Request){withTLS}, http.StatusOK},
+		{"TLS 1.2 connection", false, false, []func(*http.Request){tls12}, http.StatusForbidden},
+		{"unknown version allowed", false, false, nil, http.StatusOK},
+		{"unknown version blocked", false, true, nil, http.StatusForbidden},
+		{"untrusted header", false, true, []func(*http.Request){withHeader(defaultTLSVersionHeader, "1.3")}, http.StatusForbidden},
+		{"trusted header", true, true, []func(*http.Request){withHeader(defaultTLSVersionHeader, "TLSv1.3")}, http.StatusOK},
+		{"trusted header too old", true, true, []func(*http.Request){withHeader(defaultTLSVersionHeader, "1.2")}, http.StatusForbidden},
+		{"connection wins over header", true, true, []func(*http.Request){tls12, withHeader(defaultTLSVersionHeader, "1.3")}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.MinTLSVersion = "1.3"
+			config.BlockUnknownTLSVersion = tt.blockUnknown
+			config.TrustForwardedHeader = tt.trustForwarded
+			h := newTestHandler(t, config, nil)
+			if rec := serve(h, chromeUA, tt.edits...); rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestTLSVersionHeader(t *testing.T) {
+	tests := []struct {
+		name  string
+		edits []func(*http.Request)
+		want  int
+	}{
+		{"custom header", []func(*http.Request){withHeader("X-TLS", "1.3")}, http.StatusOK},
+		{"custom header too old", []func(*http.Request){withHeader("X-TLS", "1.2")}, http.StatusForbidden},
+		{"default header ignored", []func(*http.Request){withHeader(defaultTLSVersionHeader, "1.3")}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.MinTLSVersion = "1.3"
+			config.BlockUnknownTLSVersion = true
+			config.TrustForwardedHeader = true
+			config.TLSVersionHeader = "X-TLS"
+			h := newTestHandler(t, config, nil)
+			if rec := serve(h, chromeUA, tt.edits...); rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
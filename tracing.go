@@ -0,0 +1,129 @@
+package traefik_plugin_block_useragents
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// traceparentHeader carries the W3C trace context of a request.
+const traceparentHeader = "traceparent"
+
+// Decisions recorded by DecisionSpan.
+const (
+	SpanAllowed    = "allowed"
+	SpanBlocked    = "blocked"
+	SpanChallenged = "challenged"
+)
+
+// DecisionSpan is the span of a request through the middleware, continuing
+// the W3C trace context (traceparent header) of the request. It is handed to
+// BlockUserAgents.SpanHook when the request leaves the middleware.
+type DecisionSpan struct {
+	TraceID      string    // 32 hex digits, the one of the request trace when it had one
+	SpanID       string    // 16 hex digits, the parent of the spans of the next handler
+	ParentSpanID string    // Span of the request traceparent, empty when the trace starts here
+	Sampled      bool      // Sampled flag of the trace
+	Start        time.Time // When the request entered the middleware
+	End          time.Time // When the response was written or the next handler returned
+	Decision     string    // SpanAllowed, SpanBlocked or SpanChallenged
+	Reason       string    // Block reason, empty when allowed
+	Rule         string    // Label of the browser rule that decided, when known
+}
+
+// spanContextKey keys the DecisionSpan in the request context.
+type spanContextKey struct{}
+
+// parseTraceparent parses a traceparent header. Versions above 00 may carry
+// more fields, which are ignored; version ff and all-zero IDs are invalid.
+func parseTraceparent(value string) (traceID, parentID string, flags byte, ok bool) {
+	fields := strings.Split(strings.TrimSpace(value), "-")
+	if len(fields) < 4 || len(fields[0]) != 2 || len(fields[1]) != 32 || len(fields[2]) != 16 || len(fields[3]) != 2 {
+		return "", "", 0, false
+	}
+	if fields[0] == "ff" || (fields[0] == "00" && len(fields) != 4) {
+		return "", "", 0, false
+	}
+	for _, field := range fields[:4] {
+		if !isLowerHex(field) {
+			return "", "", 0, false
+		}
+	}
+	if strings.Trim(fields[1], "0") == "" || strings.Trim(fields[2], "0") == "" {
+		return "", "", 0, false
+	}
+	decoded, _ := hex.DecodeString(fields[3])
+	return fields[1], fields[2], decoded[0], true
+}
+
+// isLowerHex reports whether s is made of lowercase hex digits only.
+func isLowerHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if (s[i] < '0' || s[i] > '9') && (s[i] < 'a' || s[i] > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// randomHex returns n random bytes in hex.
+func randomHex(n int) (string, error) {
+	id := make([]byte, n)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
+
+// startSpan starts the span of a request, continuing its trace or starting
+// a new one, and forwards the span as the parent of the next handler. The
+// returned request carries the span in its context. It returns a nil span
+// when tracing is off, or when the span was already started by the instance
+// handing the request over to this one.
+func (b *BlockUserAgents) startSpan(req *http.Request) (*DecisionSpan, *http.Request) {
+	if !b.tracing || spanFrom(req) != nil {
+		return nil, req
+	}
+	span := &DecisionSpan{Start: b.clock.Now(), Decision: SpanAllowed}
+	flags := byte(1) // New traces are sampled
+	if traceID, parentID, parentFlags, ok := parseTraceparent(req.Header.Get(traceparentHeader)); ok {
+		span.TraceID, span.ParentSpanID, flags = traceID, parentID, parentFlags
+	} else {
+		var err error
+		if span.TraceID, err = randomHex(16); err != nil {
+			return nil, req
+		}
+	}
+	var err error
+	if span.SpanID, err = randomHex(8); err != nil {
+		return nil, req
+	}
+	span.Sampled = flags&1 == 1
+	req.Header.Set(traceparentHeader, fmt.Sprintf("00-%s-%s-%02x", span.TraceID, span.SpanID, flags))
+	return span, req.WithContext(context.WithValue(req.Context(), spanContextKey{}, span))
+}
+
+// spanFrom returns the span of a request, or nil when it has none.
+func spanFrom(req *http.Request) *DecisionSpan {
+	span, _ := req.Context().Value(spanContextKey{}).(*DecisionSpan)
+	return span
+}
+
+// recordSpan records the decision on the span of a request, if any.
+func recordSpan(req *http.Request, decision string, d decision) {
+	if span := spanFrom(req); span != nil {
+		span.Decision, span.Reason, span.Rule = decision, d.reason, d.label
+	}
+}
+
+// endSpan ends a span and hands it to the span hook.
+func (b *BlockUserAgents) endSpan(span *DecisionSpan) {
+	span.End = b.clock.Now()
+	if b.SpanHook != nil {
+		b.guard.run("span hook", func() { b.SpanHook(*span) })
+	}
+}
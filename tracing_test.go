@@ -0,0 +1,117 @@
+package traefik_plugin_block_useragents
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		value  string
+		ok     bool
+		flags  byte
+		parent string
+	}{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true, 1, "00f067aa0ba902b7"},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", true, 0, "00f067aa0ba902b7"},
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-future", true, 1, "00f067aa0ba902b7"},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", false, 0, ""},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false, 0, ""},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", false, 0, ""},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false, 0, ""},
+		{"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", false, 0, ""},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7", false, 0, ""},
+		{"", false, 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			_, parent, flags, ok := parseTraceparent(tt.value)
+			if ok != tt.ok || flags != tt.flags || parent != tt.parent {
+				t.Errorf("parseTraceparent() = %q, %d, %v, want %q, %d, %v", parent, flags, ok, tt.parent, tt.flags, tt.ok)
+			}
+		})
+	}
+}
+
+func TestTracingSpans(t *testing.T) {
+	const incoming = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"
+	tests := []struct {
+		name        string
+		userAgent   string
+		traceparent string
+		decision    string
+		reason      string
+		newTrace    bool
+	}{
+		{"allowed continues the trace", chromeUA, incoming, SpanAllowed, "", false},
+		{"blocked continues the trace", curlUA, incoming, SpanBlocked, "Unsupported Browser", false},
+		{"invalid traceparent starts a trace", chromeUA, "garbage", SpanAllowed, "", true},
+		{"no traceparent starts a trace", chromeUA, "", SpanAllowed, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.Tracing = true
+			var forwarded string
+			next := http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+				forwarded = req.Header.Get(traceparentHeader)
+			})
+			h := newTestHandler(t, config, next)
+			var (
+				mu    sync.Mutex
+				spans []DecisionSpan
+			)
+			h.SpanHook = func(span DecisionSpan) {
+				mu.Lock()
+				defer mu.Unlock()
+				spans = append(spans, span)
+			}
+			serve(h, tt.userAgent, func(req *http.Request) {
+				if tt.traceparent != "" {
+					req.Header.Set(traceparentHeader, tt.traceparent)
+				}
+			})
+
+			if len(spans) != 1 {
+				t.Fatalf("%d spans, want 1", len(spans))
+			}
+			span := spans[0]
+			if span.Decision != tt.decision || span.Reason != tt.reason {
+				t.Errorf("decision = %q, %q, want %q, %q", span.Decision, span.Reason, tt.decision, tt.reason)
+			}
+			if tt.newTrace {
+				if span.ParentSpanID != "" || !span.Sampled || len(span.TraceID) != 32 {
+					t.Errorf("new trace span = %+v", span)
+				}
+			} else if span.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || span.ParentSpanID != "00f067aa0ba902b7" || span.Sampled {
+				t.Errorf("continued span = %+v", span)
+			}
+			if span.End.Before(span.Start) {
+				t.Errorf("span ends at %v, before its start %v", span.End, span.Start)
+			}
+			if tt.decision == SpanAllowed {
+				if traceID, parentID, _, ok := parseTraceparent(forwarded); !ok || traceID != span.TraceID || parentID != span.SpanID {
+					t.Errorf("forwarded traceparent %q, want the span %s as parent", forwarded, span.SpanID)
+				}
+			}
+		})
+	}
+}
+
+func TestTracingSpanOncePerPolicy(t *testing.T) {
+	config := testConfig()
+	config.Tracing = true
+	config.Policies = map[string]PolicyConfig{
+		"firefox": {AllowedBrowsers: []BrowserConfig{{Name: "Firefox", Regex: `Firefox/`}}},
+	}
+	config.DefaultPolicy = "firefox"
+	h := newTestHandler(t, config, nil)
+	var spans []DecisionSpan
+	h.SpanHook = func(span DecisionSpan) { spans = append(spans, span) }
+
+	serve(h, chromeUA)
+	if len(spans) != 1 || spans[0].Decision != SpanBlocked {
+		t.Errorf("spans = %+v, want one blocked span", spans)
+	}
+}
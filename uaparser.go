@@ -0,0 +1,95 @@
+package traefik_plugin_block_useragents
+
+import (
+	"regexp"
+	"strings"
+)
+
+// uaToken maps a regex with a version capture group to a display name.
+type uaToken struct {
+	name string
+	re   *regexp.Regexp
+}
+
+// browserTokens are checked in order; more specific products come first since
+// most browsers also claim to be Chrome, Safari or Mozilla.
+var browserTokens = []uaToken{
+	{"Edge", regexp.MustCompile(`Edg(?:e|A|iOS)?/([0-9.]+)`)},
+	{"Opera", regexp.MustCompile(`(?:OPR|Opera)/([0-9.]+)`)},
+	{"Samsung Internet", regexp.MustCompile(`SamsungBrowser/([0-9.]+)`)},
+	{"Brave", regexp.MustCompile(`Brave/([0-9.]+)`)},
+	{"Chrome for iOS", regexp.MustCompile(`CriOS/([0-9.]+)`)},
+	{"Firefox for iOS", regexp.MustCompile(`FxiOS/([0-9.]+)`)},
+	{"Firefox", regexp.MustCompile(`Firefox/([0-9.]+)`)},
+	{"Chrome", regexp.MustCompile(`Chrome/([0-9.]+)`)},
+	{"Safari", regexp.MustCompile(`Version/([0-9.]+).*Safari/`)},
+	{"Internet Explorer", regexp.MustCompile(`(?:MSIE |Trident/.*rv:)([0-9.]+)`)},
+	{"curl", regexp.MustCompile(`curl/([0-9.]+)`)},
+	{"Wget", regexp.MustCompile(`Wget/([0-9.]+)`)},
+	{"python-requests", regexp.MustCompile(`python-requests/([0-9.]+)`)},
+	{"Go-http-client", regexp.MustCompile(`Go-http-client/([0-9.]+)`)},
+	{"Bot", regexp.MustCompile(`(?i)(?:bot|crawler|spider)(?:/([0-9.]+))?`)},
+}
+
+// osTokens are checked in order; iOS and Android come before macOS and Linux
+// because their UAs also mention those platforms.
+var osTokens = []uaToken{
+	{"Windows", regexp.MustCompile(`Windows NT ([0-9.]+)`)},
+	{"iOS", regexp.MustCompile(`(?:iPhone|CPU) OS ([0-9_]+)`)},
+	{"Android", regexp.MustCompile(`Android ([0-9.]+)`)},
+	{"ChromeOS", regexp.MustCompile(`CrOS \S+ ([0-9.]+)`)},
+	{"macOS", regexp.MustCompile(`Mac OS X ([0-9_.]+)`)},
+	{"Linux", regexp.MustCompile(`Linux()`)},
+}
+
+// windowsVersions maps Windows NT versions to their marketing names.
+var windowsVersions = map[string]string{
+	"10.0": "10",
+	"6.3":  "8.1",
+	"6.2":  "8",
+	"6.1":  "7",
+	"6.0":  "Vista",
+	"5.1":  "XP",
+}
+
+// parseUserAgent extracts a best-effort browser and OS description from a
+// User-Agent, e.g. "Chrome 131.0.0.0" and "Windows 10". Unknown parts are empty.
+func parseUserAgent(userAgent string) (browser, os string) {
+	browser = matchToken(browserTokens, userAgent)
+
+	for _, token := range osTokens {
+		m := token.re.FindStringSubmatch(userAgent)
+		if m == nil {
+			continue
+		}
+		version := strings.ReplaceAll(m[1], "_", ".")
+		if token.name == "Windows" {
+			if name, ok := windowsVersions[version]; ok {
+				version = name
+			}
+		}
+		os = strings.TrimSpace(token.name + " " + version)
+		break
+	}
+	return browser, os
+}
+
+// matchToken returns "name version" for the first token matching userAgent.
+func matchToken(tokens []uaToken, userAgent string) string {
+	name, version, ok := firstToken(tokens, userAgent)
+	if !ok {
+		return ""
+	}
+	return strings.TrimSpace(name + " " + version)
+}
+
+// firstToken returns the name and the raw version of the first token
+// matching userAgent.
+func firstToken(tokens []uaToken, userAgent string) (name, version string, ok bool) {
+	for _, token := range tokens {
+		if m := token.re.FindStringSubmatch(userAgent); m != nil {
+			return token.name, m[1], true
+		}
+	}
+	return "", "", false
+}
@@ -0,0 +1,26 @@
+package traefik_plugin_block_useragents
+
+import (
+	"testing"
+)
+
+func TestParseUserAgent(t *testing.T) {
+	tests := []struct {
+		userAgent   string
+		wantBrowser string
+		wantOS      string
+	}{
+		{chromeUA, "Chrome 131.0.0.0", "Windows 10"},
+		{firefoxUA, "Firefox 128.0", "Linux"},
+		{safariUA, "Safari 17.4", "macOS 10.15.7"},
+		{"Mozilla/5.0 (Windows NT 6.1; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/109.0.0.0 Safari/537.36 Edg/109.0.1518.78", "Edge 109.0.1518.78", "Windows 7"},
+		{curlUA, "curl 8.0", ""},
+		{"", "", ""},
+	}
+	for _, tt := range tests {
+		browser, os := parseUserAgent(tt.userAgent)
+		if browser != tt.wantBrowser || os != tt.wantOS {
+			t.Errorf("parseUserAgent(%q) = %q, %q, want %q, %q", tt.userAgent, browser, os, tt.wantBrowser, tt.wantOS)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package traefik_plugin_block_useragents
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// defaultValidateAllowedIPs may call the validation endpoint when
+// ValidateAllowedIPs is not set.
+var defaultValidateAllowedIPs = []string{"127.0.0.1/32", "::1/128"}
+
+// ValidationResult is the response of the validation endpoint. Valid is
+// true when the configuration would be accepted by New.
+type ValidationResult struct {
+	Valid    bool     `json:"valid"`
+	Errors   []string `json:"errors"`
+	Warnings []string `json:"warnings"`
+}
+
+// validateValidateEndpoint checks the validation endpoint settings.
+func validateValidateEndpoint(config *Config) error {
+	if !config.EnableValidateEndpoint {
+		if config.ValidatePath != "" || len(config.ValidateAllowedIPs) > 0 {
+			return configErrorf(ErrMissingSetting, "validatePath and validateAllowedIPs require enableValidateEndpoint")
+		}
+		return nil
+	}
+	var errs ConfigErrors
+	if !strings.HasPrefix(config.ValidatePath, "/") {
+		errs.add(configErrorf(ErrInvalidValue, "validatePath must be an absolute path, got %q", config.ValidatePath))
+	}
+	if _, err := parseIPNets(config.ValidateAllowedIPs); err != nil {
+		errs.add(configErrorf(ErrInvalidValue, "invalid validateAllowedIPs: %w", err))
+	}
+	return errs.err()
+}
+
+// newValidateAllowedIPs parses the IPs allowed to call the validation
+// endpoint, defaulting to loopback.
+func newValidateAllowedIPs(config *Config) []*net.IPNet {
+	allowed := config.ValidateAllowedIPs
+	if len(allowed) == 0 {
+		allowed = defaultValidateAllowedIPs
+	}
+	nets, _ := parseIPNets(allowed)
+	return nets
+}
+
+// isValidateRequest reports whether the request targets the validation endpoint.
+func (b *BlockUserAgents) isValidateRequest(req *http.Request) bool {
+	return b.validatePath != "" && req.URL != nil && req.URL.Path == b.validatePath
+}
+
+// serveValidate validates the candidate configuration posted as JSON and
+// writes a ValidationResult. The running middleware is not affected. Access
+// is checked against the connection address, never the forwarded headers a
+// client could spoof.
+func (b *BlockUserAgents) serveValidate(res http.ResponseWriter, req *http.Request) {
+	if !containsIP(b.validateAllowedIPs, remoteIP(req.RemoteAddr)) {
+		res.WriteHeader(http.StatusForbidden)
+		return
+	}
+	if req.Method != http.MethodPost {
+		res.Header().Set("Allow", http.MethodPost)
+		res.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(req.Body, defaultMaxRulesBytes+1))
+	if err != nil {
+		http.Error(res, "error reading the configuration", http.StatusBadRequest)
+		return
+	}
+	if len(body) > defaultMaxRulesBytes {
+		http.Error(res, "configuration too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	result := b.validateCandidate(body)
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		res.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	status := http.StatusOK
+	if !result.Valid {
+		status = http.StatusUnprocessableEntity
+	}
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(status)
+	if _, err := res.Write(append(encoded, '\n')); err != nil {
+		log.Printf("%s: error writing validation result: %v", b.name, err)
+	}
+}
+
+// validateCandidate decodes a candidate configuration over the defaults,
+// validates it and compiles it into a throwaway instance. Settings reaching
+// outside the middleware (files, URLs, signals, expvar) are not exercised;
+// they are reported as warnings instead.
+func (b *BlockUserAgents) validateCandidate(body []byte) ValidationResult {
+	result := ValidationResult{Errors: []string{}, Warnings: []string{}}
+	config := CreateConfig()
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(config); err != nil {
+		result.Errors = append(result.Errors, fmt.Sprintf("invalid JSON configuration: %v", err))
+		return result
+	}
+
+	for _, skipped := range []struct {
+		name string
+		set  bool
+	}{
+		{"rulesFile", config.RulesFile != ""},
+		{"rulesUrl", config.RulesURL != ""},
+		{"threatFeedUrl", config.ThreatFeedURL != ""},
+		{"blockLogFile", config.BlockLogFile != ""},
+		{"webhookUrl", config.WebhookURL != ""},
+		{"blockPageFile", config.BlockPageFile != "" || len(config.BlockPagesByReason) > 0},
+		{"reloadOnSignal", config.ReloadOnSignal},
+		{"expvar", config.Expvar},
+		{"learnFile", config.LearnFile != ""},
+	} {
+		if skipped.set {
+			result.Warnings = append(result.Warnings, skipped.name+" is not checked by the validation endpoint")
+		}
+	}
+	if (config.RulesFile != "" || config.RulesURL != "") && config.EmptyConfigBehavior == "" && emptyRuleset(config) {
+		config.EmptyConfigBehavior = EmptyConfigAllowAll // The browsers may all come from the skipped rules
+	}
+	config.RulesFile, config.RulesURL = "", ""
+	config.ThreatFeedURL, config.ReloadInterval = "", ""
+	config.BlockLogFile, config.BlockLogMaxBytes = "", 0
+	config.WebhookURL, config.WebhookAuthHeader = "", ""
+	config.BlockPageFile, config.BlockPagesByReason = "", nil
+	config.ReloadOnSignal = false
+	config.Expvar = false
+	config.LearnFile = ""
+	config.EnableValidateEndpoint, config.ValidatePath, config.ValidateAllowedIPs = false, "", nil
+	config.MetricsPath, config.MetricsAllowedIPs = "", nil
+
+	result.Warnings = append(result.Warnings, analyzeRules(config)...)
+	handler, err := New(context.Background(), http.NotFoundHandler(), config, b.name+".validate")
+	if err != nil {
+		var problems ConfigErrors
+		if !errors.As(err, &problems) {
+			problems = ConfigErrors{err}
+		}
+		for _, problem := range problems {
+			result.Errors = append(result.Errors, problem.Error())
+		}
+		return result
+	}
+	if candidate, ok := handler.(*BlockUserAgents); ok {
+		_ = candidate.Close()
+	}
+	result.Valid = true
+	return result
+}
@@ -0,0 +1,107 @@
+package traefik_plugin_block_useragents
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestValidateEndpointAccess(t *testing.T) {
+	config := testConfig()
+	config.EnableValidateEndpoint = true
+	config.ValidatePath = "/_validate"
+	config.TrustForwardedHeader = true
+	h := newTestHandler(t, config, nil)
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		want       int
+	}{
+		{"loopback", "127.0.0.1:4321", "", http.StatusOK},
+		{"remote", "192.0.2.1:4321", "", http.StatusForbidden},
+		{"spoofed X-Forwarded-For", "192.0.2.1:4321", "127.0.0.1", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "http://example.com"+h.validatePath, strings.NewReader(`{"allowedBrowsers":[{"name":"Chrome","regex":"Chrome/"}]}`))
+			req.RemoteAddr = tt.remoteAddr
+			if tt.forwarded != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateCandidateSkipsOutboundSettings(t *testing.T) {
+	h := newTestHandler(t, testConfig(), nil)
+	result := h.validateCandidate([]byte(`{
+		"allowedBrowsers": [{"name": "Chrome", "regex": "Chrome/"}],
+		"webhookUrl": "http://192.0.2.1/hook",
+		"webhookAuthHeader": "Bearer secret"
+	}`))
+	if !result.Valid {
+		t.Fatalf("candidate invalid: %v", result.Errors)
+	}
+	encoded, _ := json.Marshal(result.Warnings)
+	if !strings.Contains(string(encoded), "webhookUrl") {
+		t.Errorf("warnings = %s, want one for webhookUrl", encoded)
+	}
+}
+
+func TestValidateAllowedIPs(t *testing.T) {
+	config := testConfig()
+	config.EnableValidateEndpoint = true
+	config.ValidatePath = "/_validate"
+	config.ValidateAllowedIPs = []string{"192.0.2.0/24", "2001:db8::1"}
+	h := newTestHandler(t, config, nil)
+
+	tests := []struct {
+		remoteAddr string
+		want       int
+	}{
+		{"192.0.2.1:4321", http.StatusOK},
+		{"[2001:db8::1]:4321", http.StatusOK},
+		{"198.51.100.1:4321", http.StatusForbidden},
+		{"127.0.0.1:4321", http.StatusForbidden}, // Loopback is only the default
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "http://example.com"+h.validatePath, strings.NewReader(`{"allowedBrowsers":[{"name":"Chrome","regex":"Chrome/"}]}`))
+		req.RemoteAddr = tt.remoteAddr
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.remoteAddr, rec.Code, tt.want)
+		}
+	}
+
+	invalid := []struct {
+		name    string
+		enable  bool
+		allowed []string
+		wantErr error
+	}{
+		{"invalid entry", true, []string{"not-an-ip"}, ErrInvalidValue},
+		{"endpoint disabled", false, []string{"192.0.2.0/24"}, ErrMissingSetting},
+	}
+	for _, tt := range invalid {
+		config := testConfig()
+		config.EnableValidateEndpoint = tt.enable
+		if tt.enable {
+			config.ValidatePath = "/_validate"
+		}
+		config.ValidateAllowedIPs = tt.allowed
+		if err := ValidateConfig(config); !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: ValidateConfig = %v, want %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
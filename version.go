@@ -0,0 +1,83 @@
+package traefik_plugin_block_useragents
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxVersionComponents bounds the number of components parsed from a version.
+const maxVersionComponents = 8
+
+// parseVersion parses a dotted version such as "121.0.6167.85", "121" or
+// "17_4_1" into its numeric components. Parsing stops at the first character
+// that cannot continue the version, so pre-release suffixes and trailing junk
+// ("121.0b2", "1.2-beta", "15.0 Mobile") are ignored. Missing components are
+// simply absent; compare versions with compareVersions, which treats them as 0.
+func parseVersion(version string) ([]int, error) {
+	version = strings.TrimSpace(version)
+	components := make([]int, 0, 4)
+	for len(components) < maxVersionComponents {
+		end := 0
+		for end < len(version) && version[end] >= '0' && version[end] <= '9' {
+			end++
+		}
+		if end == 0 {
+			break
+		}
+		n, err := strconv.Atoi(version[:end])
+		if err != nil {
+			return nil, fmt.Errorf("invalid version %q: %w", version, err)
+		}
+		components = append(components, n)
+		version = version[end:]
+		if len(version) < 2 || (version[0] != '.' && version[0] != '_') || version[1] < '0' || version[1] > '9' {
+			break
+		}
+		version = version[1:]
+	}
+	if len(components) == 0 {
+		return nil, fmt.Errorf("invalid version %q: no leading number", version)
+	}
+	return components, nil
+}
+
+// compareVersions compares two parsed versions component by component,
+// treating missing components as 0. It returns -1, 0 or +1.
+func compareVersions(a, b []int) int {
+	for i := 0; i < max(len(a), len(b)); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+	}
+	return 0
+}
+
+// satisfiesVersion reports whether the result of compareVersions satisfies
+// the operator of a version rule: >=, >, <=, <, !=, == or =.
+func satisfiesVersion(cmp int, op string) bool {
+	switch op {
+	case ">=":
+		return cmp >= 0
+	case ">":
+		return cmp > 0
+	case "<=":
+		return cmp <= 0
+	case "<":
+		return cmp < 0
+	case "!=":
+		return cmp != 0
+	default: // "==" and "="
+		return cmp == 0
+	}
+}
@@ -0,0 +1,72 @@
+package traefik_plugin_block_useragents
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		want    []int
+		wantErr bool
+	}{
+		{"121.0.6167.85", []int{121, 0, 6167, 85}, false},
+		{"121", []int{121}, false},
+		{"17_4_1", []int{17, 4, 1}, false},
+		{" 15.0 Mobile", []int{15, 0}, false},
+		{"121.0b2", []int{121, 0}, false},
+		{"1.2-beta", []int{1, 2}, false},
+		{"1.", []int{1}, false},
+		{"1..2", []int{1}, false},
+		{"1.2.3.4.5.6.7.8.9.10", []int{1, 2, 3, 4, 5, 6, 7, 8}, false},
+		{"99999999999999999999", nil, true},
+		{"beta", nil, true},
+		{"", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			got, err := parseVersion(tt.version)
+			if !reflect.DeepEqual(got, tt.want) || (err != nil) != tt.wantErr {
+				t.Errorf("parseVersion() = %v, %v, want %v, error %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b []int
+		want int
+	}{
+		{[]int{121}, []int{121, 0, 0}, 0},
+		{[]int{121, 0, 6167}, []int{121, 1}, -1},
+		{[]int{17, 4, 1}, []int{17, 4}, 1},
+		{[]int{10}, []int{9, 99}, 1},
+	}
+	for _, tt := range tests {
+		if got := compareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareVersions(%v, %v) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestSatisfiesVersion(t *testing.T) {
+	tests := []struct {
+		cmp  int
+		op   string
+		want bool
+	}{
+		{0, ">=", true}, {-1, ">=", false},
+		{1, ">", true}, {0, ">", false},
+		{0, "<=", true}, {1, "<=", false},
+		{-1, "<", true}, {0, "<", false},
+		{1, "!=", true}, {0, "!=", false},
+		{0, "==", true}, {0, "=", true}, {1, "=", false},
+	}
+	for _, tt := range tests {
+		if got := satisfiesVersion(tt.cmp, tt.op); got != tt.want {
+			t.Errorf("satisfiesVersion(%d, %q) = %v, want %v", tt.cmp, tt.op, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package traefik_plugin_block_useragents
+
+import (
+	"log"
+	"net/http"
+	"time"
+)
+
+// validateEnforcementDelay checks the enforcement delay.
+func validateEnforcementDelay(config *Config) error {
+	if config.EnforcementDelay == "" {
+		return nil
+	}
+	delay, err := time.ParseDuration(config.EnforcementDelay)
+	if err != nil {
+		return configErrorf(ErrInvalidValue, "invalid enforcementDelay %q: %w", config.EnforcementDelay, err)
+	}
+	if delay < 0 {
+		return configErrorf(ErrInvalidValue, "enforcementDelay must not be negative")
+	}
+	return nil
+}
+
+// startWarmup starts the enforcement delay of a validated config, if any.
+func (b *BlockUserAgents) startWarmup(config *Config) {
+	delay, _ := time.ParseDuration(config.EnforcementDelay)
+	if delay <= 0 {
+		return
+	}
+	b.enforceAfter = b.clock.Now().Add(delay)
+	log.Printf("%s: warming up, User-Agent decisions are only logged for %s", b.name, delay)
+}
+
+// inheritWarmup continues the enforcement delay of from in a ruleset rebuilt
+// from its configuration, on SIGHUP or a rulesUrl change, and in its
+// policies: the rebuilds do not restart the delay.
+func (b *BlockUserAgents) inheritWarmup(from *BlockUserAgents) {
+	b.enforceAfter = from.enforceAfter
+	b.enforcing.Store(from.enforcing.Load())
+	for _, policy := range b.policies {
+		policy.inheritWarmup(from)
+	}
+}
+
+// warmingUp reports whether the enforcement delay is still running. The
+// switch to enforcing is logged once, by the first request past the delay.
+func (b *BlockUserAgents) warmingUp() bool {
+	if b.enforceAfter.IsZero() || b.enforcing.Load() {
+		return false
+	}
+	if b.clock.Now().Before(b.enforceAfter) {
+		return true
+	}
+	if b.enforcing.CompareAndSwap(false, true) {
+		log.Printf("%s: warmup over, enforcing User-Agent decisions", b.name)
+	}
+	return false
+}
+
+// logWouldBlock logs a decision that is not enforced during the warmup. It
+// is sampled like block events.
+func (b *BlockUserAgents) logWouldBlock(req *http.Request, d decision) {
+	reason := d.reason
+	if d.challenge {
+		reason = "Challenge"
+	}
+	sampled := true
+	b.guard.run("log sampler", func() { sampled = b.logSampler.sample(reason) })
+	if !sampled {
+		return
+	}
+	b.logEvent(req, "Would-Block", reason, d.elapsed)
+}
@@ -0,0 +1,68 @@
+package traefik_plugin_block_useragents
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestEnforcementDelay(t *testing.T) {
+	config := testConfig()
+	config.EnforcementDelay = "1h"
+	config.DeniedIPs = []string{"198.51.100.0/24"}
+	h := newTestHandler(t, config, okHandler)
+
+	tests := []struct {
+		name  string
+		edits []func(*http.Request)
+		want  int
+	}{
+		{"blocked User-Agent", nil, http.StatusOK},
+		{"denied IP", []func(*http.Request){func(req *http.Request) { req.RemoteAddr = "198.51.100.7:1234" }}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := serve(h, curlUA, tt.edits...); rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnforcementDelayRestartsOnNew(t *testing.T) {
+	config := testConfig()
+	config.EnforcementDelay = "1h"
+	first := newTestHandler(t, config, okHandler)
+	time.Sleep(10 * time.Millisecond)
+	second := newTestHandler(t, config, okHandler)
+	if !second.enforceAfter.After(first.enforceAfter) {
+		t.Errorf("delay not restarted: ends %s, first instance %s", second.enforceAfter, first.enforceAfter)
+	}
+}
+
+func TestEnforcementDelayKeptAcrossReloads(t *testing.T) {
+	rulesFile := writeRules(t, "rules.json", `{"allowedBrowsers": [{"name": "Chrome", "regex": "Chrome/"}]}`)
+	h := newReloadableHandler(t, context.Background(), rulesFile, func(config *Config) {
+		config.EnforcementDelay = "1h"
+	})
+	time.Sleep(10 * time.Millisecond)
+	h.reload()
+	reloaded := h.reloaded.Load()
+	if reloaded == nil {
+		t.Fatal("reload did not build a ruleset")
+	}
+	if !reloaded.enforceAfter.Equal(h.enforceAfter) {
+		t.Errorf("delay restarted by the reload: ends %s, want %s", reloaded.enforceAfter, h.enforceAfter)
+	}
+	if rec := serve(h, curlUA); rec.Code != http.StatusOK {
+		t.Errorf("status during warmup after a reload = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	// A reload after the delay enforces at once
+	h.enforceAfter = time.Now().Add(-time.Minute)
+	h.reload()
+	if rec := serve(h, curlUA); rec.Code != http.StatusForbidden {
+		t.Errorf("status after the delay = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+}
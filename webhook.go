@@ -0,0 +1,190 @@
+package traefik_plugin_block_useragents
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// webhookQueueSize bounds the events waiting to be posted; events arriving
+// while the queue is full are dropped.
+const webhookQueueSize = 1024
+
+// webhookTimeout bounds each POST to the webhook.
+const webhookTimeout = 5 * time.Second
+
+// webhookBackoffs are the waits before the retries of a failed POST.
+var webhookBackoffs = []time.Duration{500 * time.Millisecond, 2 * time.Second, 5 * time.Second}
+
+// webhookSink posts blocked request events as JSON to a webhook from a
+// worker goroutine, so requests never wait on the network. Failed posts are
+// retried with backoff, then dropped with a log line.
+type webhookSink struct {
+	url        string
+	authHeader string
+	client     *http.Client
+
+	mu     sync.RWMutex
+	closed bool
+	events chan []byte
+	done   chan struct{}
+
+	ctx    context.Context // Canceled on close, or with the construction context, to abort pending retries
+	cancel context.CancelFunc
+
+	dropped atomic.Uint64
+}
+
+// validateWebhook checks the webhook settings.
+func validateWebhook(config *Config) error {
+	if config.WebhookURL == "" {
+		if config.WebhookAuthHeader != "" {
+			return configErrorf(ErrMissingSetting, "webhookAuthHeader requires webhookUrl")
+		}
+		return nil
+	}
+	u, err := url.Parse(config.WebhookURL)
+	if err != nil {
+		return configErrorf(ErrInvalidValue, "invalid webhookUrl %q: %w", config.WebhookURL, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return configErrorf(ErrInvalidValue, "webhookUrl %q must use http or https", config.WebhookURL)
+	}
+	return nil
+}
+
+// newWebhookSink starts the webhook worker of a validated config, or returns
+// nil when no webhook is configured. The worker stops on close or when ctx,
+// the construction context, is cancelled.
+func newWebhookSink(ctx context.Context, config *Config) *webhookSink {
+	if config.WebhookURL == "" {
+		return nil
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	s := &webhookSink{
+		url:        config.WebhookURL,
+		authHeader: config.WebhookAuthHeader,
+		client:     &http.Client{Timeout: webhookTimeout},
+		events:     make(chan []byte, webhookQueueSize),
+		done:       make(chan struct{}),
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+	go s.run()
+	go func() {
+		<-s.ctx.Done() // Canceled by close at the latest
+		s.close()
+	}()
+	return s
+}
+
+// run posts the queued events until the queue is closed.
+func (s *webhookSink) run() {
+	defer close(s.done)
+	for event := range s.events {
+		if s.ctx.Err() != nil {
+			s.dropped.Add(1) // Closed with events still queued
+			continue
+		}
+		if err := s.deliver(event); err != nil {
+			s.dropped.Add(1)
+			if s.ctx.Err() == nil {
+				log.Printf("error posting to webhook %q, dropping the event: %v", s.url, err)
+			}
+		}
+	}
+}
+
+// deliver posts an event, retrying failures after the backoffs. It gives up
+// early once the sink is closed.
+func (s *webhookSink) deliver(event []byte) error {
+	err := s.post(event)
+	for _, backoff := range webhookBackoffs {
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-time.After(backoff):
+		case <-s.ctx.Done():
+			return err
+		}
+		err = s.post(event)
+	}
+	return err
+}
+
+// post sends an event once. Any 2xx status is a success.
+func (s *webhookSink) post(event []byte) error {
+	req, err := http.NewRequestWithContext(s.ctx, http.MethodPost, s.url, bytes.NewReader(event))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.authHeader != "" {
+		req.Header.Set("Authorization", s.authHeader)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096)) // Lets the connection be reused
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// send queues an event. It never blocks: the event is dropped and counted
+// when the queue is full or the sink is closed.
+func (s *webhookSink) send(message *BlockUserAgentsMessage) {
+	event, err := json.Marshal(message)
+	if err != nil {
+		return
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		s.dropped.Add(1)
+		return
+	}
+	select {
+	case s.events <- event:
+	default:
+		if s.dropped.Add(1) == 1 {
+			log.Printf("webhook %q cannot keep up, dropping events", s.url)
+		}
+	}
+}
+
+// close stops accepting events, drops the queued ones, aborts the current
+// post and waits for the worker to finish. It is idempotent.
+func (s *webhookSink) close() {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.events)
+		s.cancel()
+	}
+	s.mu.Unlock()
+	<-s.done
+}
+
+// WebhookDropped returns the number of blocked request events that were not
+// delivered to the webhook: dropped while the queue was full, or failed
+// after the retries.
+func (b *BlockUserAgents) WebhookDropped() uint64 {
+	if b.webhook == nil {
+		return 0
+	}
+	return b.webhook.dropped.Load()
+}
@@ -0,0 +1,134 @@
+package traefik_plugin_block_useragents
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newWebhookServer collects the events posted to it.
+func newWebhookServer(t *testing.T) (*httptest.Server, chan BlockUserAgentsMessage) {
+	t.Helper()
+	events := make(chan BlockUserAgentsMessage, 16)
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		var event BlockUserAgentsMessage
+		if err := json.NewDecoder(req.Body).Decode(&event); err != nil {
+			res.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		events <- event
+	}))
+	t.Cleanup(server.Close)
+	return server, events
+}
+
+func TestWebhookStopsWithContext(t *testing.T) {
+	server, events := newWebhookServer(t)
+	config := testConfig()
+	config.WebhookURL = server.URL
+	ctx, cancel := context.WithCancel(context.Background())
+	handler, err := New(ctx, okHandler, config, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := handler.(*BlockUserAgents)
+
+	serve(b, curlUA)
+	select {
+	case event := <-events:
+		if event.UserAgent != curlUA {
+			t.Errorf("event for %q, want %q", event.UserAgent, curlUA)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("blocked request not posted")
+	}
+
+	cancel()
+	select {
+	case <-b.webhook.done:
+	case <-time.After(time.Second):
+		t.Fatal("webhook worker still running after the context was cancelled")
+	}
+	dropped := b.WebhookDropped() // The first post may have been aborted before its response
+	serve(b, curlUA)
+	if got := b.WebhookDropped(); got != dropped+1 {
+		t.Errorf("WebhookDropped() = %d, want %d", got, dropped+1)
+	}
+}
+
+func TestLogOnlyEvent(t *testing.T) {
+	logs := captureLog(t)
+	server, events := newWebhookServer(t)
+	path := filepath.Join(t.TempDir(), "blocked.log")
+	config := testConfig()
+	config.BlockedBrowsers = []BrowserConfig{{Name: "Chrome", Regex: "Chrome/", Action: ActionLogOnly}}
+	config.BlockLogFile = path
+	config.WebhookURL = server.URL
+	config.LogFormat = LogFormatJSON
+	h := newTestHandler(t, config, nil)
+
+	if rec := serve(h, chromeUA); rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	select {
+	case event := <-events:
+		if event.Event != "LogOnly" {
+			t.Errorf("webhook event = %q, want LogOnly", event.Event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("log-only match not posted")
+	}
+	_ = h.Close()
+	records := readBlockLog(t, path)
+	if len(records) != 1 || records[0].Event != "LogOnly" {
+		t.Errorf("block log records = %+v, want one LogOnly event", records)
+	}
+	if !strings.Contains(logs.String(), `"event":"LogOnly"`) {
+		t.Errorf("log = %q, want a LogOnly event", logs.String())
+	}
+}
+
+func TestWebhookAuthHeader(t *testing.T) {
+	tests := []struct {
+		name       string
+		authHeader string
+	}{
+		{"set", "Bearer secret"},
+		{"not set", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authorization := make(chan string, 1)
+			server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+				authorization <- req.Header.Get("Authorization")
+			}))
+			t.Cleanup(server.Close)
+			config := testConfig()
+			config.WebhookURL = server.URL
+			config.WebhookAuthHeader = tt.authHeader
+			h := newTestHandler(t, config, nil)
+
+			serve(h, curlUA)
+			select {
+			case got := <-authorization:
+				if got != tt.authHeader {
+					t.Errorf("Authorization = %q, want %q", got, tt.authHeader)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("blocked request not posted")
+			}
+		})
+	}
+
+	config := testConfig()
+	config.WebhookAuthHeader = "Bearer secret"
+	if err := ValidateConfig(config); !errors.Is(err, ErrMissingSetting) {
+		t.Errorf("ValidateConfig without webhookUrl = %v, want %v", err, ErrMissingSetting)
+	}
+}